@@ -4,13 +4,16 @@ go 1.13
 
 require (
 	github.com/go-chi/chi v4.0.4+incompatible
+	github.com/golang/protobuf v1.4.3
+	github.com/graph-gophers/graphql-go v1.3.0
 	github.com/konsorten/go-windows-terminal-sequences v1.0.3 // indirect
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/robfig/cron v1.2.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/strava/go.strava v0.0.0-20180612235916-99ebe972ba16
-	github.com/stretchr/testify v1.4.0 // indirect
 	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
+	google.golang.org/grpc v1.34.0
+	google.golang.org/protobuf v1.25.0
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22
 	gopkg.in/yaml.v2 v2.2.4 // indirect
@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"sync"
+	"time"
+)
+
+// batchWait is how long a loader collects IDs before fetching them in a single call,
+// resolvers of sibling fields and list items run concurrently so they land in one batch
+const batchWait = 2 * time.Millisecond
+
+// fetchFunc returns the values found for ids, missing IDs are left out of the map
+type fetchFunc func(ids []int64) (map[int64]interface{}, error)
+
+// Loader batches the lookups of one kind made while resolving a query, DataLoader style,
+// and caches the results until the query is done
+type Loader struct {
+	fetch fetchFunc
+
+	mu    sync.Mutex
+	cache map[int64]*entry
+	batch []int64
+}
+
+type entry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// NewLoader returns a loader fetching its batches with fetch
+func NewLoader(fetch fetchFunc) *Loader {
+	return &Loader{fetch: fetch, cache: map[int64]*entry{}}
+}
+
+// Load returns the value for id, it is nil when no value exists
+func (l *Loader) Load(id int64) (interface{}, error) {
+	l.mu.Lock()
+	e, ok := l.cache[id]
+	if !ok {
+		e = &entry{done: make(chan struct{})}
+		l.cache[id] = e
+		l.batch = append(l.batch, id)
+		if len(l.batch) == 1 {
+			time.AfterFunc(batchWait, l.dispatch)
+		}
+	}
+	l.mu.Unlock()
+
+	<-e.done
+	return e.value, e.err
+}
+
+// dispatch fetches the pending batch and wakes up its callers
+func (l *Loader) dispatch() {
+	l.mu.Lock()
+	ids := l.batch
+	l.batch = nil
+	l.mu.Unlock()
+
+	values, err := l.fetch(ids)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		e := l.cache[id]
+		e.value, e.err = values[id], err
+		if err != nil {
+			// a failed batch is retried by the next query rather than cached
+			delete(l.cache, id)
+		}
+		close(e.done)
+	}
+}
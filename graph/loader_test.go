@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestLoaderBatchesConcurrentLoads(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]int64
+	l := NewLoader(func(ids []int64) (map[int64]interface{}, error) {
+		mu.Lock()
+		batches = append(batches, ids)
+		mu.Unlock()
+		values := map[int64]interface{}{}
+		for _, id := range ids {
+			if id != 3 {
+				values[id] = id * 10
+			}
+		}
+		return values, nil
+	})
+
+	var wg sync.WaitGroup
+	for _, id := range []int64{1, 2, 1, 3} {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			v, err := l.Load(id)
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if id == 3 && v != nil {
+				t.Errorf("expected no value for a missing ID, got %v", v)
+			}
+			if id != 3 && v != id*10 {
+				t.Errorf("expected %d, got %v", id*10, v)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Errorf("expected a single batch of 3 IDs, got %v", batches)
+	}
+	if v, _ := l.Load(2); v != int64(20) || len(batches) != 1 {
+		t.Errorf("expected a cached value, got %v after %d batches", v, len(batches))
+	}
+}
+
+func TestLoaderDoesNotCacheErrors(t *testing.T) {
+	calls := 0
+	l := NewLoader(func(ids []int64) (map[int64]interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return map[int64]interface{}{1: "one"}, nil
+	})
+	if _, err := l.Load(1); err == nil {
+		t.Error("expected the fetch error")
+	}
+	if v, err := l.Load(1); err != nil || v != "one" {
+		t.Errorf("expected the load to be retried, got %v %v", v, err)
+	}
+}
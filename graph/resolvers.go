@@ -0,0 +1,471 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the models functions resolvers read from, replaced in tests
+var (
+	getUsers      = models.GetUsersByIDs
+	getSegments   = models.GetSegmentsByIDs
+	getChallenge  = models.GetChallengeByID
+	getChallenges = models.GetChallenges
+)
+
+// maxChallenges caps the challenges of a user returned by one query
+const maxChallenges = 100
+
+type contextKey struct{}
+
+// requestContext holds the viewer and the loaders of a single query
+type requestContext struct {
+	viewer   *models.User
+	users    *Loader
+	segments *Loader
+}
+
+// NewContext returns ctx carrying the authenticated viewer and fresh loaders for one query
+func NewContext(ctx context.Context, viewer *models.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestContext{
+		viewer: viewer,
+		users: NewLoader(func(ids []int64) (map[int64]interface{}, error) {
+			users, err := getUsers(ids)
+			values := map[int64]interface{}{}
+			for i := range users {
+				values[users[i].ID] = &users[i]
+			}
+			return values, err
+		}),
+		segments: NewLoader(func(ids []int64) (map[int64]interface{}, error) {
+			segments, err := getSegments(ids)
+			values := map[int64]interface{}{}
+			for i := range segments {
+				values[segments[i].ID] = &segments[i]
+			}
+			return values, err
+		}),
+	})
+}
+
+func fromContext(ctx context.Context) *requestContext {
+	return ctx.Value(contextKey{}).(*requestContext)
+}
+
+// loadUser returns the stored user with id through the query's user loader, nil when there is none.
+// The user is reduced to what their privacy setting shows the viewer.
+func loadUser(ctx context.Context, id int64) (*userResolver, error) {
+	v, err := fromContext(ctx).users.Load(id)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	viewer := fromContext(ctx).viewer
+	u := v.(*models.User)
+	view := u.ViewFor(viewer)
+	return &userResolver{u: &view, visible: u.VisibleTo(viewer)}, nil
+}
+
+// challengeVisible reports whether the viewer takes part in c or both opponents share their
+// challenge history with them
+func challengeVisible(ctx context.Context, c *models.Challenge) (bool, error) {
+	viewer := fromContext(ctx).viewer
+	for _, o := range []*models.Opponent{c.Challenger, c.Challengee} {
+		if o != nil && viewer != nil && o.ID == viewer.ID {
+			return true, nil
+		}
+	}
+	for _, o := range []*models.Opponent{c.Challenger, c.Challengee} {
+		if o == nil {
+			continue
+		}
+		u, err := loadUser(ctx, o.ID)
+		if err != nil {
+			return false, err
+		}
+		if u != nil && !u.visible {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// visibleChallenges returns the challenges the viewer may see, they are checked concurrently
+// so the opponents are fetched in a single batch
+func visibleChallenges(ctx context.Context, challenges []models.Challenge) ([]models.Challenge, error) {
+	visible := make([]bool, len(challenges))
+	errs := make([]error, len(challenges))
+	var wg sync.WaitGroup
+	for i := range challenges {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			visible[i], errs[i] = challengeVisible(ctx, &challenges[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var kept []models.Challenge
+	for i := range challenges {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if visible[i] {
+			kept = append(kept, challenges[i])
+		}
+	}
+	return kept, nil
+}
+
+// parseID reads a numeric user or segment ID
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, models.InvalidError("id must be a number", map[string]string{"id": string(id)})
+	}
+	return n, nil
+}
+
+func idOf(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optionalInt(n *int) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
+
+func timeOf(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+// Resolver resolves the root query
+type Resolver struct{}
+
+// Viewer returns the user the access token was issued to
+func (r *Resolver) Viewer(ctx context.Context) *userResolver {
+	return &userResolver{u: fromContext(ctx).viewer, visible: true}
+}
+
+// User returns a user by ID
+func (r *Resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return loadUser(ctx, id)
+}
+
+// Segment returns a stored segment by ID, nil when Strava restricts it as it does for a missing one
+func (r *Resolver) Segment(ctx context.Context, args struct{ ID graphql.ID }) (*segmentResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	segment, err := (&segmentResolver{id: id}).detail(ctx)
+	if err != nil || segment == nil || segment.Restricted() {
+		return nil, err
+	}
+	return &segmentResolver{id: id, segment: segment}, nil
+}
+
+// Challenge returns a challenge by ID, nil when the viewer may not see it
+func (r *Resolver) Challenge(ctx context.Context, args struct{ ID graphql.ID }) (*challengeResolver, error) {
+	if !bson.IsObjectIdHex(string(args.ID)) {
+		return nil, models.InvalidError("id must be an Object ID", map[string]string{"id": string(args.ID)})
+	}
+	c, err := getChallenge(bson.ObjectIdHex(string(args.ID)))
+	if models.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := challengeVisible(ctx, c); !ok || err != nil {
+		return nil, err
+	}
+	return &challengeResolver{*c}, nil
+}
+
+// userResolver resolves the view of a user, visible is false when their privacy setting
+// hides their profile and challenge history from the viewer
+type userResolver struct {
+	u       *models.User
+	visible bool
+}
+
+func (r *userResolver) ID() graphql.ID        { return idOf(r.u.ID) }
+func (r *userResolver) FirstName() string     { return r.u.FirstName }
+func (r *userResolver) LastName() string      { return r.u.LastName }
+func (r *userResolver) FullName() string      { return r.u.FullName }
+func (r *userResolver) Photo() string         { return r.u.Photo }
+func (r *userResolver) City() string          { return r.u.City }
+func (r *userResolver) State() string         { return r.u.State }
+func (r *userResolver) Country() string       { return r.u.Country }
+func (r *userResolver) Wins() int32           { return int32(r.u.Wins) }
+func (r *userResolver) Losses() int32         { return int32(r.u.Losses) }
+func (r *userResolver) ChallengeCount() int32 { return int32(r.u.ChallengeCount) }
+func (r *userResolver) TimeZone() *string     { return optionalString(r.u.TimeZone) }
+
+func (r *userResolver) Friends() []*friendResolver {
+	friends := make([]*friendResolver, 0, len(r.u.Friends))
+	for _, f := range r.u.Friends {
+		friends = append(friends, &friendResolver{f})
+	}
+	return friends
+}
+
+func (r *userResolver) Segments(args struct{ ActivityType *string }) []*segmentResolver {
+	segments := make([]*segmentResolver, 0, len(r.u.Segments))
+	for _, s := range r.u.Segments {
+		if args.ActivityType == nil || s.ActivityType == *args.ActivityType {
+			segments = append(segments, &segmentResolver{id: s.ID, summary: s})
+		}
+	}
+	return segments
+}
+
+type challengesArgs struct {
+	Status       *string
+	Opponent     *graphql.ID
+	Segment      *graphql.ID
+	ActivityType *string
+	First        int32
+}
+
+func (r *userResolver) Challenges(ctx context.Context, args challengesArgs) ([]*challengeResolver, error) {
+	if !r.visible {
+		return nil, models.ForbiddenError(fmt.Sprintf("user %d does not share their challenge history with you", r.u.ID))
+	}
+	q := models.ChallengeQuery{UserID: r.u.ID, Limit: maxChallenges}
+	if args.Status != nil {
+		q.Status = strings.ToLower(*args.Status)
+	}
+	if args.ActivityType != nil {
+		q.ActivityType = *args.ActivityType
+	}
+	var err error
+	if args.Opponent != nil {
+		if q.OpponentID, err = parseID(*args.Opponent); err != nil {
+			return nil, err
+		}
+	}
+	if args.Segment != nil {
+		if q.SegmentID, err = parseID(*args.Segment); err != nil {
+			return nil, err
+		}
+	}
+	if args.First > 0 && args.First < maxChallenges {
+		q.Limit = int(args.First)
+	}
+
+	challenges, _, err := getChallenges(q)
+	if err != nil {
+		return nil, err
+	}
+	// the other opponent's privacy setting hides the challenge too
+	if challenges, err = visibleChallenges(ctx, challenges); err != nil {
+		return nil, err
+	}
+	resolvers := make([]*challengeResolver, 0, len(challenges))
+	for _, c := range challenges {
+		resolvers = append(resolvers, &challengeResolver{c})
+	}
+	return resolvers, nil
+}
+
+type friendResolver struct {
+	f *models.Friend
+}
+
+func (r *friendResolver) ID() graphql.ID        { return idOf(r.f.ID) }
+func (r *friendResolver) FirstName() string     { return r.f.FirstName }
+func (r *friendResolver) LastName() string      { return r.f.LastName }
+func (r *friendResolver) FullName() string      { return r.f.FullName }
+func (r *friendResolver) Photo() string         { return r.f.Photo }
+func (r *friendResolver) ChallengeCount() int32 { return int32(r.f.ChallengeCount) }
+func (r *friendResolver) Wins() int32           { return int32(r.f.Wins) }
+func (r *friendResolver) Losses() int32         { return int32(r.f.Losses) }
+
+func (r *friendResolver) User(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, r.f.ID)
+}
+
+// segmentResolver resolves a segment from a users segment list, a challenge or the segments collection,
+// the details missing from the first two are loaded in batches
+type segmentResolver struct {
+	id      int64
+	summary *models.UserSegment
+	segment *models.Segment
+}
+
+func (r *segmentResolver) detail(ctx context.Context) (*models.Segment, error) {
+	if r.segment != nil {
+		return r.segment, nil
+	}
+	// sibling fields resolve concurrently, the loader caches the segment for all of them
+	v, err := fromContext(ctx).segments.Load(r.id)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return v.(*models.Segment), nil
+}
+
+func (r *segmentResolver) ID() graphql.ID { return idOf(r.id) }
+
+func (r *segmentResolver) Name() string {
+	if r.summary != nil {
+		return r.summary.Name
+	}
+	return r.segment.Name
+}
+
+func (r *segmentResolver) ActivityType() string {
+	if r.summary != nil {
+		return r.summary.ActivityType
+	}
+	return r.segment.ActivityType
+}
+
+func (r *segmentResolver) EffortCount() *int32 {
+	if r.summary == nil {
+		return nil
+	}
+	n := int32(r.summary.Count)
+	return &n
+}
+
+// float resolves a numeric detail of the segment
+func (r *segmentResolver) float(ctx context.Context, field func(*models.Segment) float64) (*float64, error) {
+	s, err := r.detail(ctx)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	v := field(s)
+	return &v, nil
+}
+
+func (r *segmentResolver) Distance(ctx context.Context) (*float64, error) {
+	return r.float(ctx, func(s *models.Segment) float64 { return s.Distance })
+}
+
+func (r *segmentResolver) AverageGrade(ctx context.Context) (*float64, error) {
+	return r.float(ctx, func(s *models.Segment) float64 { return s.AverageGrade })
+}
+
+func (r *segmentResolver) MaximumGrade(ctx context.Context) (*float64, error) {
+	return r.float(ctx, func(s *models.Segment) float64 { return s.MaximumGrade })
+}
+
+func (r *segmentResolver) ElevationHigh(ctx context.Context) (*float64, error) {
+	return r.float(ctx, func(s *models.Segment) float64 { return s.ElevationHigh })
+}
+
+func (r *segmentResolver) ElevationLow(ctx context.Context) (*float64, error) {
+	return r.float(ctx, func(s *models.Segment) float64 { return s.ElevationLow })
+}
+
+func (r *segmentResolver) ClimbCategory(ctx context.Context) (*int32, error) {
+	s, err := r.detail(ctx)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	v := int32(s.ClimbCategory)
+	return &v, nil
+}
+
+// text resolves a location detail of the segment
+func (r *segmentResolver) text(ctx context.Context, field func(*models.Segment) string) (*string, error) {
+	s, err := r.detail(ctx)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	v := field(s)
+	return &v, nil
+}
+
+func (r *segmentResolver) City(ctx context.Context) (*string, error) {
+	return r.text(ctx, func(s *models.Segment) string { return s.City })
+}
+
+func (r *segmentResolver) State(ctx context.Context) (*string, error) {
+	return r.text(ctx, func(s *models.Segment) string { return s.State })
+}
+
+func (r *segmentResolver) Country(ctx context.Context) (*string, error) {
+	return r.text(ctx, func(s *models.Segment) string { return s.Country })
+}
+
+type challengeResolver struct {
+	c models.Challenge
+}
+
+func (r *challengeResolver) ID() graphql.ID { return graphql.ID(r.c.ID.Hex()) }
+func (r *challengeResolver) Status() string { return r.c.Status }
+func (r *challengeResolver) Expired() bool  { return r.c.Expired }
+
+func (r *challengeResolver) TimeZone() *string { return optionalString(r.c.TimeZone) }
+
+func (r *challengeResolver) Segment() *segmentResolver {
+	if r.c.Segment == nil {
+		return nil
+	}
+	return &segmentResolver{id: r.c.Segment.ID, segment: r.c.Segment}
+}
+
+func (r *challengeResolver) Challenger() *opponentResolver { return &opponentResolver{r.c.Challenger} }
+func (r *challengeResolver) Challengee() *opponentResolver { return &opponentResolver{r.c.Challengee} }
+
+func (r *challengeResolver) Created() *graphql.Time   { return timeOf(r.c.Created) }
+func (r *challengeResolver) Expires() *graphql.Time   { return timeOf(r.c.Expires) }
+func (r *challengeResolver) Completed() *graphql.Time { return timeOf(r.c.Completed) }
+
+func (r *challengeResolver) Winner() *opponentResolver {
+	switch {
+	case r.c.WinnerID == nil:
+		return nil
+	case r.c.Challenger != nil && r.c.Challenger.ID == *r.c.WinnerID:
+		return &opponentResolver{r.c.Challenger}
+	case r.c.Challengee != nil && r.c.Challengee.ID == *r.c.WinnerID:
+		return &opponentResolver{r.c.Challengee}
+	}
+	return nil
+}
+
+type opponentResolver struct {
+	o *models.Opponent
+}
+
+func (r *opponentResolver) ID() graphql.ID             { return idOf(r.o.ID) }
+func (r *opponentResolver) Name() string               { return r.o.Name }
+func (r *opponentResolver) Photo() string              { return r.o.Photo }
+func (r *opponentResolver) Completed() bool            { return r.o.Completed }
+func (r *opponentResolver) Time() *int32               { return optionalInt(r.o.Time) }
+func (r *opponentResolver) AverageCadence() *float64   { return r.o.AverageCadence }
+func (r *opponentResolver) AverageWatts() *float64     { return r.o.AverageWatts }
+func (r *opponentResolver) AverageHeartRate() *float64 { return r.o.AverageHeartRate }
+func (r *opponentResolver) MaxHeartRate() *float64     { return r.o.MaxHeartRate }
+
+func (r *opponentResolver) User(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, r.o.ID)
+}
@@ -0,0 +1,268 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// stubModels replaces the models functions and records the batches requested from them
+func stubModels(t *testing.T) (userBatches, segmentBatches *[][]int64, restore func()) {
+	var mu sync.Mutex
+	userBatches, segmentBatches = &[][]int64{}, &[][]int64{}
+	stored := map[int64]models.User{
+		2: {ID: 2, FullName: "Alex Rider", Wins: 4},
+		3: {ID: 3, FullName: "Bo Runner"},
+		5: {ID: 5, FullName: "Cy Private", City: "Oakland", Friends: []*models.Friend{{ID: 2}}, Settings: &models.UserSettings{Privacy: models.PrivacyPrivate}},
+	}
+	getUsers = func(ids []int64) ([]models.User, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*userBatches = append(*userBatches, ids)
+		var users []models.User
+		for _, id := range ids {
+			if u, ok := stored[id]; ok {
+				users = append(users, u)
+			}
+		}
+		return users, nil
+	}
+	getSegments = func(ids []int64) ([]models.Segment, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*segmentBatches = append(*segmentBatches, ids)
+		var segments []models.Segment
+		for _, id := range ids {
+			segments = append(segments, models.Segment{ID: id, Distance: float64(id) * 100})
+		}
+		return segments, nil
+	}
+	expires := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+	winner := int64(2)
+	getChallenges = func(q models.ChallengeQuery) ([]models.Challenge, int, error) {
+		if q.UserID != 1 || q.Status != "completed" || q.Limit != 5 {
+			t.Errorf("unexpected challenge query %+v", q)
+		}
+		return []models.Challenge{{
+			ID:         bson.NewObjectId(),
+			Segment:    &models.Segment{ID: 30, Name: "Hawk Hill", Distance: 2400},
+			Challenger: &models.Opponent{ID: 1, Name: "Viewer"},
+			Challengee: &models.Opponent{ID: 2, Name: "Alex Rider"},
+			Status:     "complete",
+			Expires:    &expires,
+			WinnerID:   &winner,
+		}}, 1, nil
+	}
+	restore = func() {
+		getUsers, getSegments, getChallenges = models.GetUsersByIDs, models.GetSegmentsByIDs, models.GetChallenges
+	}
+	return userBatches, segmentBatches, restore
+}
+
+func TestViewerQueryBatchesLookups(t *testing.T) {
+	userBatches, segmentBatches, restore := stubModels(t)
+	defer restore()
+	viewer := &models.User{
+		ID:       1,
+		FullName: "Viewer",
+		Token:    "secret",
+		Friends:  []*models.Friend{{ID: 2, FullName: "Alex Rider"}, {ID: 3, FullName: "Bo Runner"}, {ID: 4, FullName: "Not Signed Up"}},
+		Segments: []*models.UserSegment{{ID: 10, Name: "Hawk Hill", ActivityType: "Ride", Count: 3}, {ID: 20, Name: "Lake Loop", ActivityType: "Run"}},
+	}
+
+	query := `{
+		viewer {
+			fullName
+			friends { fullName user { wins } }
+			segments(activityType: "Ride") { name effortCount distance }
+			challenges(status: COMPLETED, first: 5) {
+				expires
+				segment { name distance }
+				winner { name user { fullName } }
+			}
+		}
+	}`
+	resp := NewSchema().Exec(NewContext(context.Background(), viewer), query, "", nil)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", resp.Errors)
+	}
+
+	var data struct {
+		Viewer struct {
+			FullName string
+			Friends  []struct {
+				FullName string
+				User     *struct{ Wins int }
+			}
+			Segments []struct {
+				Name        string
+				EffortCount int
+				Distance    float64
+			}
+			Challenges []struct {
+				Expires string
+				Segment struct {
+					Name     string
+					Distance float64
+				}
+				Winner struct {
+					Name string
+					User struct{ FullName string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("unable to decode data: %s", err)
+	}
+	v := data.Viewer
+	if len(v.Friends) != 3 || v.Friends[0].User == nil || v.Friends[0].User.Wins != 4 || v.Friends[2].User != nil {
+		t.Errorf("unexpected friends %+v", v.Friends)
+	}
+	if len(v.Segments) != 1 || v.Segments[0].EffortCount != 3 || v.Segments[0].Distance != 1000 {
+		t.Errorf("unexpected segments %+v", v.Segments)
+	}
+	if len(v.Challenges) != 1 || v.Challenges[0].Segment.Distance != 2400 || v.Challenges[0].Winner.User.FullName != "Alex Rider" {
+		t.Errorf("unexpected challenges %+v", v.Challenges)
+	}
+	if v.Challenges[0].Expires != "2018-06-04T00:00:00Z" {
+		t.Errorf("unexpected expires %s", v.Challenges[0].Expires)
+	}
+
+	// friends and the winner share one user batch, the challenge segment is embedded
+	if len(*userBatches) != 1 || len((*userBatches)[0]) != 3 {
+		t.Errorf("expected a single batch of 3 users, got %v", *userBatches)
+	}
+	if len(*segmentBatches) != 1 || len((*segmentBatches)[0]) != 1 {
+		t.Errorf("expected a single batch of 1 segment, got %v", *segmentBatches)
+	}
+}
+
+func TestQueryDoesNotExposeToken(t *testing.T) {
+	_, _, restore := stubModels(t)
+	defer restore()
+	resp := NewSchema().Exec(NewContext(context.Background(), &models.User{ID: 1}), `{ viewer { token } }`, "", nil)
+	if len(resp.Errors) == 0 {
+		t.Error("expected the token field to be rejected")
+	}
+}
+
+func TestQueryDepthIsLimited(t *testing.T) {
+	_, _, restore := stubModels(t)
+	defer restore()
+	ctx := NewContext(context.Background(), &models.User{ID: 1})
+
+	query := `{ viewer { friends { user { friends { user { friends { user { fullName } } } } } } } }`
+	resp := NewSchema().Exec(ctx, query, "", nil)
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "exceeds max depth") {
+		t.Errorf("expected a query nested deeper than the limit to be rejected, got %v", resp.Errors)
+	}
+	if resp.Data != nil {
+		t.Errorf("expected the query not to be executed, got %s", resp.Data)
+	}
+}
+
+func TestQueryRespectsPrivacy(t *testing.T) {
+	_, _, restore := stubModels(t)
+	defer restore()
+	ctx := NewContext(context.Background(), &models.User{ID: 1})
+
+	resp := NewSchema().Exec(ctx, `{ user(id: "5") { fullName city friends { id } } }`, "", nil)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", resp.Errors)
+	}
+	var data struct {
+		User struct {
+			FullName string
+			City     string
+			Friends  []struct{ ID string }
+		}
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("unable to decode data: %s", err)
+	}
+	if data.User.FullName != "Cy Private" || data.User.City != "" || len(data.User.Friends) != 0 {
+		t.Errorf("expected only the name of a private user, got %+v", data.User)
+	}
+
+	resp = NewSchema().Exec(ctx, `{ user(id: "5") { challenges { id } } }`, "", nil)
+	if len(resp.Errors) == 0 {
+		t.Error("expected the challenges of a private user to be rejected")
+	}
+}
+
+func TestQueryHidesChallengesWithPrivateOpponents(t *testing.T) {
+	userBatches, _, restore := stubModels(t)
+	defer restore()
+	public, private := bson.NewObjectId(), bson.NewObjectId()
+	getChallenges = func(q models.ChallengeQuery) ([]models.Challenge, int, error) {
+		return []models.Challenge{
+			{ID: public, Challenger: &models.Opponent{ID: 2}, Challengee: &models.Opponent{ID: 3}},
+			{ID: private, Challenger: &models.Opponent{ID: 5}, Challengee: &models.Opponent{ID: 2}},
+		}, 2, nil
+	}
+
+	var data struct {
+		User struct{ Challenges []struct{ ID string } }
+	}
+	for _, tc := range []struct {
+		viewer *models.User
+		exp    []bson.ObjectId
+	}{
+		{nil, []bson.ObjectId{public}},
+		{&models.User{ID: 3}, []bson.ObjectId{public}},
+		{&models.User{ID: 5}, []bson.ObjectId{public, private}},
+	} {
+		*userBatches = nil
+		resp := NewSchema().Exec(NewContext(context.Background(), tc.viewer), `{ user(id: "2") { challenges { id } } }`, "", nil)
+		if len(resp.Errors) > 0 {
+			t.Fatalf("unexpected errors %v", resp.Errors)
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatalf("unable to decode data: %s", err)
+		}
+		var ids []bson.ObjectId
+		for _, c := range data.User.Challenges {
+			ids = append(ids, bson.ObjectIdHex(c.ID))
+		}
+		if len(ids) != len(tc.exp) || (len(ids) > 0 && ids[0] != tc.exp[0]) || (len(ids) > 1 && ids[1] != tc.exp[1]) {
+			t.Errorf("viewer %+v: expected challenges %v, got %v", tc.viewer, tc.exp, ids)
+		}
+		if len(*userBatches) > 2 {
+			t.Errorf("viewer %+v: expected the opponents in a single batch, got %v", tc.viewer, *userBatches)
+		}
+	}
+}
+
+func TestQueryHidesRestrictedSegments(t *testing.T) {
+	_, _, restore := stubModels(t)
+	defer restore()
+	getSegments = func(ids []int64) ([]models.Segment, error) {
+		var segments []models.Segment
+		for _, id := range ids {
+			segments = append(segments, models.Segment{ID: id, Name: "Hill", Private: id == 40, Hazardous: id == 50})
+		}
+		return segments, nil
+	}
+	ctx := NewContext(context.Background(), &models.User{ID: 1})
+
+	for id, exp := range map[string]bool{"30": true, "40": false, "50": false} {
+		resp := NewSchema().Exec(ctx, `{ segment(id: "`+id+`") { name } }`, "", nil)
+		if len(resp.Errors) > 0 {
+			t.Fatalf("unexpected errors %v", resp.Errors)
+		}
+		var data struct{ Segment *struct{ Name string } }
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatalf("unable to decode data: %s", err)
+		}
+		if (data.Segment != nil) != exp {
+			t.Errorf("segment %s: expected found %v, got %+v", id, exp, data.Segment)
+		}
+	}
+}
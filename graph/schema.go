@@ -0,0 +1,121 @@
+package graph
+
+import (
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// schema describes the users, segments and challenges served at /graphql,
+// it mirrors the JSON of the REST API without private fields such as tokens and emails
+const schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# the user the access token was issued to
+	viewer: User!
+	user(id: ID!): User
+	segment(id: ID!): Segment
+	challenge(id: ID!): Challenge
+}
+
+scalar Time
+
+enum ChallengeStatus {
+	PENDING
+	ACTIVE
+	COMPLETED
+}
+
+type User {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	fullName: String!
+	photo: String!
+	city: String!
+	state: String!
+	country: String!
+	wins: Int!
+	losses: Int!
+	challengeCount: Int!
+	timeZone: String
+	friends: [Friend!]!
+	segments(activityType: String): [Segment!]!
+	challenges(status: ChallengeStatus, opponent: ID, segment: ID, activityType: String, first: Int = 25): [Challenge!]!
+}
+
+type Friend {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	fullName: String!
+	photo: String!
+	challengeCount: Int!
+	wins: Int!
+	losses: Int!
+	# null when the friend has not signed up
+	user: User
+}
+
+type Segment {
+	id: ID!
+	name: String!
+	activityType: String!
+	# how often the user rode or ran the segment, only set on a users segments
+	effortCount: Int
+	# the details are null until the segment is stored
+	distance: Float
+	averageGrade: Float
+	maximumGrade: Float
+	elevationHigh: Float
+	elevationLow: Float
+	climbCategory: Int
+	city: String
+	state: String
+	country: String
+}
+
+type Challenge {
+	id: ID!
+	segment: Segment
+	challenger: Opponent!
+	challengee: Opponent!
+	status: String!
+	created: Time
+	expires: Time
+	completed: Time
+	expired: Boolean!
+	winner: Opponent
+	timeZone: String
+}
+
+type Opponent {
+	id: ID!
+	name: String!
+	photo: String!
+	completed: Boolean!
+	time: Int
+	averageCadence: Float
+	averageWatts: Float
+	averageHeartRate: Float
+	maxHeartRate: Float
+	user: User
+}
+`
+
+// query limits, friends and users refer to each other so a query could otherwise nest
+// without bound and fan out to every friend of every friend
+const (
+	maxQueryDepth = 6
+	// maxQueryParallelism caps the fields resolved concurrently for one query
+	maxQueryParallelism = 10
+)
+
+// NewSchema parses the schema with its resolvers, it panics when they do not match
+func NewSchema() *graphql.Schema {
+	return graphql.MustParseSchema(schema, &Resolver{},
+		graphql.MaxDepth(maxQueryDepth),
+		graphql.MaxParallelism(maxQueryParallelism),
+	)
+}
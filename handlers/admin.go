@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/utils"
+)
+
+// adminToken is the shared secret operators send as a bearer token to the operational routes,
+// the routes refuse every request when it is empty
+var adminToken = utils.GetEnvStringDefault("ADMIN_TOKEN", "")
+
+// errAdminUnauthorized is returned when a request to an operational route does not carry the admin token
+var errAdminUnauthorized = models.UnauthorizedError("missing or invalid admin token")
+
+// authenticateAdmin checks the request carries the admin token in its Authorization header
+func authenticateAdmin(r *http.Request) error {
+	h := r.Header.Get("Authorization")
+	if adminToken == "" || !strings.HasPrefix(h, "Bearer ") {
+		return errAdminUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(h, "Bearer ")), []byte(adminToken)) != 1 {
+		return errAdminUnauthorized
+	}
+	return nil
+}
+
+// AdminOnly serves the operational routes, job history, leases, quotas and syncs, to operators only.
+// They expose other users data and start work that spends the shared Strava quota.
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := authenticateAdmin(r); err != nil {
+			New(w).Error(err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
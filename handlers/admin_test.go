@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminOnly(t *testing.T) {
+	defer func(token string) { adminToken = token }(adminToken)
+	h := AdminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		configured string
+		header     string
+		status     int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"", "Bearer ", http.StatusUnauthorized},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tc := range tests {
+		adminToken = tc.configured
+		req := httptest.NewRequest("GET", "/api/v2/jobs/runs", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("token %q, header %q: expected status code %v, got: %v", tc.configured, tc.header, tc.status, w.Code)
+		}
+	}
+}
+
+// TestAdminRoutesRequireAdminToken tests every operational route is served through AdminOnly,
+// a user's access token is not enough
+func TestAdminRoutesRequireAdminToken(t *testing.T) {
+	defer func(token string) { adminToken = token }(adminToken)
+	adminToken = "secret"
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	for _, op := range apiOperations() {
+		if !op.admin {
+			continue
+		}
+		req, err := http.NewRequest(op.method, server.URL+op.path+"?access_token=user", nil)
+		if err != nil {
+			t.Fatal("unable to generate request", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected status code %v without the admin token, got: %v", op.method, op.path, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+}
+
+func TestAllUsersSyncIsNotServedOnGet(t *testing.T) {
+	defer func(token string) { adminToken = token }(adminToken)
+	adminToken = "secret"
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL+"/strava/update/users", nil)
+	if err != nil {
+		t.Fatal("unable to generate request", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("unable to send request", err)
+	}
+	resp.Body.Close()
+	if exp := http.StatusMethodNotAllowed; resp.StatusCode != exp {
+		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+	}
+}
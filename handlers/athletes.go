@@ -2,33 +2,32 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
-	"github.com/go-chi/chi"
 	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
 	log "github.com/sirupsen/logrus"
 	strava "github.com/strava/go.strava"
 )
 
 // UpdateAthleteFromStrava updates athlete information from Strava
-func UpdateAthleteFromStrava(numID int64) (*models.User, error) {
+func UpdateAthleteFromStrava(numID int64, p ratelimit.Priority) (*models.User, error) {
 	user, err := models.GetUserByID(numID)
 	if err != nil {
 		log.WithField("ID", numID).Error("unable to retrieve user from database")
 		return nil, err
 	}
 
-	client := strava.NewClient(user.Token)
+	client := newStravaClient(user.Token, p)
 
 	log.Info("Fetching athlete info...\n")
 	// retrieve athlete info from Strava API
 	athlete, err := strava.NewCurrentAthleteService(client).Get().Do()
 	if err != nil {
 		log.Error("Unable to retrieve athlete info from Strava")
-		return nil, err
+		return nil, stravaError(err)
 	}
-	log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
 	log.Infof("athlete %v retrieved from strava", athlete.Id)
 
 	u, err := user.UpdateAthlete(athlete)
@@ -39,83 +38,53 @@ func UpdateAthleteFromStrava(numID int64) (*models.User, error) {
 	return u, err
 }
 
-// GetAthleteByIDFromStrava returns the strava athlete with the specified ID
+// GetAthleteByIDFromStrava syncs the user from Strava with their own access token and returns them,
+// only the user may sync their profile
 func GetAthleteByIDFromStrava(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
-	id := chi.URLParam(r, "id")
 
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.Error("unable to convert ID param")
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to convert ID param",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
-	u, err := UpdateAthleteFromStrava(numID)
+	viewer, err := authenticateUser(r, numID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	u, err := UpdateAthleteFromStrava(numID, ratelimit.Interactive)
 	if err != nil {
 		log.Errorf("unable to update athlete %d from Strava", numID)
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to update athlete from Strava",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
-	res.Render(http.StatusOK, &u)
+	res.Render(http.StatusOK, u.ViewFor(viewer))
 }
 
-// UpdateAllUsersFromStrava returns all updated users from Strava
+// UpdateAllUsersFromStrava starts a background sync of all users from Strava
 func UpdateAllUsersFromStrava(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	// get all users
-	users, err := models.GetAllUsers()
-	if err != nil {
-		log.Error("unable to get users")
+	if jobRunner == nil {
+		log.Error("job runner not registered, unable to sync users")
+		res.Error(errJobsUnavailable)
 		return
 	}
-	// iterate over all users
-	for _, u := range users {
-		// remove friends and segments if older imported user
-		oldCreatedDate := time.Date(2017, 8, 29, 0, 0, 0, 0, time.UTC)
-		log.Infof("user %d created before %v", u.ID, oldCreatedDate)
-		oldUpdatedDate := time.Date(2017, 9, 9, 12, 0, 0, 0, time.UTC)
-		log.Infof("user %d updated before %v", u.ID, oldUpdatedDate)
-		if u.CreatedAt.Before(oldCreatedDate) && u.CreatedAt.Before(oldUpdatedDate) {
-			err := u.RemoveFriendsSegmentsFromUser()
-			if err != nil {
-				log.Errorf("unable to remove segments and friends from user %d:\n %v", u.ID, err)
-				return
-			}
-		}
-		// update athlete
-		_, err = UpdateAthleteFromStrava(u.ID)
-		if err != nil {
-			log.Errorf("unable to update user %d", u.ID)
-			return
-		}
-		// update athlete friends
-		_, err = GetFriendsFromStrava(u.ID)
-		if err != nil {
-			log.Errorf("unable to update user %d friends", u.ID)
-			return
-		}
-		// update athlete segments
-		_, err = GetUserSegmentsFromStrava(u.ID, 1)
-		if err != nil {
-			log.Errorf("unable to update user %d segments", u.ID)
-			return
-		}
+	job, err := jobRunner.Enqueue(SyncAllUsersJob, nil)
+	if err != nil {
+		log.WithError(err).Error("unable to enqueue user sync")
+		res.Error(err)
+		return
 	}
-	log.Info("all users updated successfully")
-	res.Render(http.StatusOK, "all users updated successfully")
+	log.WithField("JOB ID", job.ID).Info("user sync enqueued")
+	res.Render(http.StatusAccepted, job)
 }
 
 // GetFriendsFromStrava gets a users friends from Strava
-func GetFriendsFromStrava(numID int64) (friends []*models.Friend, err error) {
+func GetFriendsFromStrava(numID int64, p ratelimit.Priority) (friends []*models.Friend, err error) {
 	// get user based on ID
 	user, err := models.GetUserByID(numID)
 	if err != nil {
@@ -130,15 +99,14 @@ func GetFriendsFromStrava(numID int64) (friends []*models.Friend, err error) {
 		friendMap[friend.ID] = friend
 	}
 
-	client := strava.NewClient(user.Token)
+	client := newStravaClient(user.Token, p)
 
 	log.Info("Fetching athlete friends info...\n")
 	// retrieve a list of users friends from Strava API
 	stravaFriends, err := strava.NewCurrentAthleteService(client).ListFriends().Do()
 	if err != nil {
-		return nil, err
+		return nil, stravaError(err)
 	}
-	log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
 	log.Infof("Finished fetching %v athlete friends from Strava...\n", len(stravaFriends))
 
 	// update friends map based upon strava friend data
@@ -192,26 +160,23 @@ func GetFriendsFromStrava(numID int64) (friends []*models.Friend, err error) {
 // GetFriendsByUserIDFromStrava returns a list of friends for a specific user by ID from strava
 func GetFriendsByUserIDFromStrava(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
-	id := chi.URLParam(r, "id")
 
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert ID param")
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to convert ID param",
-			"stack": err,
-		})
+		res.Error(err)
+		return
+	}
+
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
 		return
 	}
 
 	// Get users friends from strava and save to DB
-	friends, err := GetFriendsFromStrava(numID)
+	friends, err := GetFriendsFromStrava(numID, ratelimit.Interactive)
 	if err != nil {
 		log.WithField("USER ID", numID).Errorf("unable to retrieve user %v friends from strava", numID)
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to retrieve users friends from strava",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
@@ -220,7 +185,8 @@ func GetFriendsByUserIDFromStrava(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetUserSegmentsFromStrava gets a users recently completed segments from Strava
-func GetUserSegmentsFromStrava(numID int64, page int) (userSegmentSlice []*models.UserSegment, err error) {
+// only activities newer than the users activity cursor are requested and each activity is read once
+func GetUserSegmentsFromStrava(numID int64, p ratelimit.Priority) (userSegmentSlice []*models.UserSegment, err error) {
 	// find user by numID to retrieve strava token
 	user, err := models.GetUserByID(numID)
 	if err != nil {
@@ -235,107 +201,61 @@ func GetUserSegmentsFromStrava(numID int64, page int) (userSegmentSlice []*model
 	}
 
 	// create new strava client with user token
-	client := strava.NewClient(user.Token)
+	client := newStravaClient(user.Token, p)
 
 	log.Info("Fetching starred segments from Strava...\n")
 	starred, err := strava.NewCurrentAthleteService(client).ListStarredSegments().Do()
 	if err != nil {
-		return nil, err
+		return nil, stravaError(err)
 	}
 
+	fetch := stravaFetcher(client)
 	// range over a users starred segments
 	// to obtain segment details to cache
 	for _, seg := range starred {
 		log.Infof("segment %v was starred by user", seg.Id)
-		log.WithField("SEGMENT", seg.Name).Info("segment effort from activity detail")
-		// check if segment is in database
-		segment, err := models.GetSegmentByID(seg.Id)
+		segment, err := segmentCache.Get(seg.Id, fetch)
 		if err != nil {
-			// segment not found, make request to strava
-			log.WithField("SEGMENT ID", seg.Id).Infof("segment %v not found in database... saving", seg.Id)
-			segmentDetail, err := strava.NewSegmentsService(client).Get(seg.Id).Do()
-			if err != nil {
-				log.WithFields(log.Fields{
-					"SEGMENT NAME": seg.Name,
-					"SEGMENT ID":   seg.Id,
-				}).Errorf("unable to retrieve segment detail for %d %s", seg.Id, seg.Name)
-				return nil, err
-			}
-			log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
-			log.WithField("SEGMENT DETAIL ID", segmentDetail.Id).Infof("segment %d returned from strava", segmentDetail.Id)
-			saved, err := models.SaveSegment(segmentDetail)
-			if err != nil {
-				log.WithError(err).Errorf("unable to save segment detail %d to database", segmentDetail.Id)
-				return nil, err
-			}
-			log.WithField("SEGMENT ID", saved.ID).Infof("segment %d stored in DB", saved.ID)
-
-			// store saved segment in userSegments map
-			userSegments[saved.ID] = &models.UserSegment{
-				ID:           saved.ID,
-				Name:         saved.Name,
-				ActivityType: saved.ActivityType,
-				Count:        0,
-			}
-
-		} else if time.Now().After(segment.UpdatedAt.AddDate(0, 0, 7)) {
-			// update segment in DB if segment data is stale
-			// this is required by Strava API license agreement
-			log.WithField("SEGMENT ID", seg.Id).Infof("segment %v is greater than 7 days old... updating", seg.Id)
-			segmentDetail, err := strava.NewSegmentsService(client).Get(seg.Id).Do()
-			if err != nil {
-				log.WithFields(log.Fields{
-					"SEGMENT NAME": seg.Name,
-					"SEGMENT ID":   seg.Id,
-				}).Errorf("unable to retrieve segment detail for %d %s", seg.Id, seg.Name)
-				return nil, err
-			}
-			log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
-			log.WithField("SEGMENT DETAIL ID", segmentDetail.Id).Infof("segment %d returned from strava", segmentDetail.Id)
-			updated, err := segment.UpdateSegment(segmentDetail)
-			if err != nil {
-				log.WithError(err).Errorf("unable to save segment detail %d to database", segmentDetail.Id)
-				return nil, err
-			}
-			log.WithField("SEGMENT ID", updated.ID).Infof("segment %d updated in DB", updated.ID)
-
-			count := 0
-			if segment, ok := userSegments[segment.ID]; ok {
-				count = segment.Count
-			}
-			// store updated segment in userSegments map
-			userSegments[updated.ID] = &models.UserSegment{
-				ID:           updated.ID,
-				Name:         updated.Name,
-				ActivityType: updated.ActivityType,
-				Count:        count,
-			}
-		} else {
-			count := 0
-			if segment, ok := userSegments[segment.ID]; ok {
-				count = segment.Count
-			}
-			// segment was found and returned
-			// add segment to userSegments
-			userSegments[segment.ID] = &models.UserSegment{
-				ID:           segment.ID,
-				Name:         segment.Name,
-				ActivityType: segment.ActivityType,
-				Count:        count,
-			}
+			log.WithFields(log.Fields{
+				"SEGMENT NAME": seg.Name,
+				"SEGMENT ID":   seg.Id,
+			}).Errorf("unable to retrieve segment detail for %d %s", seg.Id, seg.Name)
+			return nil, err
 		}
+		addUserSegment(userSegments, segment)
 	}
 
-	log.Info("Fetching athlete activities from Strava...\n")
-	activities, err := strava.NewCurrentAthleteService(client).ListActivities().Page(1).PerPage(page).Do()
+	state, err := models.GetUserSync(user.ID)
 	if err != nil {
 		return nil, err
 	}
-	log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
-	log.Infof("Finished fetching %v athlete activities from Strava...\n", len(activities))
+	activities, err := listNewActivities(client, state.ActivityCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip activities whose segment efforts were read on an earlier sync
+	ids := make([]int64, 0, len(activities))
+	for _, activitySummary := range activities {
+		ids = append(ids, activitySummary.Id)
+	}
+	processed, err := models.GetProcessedActivityIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor time.Time
+	var newlyProcessed []models.ProcessedActivity
 	// range over activity summary to get activity details
 	// the activity summary does not contain segment effort information
 	for _, activitySummary := range activities {
+		if activitySummary.StartDate.After(cursor) {
+			cursor = activitySummary.StartDate
+		}
+		if processed[activitySummary.Id] {
+			log.WithField("ID", activitySummary.Id).Debug("activity already processed")
+			continue
+		}
 		log.WithFields(log.Fields{
 			"NAME": activitySummary.Name,
 			"ID":   activitySummary.Id,
@@ -345,93 +265,31 @@ func GetUserSegmentsFromStrava(numID int64, page int) (userSegmentSlice []*model
 		activityDetail, err := strava.NewActivitiesService(client).Get(activitySummary.Id).IncludeAllEfforts().Do()
 		if err != nil {
 			log.WithFields(log.Fields{
-				"NAME": activityDetail.Name,
-				"ID":   activityDetail.Id,
+				"NAME": activitySummary.Name,
+				"ID":   activitySummary.Id,
 			}).Errorf("unable to retrieve activity detail: \n%v", err)
-			return nil, err
+			return nil, stravaError(err)
 		}
-		log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
+		newlyProcessed = append(newlyProcessed, models.ProcessedActivity{
+			ID:          activityDetail.Id,
+			UserID:      user.ID,
+			StartDate:   activityDetail.StartDate,
+			ProcessedAt: time.Now(),
+		})
 
 		// range over segment efforts from the activity detail
 		// to obtain segment details to cache
 		for _, effort := range activityDetail.SegmentEfforts {
 			log.WithField("SEGMENT", effort.Segment.Name).Info("segment effort from activity detail")
-			// check if segment is in database
-			segment, err := models.GetSegmentByID(effort.Segment.Id)
+			segment, err := segmentCache.Get(effort.Segment.Id, fetch)
 			if err != nil {
-				// segment not found, make request to strava
-				log.WithField("SEGMENT ID", effort.Segment.Id).Infof("segment %v not found in database... saving", effort.Segment.Id)
-				segmentDetail, err := strava.NewSegmentsService(client).Get(effort.Segment.Id).Do()
-				if err != nil {
-					log.WithFields(log.Fields{
-						"SEGMENT NAME": effort.Segment.Name,
-						"SEGMENT ID":   effort.Segment.Id,
-					}).Errorf("unable to retrieve segment detail for %d %s", effort.Segment.Id, effort.Segment.Name)
-					return nil, err
-				}
-				log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
-				log.WithField("SEGMENT DETAIL ID", segmentDetail.Id).Infof("segment %d returned from strava", segmentDetail.Id)
-				saved, err := models.SaveSegment(segmentDetail)
-				if err != nil {
-					log.WithError(err).Errorf("unable to save segment detail %d to database", segmentDetail.Id)
-					return nil, err
-				}
-				log.WithField("SEGMENT ID", saved.ID).Infof("segment %d stored in DB", saved.ID)
-
-				// store saved segment in userSegments map
-				userSegments[saved.ID] = &models.UserSegment{
-					ID:           saved.ID,
-					Name:         saved.Name,
-					ActivityType: saved.ActivityType,
-					Count:        0,
-				}
-
-			} else if time.Now().After(segment.UpdatedAt.AddDate(0, 0, 7)) {
-				// update segment in DB if segment data is stale
-				// this is required by Strava API license agreement
-				log.WithField("SEGMENT ID", effort.Segment.Id).Infof("segment %v is greater than 7 days old... updating", effort.Segment.Id)
-				segmentDetail, err := strava.NewSegmentsService(client).Get(effort.Segment.Id).Do()
-				if err != nil {
-					log.WithFields(log.Fields{
-						"SEGMENT NAME": effort.Segment.Name,
-						"SEGMENT ID":   effort.Segment.Id,
-					}).Errorf("unable to retrieve segment detail for %d %s", effort.Segment.Id, effort.Segment.Name)
-					return nil, err
-				}
-				log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
-				log.WithField("SEGMENT DETAIL ID", segmentDetail.Id).Infof("segment %d returned from strava", segmentDetail.Id)
-				updated, err := segment.UpdateSegment(segmentDetail)
-				if err != nil {
-					log.WithError(err).Errorf("unable to save segment detail %d to database", segmentDetail.Id)
-					return nil, err
-				}
-				log.WithField("SEGMENT ID", updated.ID).Infof("segment %d updated in DB", updated.ID)
-
-				count := 0
-				if segment, ok := userSegments[segment.ID]; ok {
-					count = segment.Count
-				}
-				// store updated segment in userSegments map
-				userSegments[updated.ID] = &models.UserSegment{
-					ID:           updated.ID,
-					Name:         updated.Name,
-					ActivityType: updated.ActivityType,
-					Count:        count,
-				}
-			} else {
-				count := 0
-				if segment, ok := userSegments[segment.ID]; ok {
-					count = segment.Count
-				}
-				// segment was found and returned
-				// add segment to userSegments
-				userSegments[segment.ID] = &models.UserSegment{
-					ID:           segment.ID,
-					Name:         segment.Name,
-					ActivityType: segment.ActivityType,
-					Count:        count,
-				}
+				log.WithFields(log.Fields{
+					"SEGMENT NAME": effort.Segment.Name,
+					"SEGMENT ID":   effort.Segment.Id,
+				}).Errorf("unable to retrieve segment detail for %d %s", effort.Segment.Id, effort.Segment.Name)
+				return nil, err
 			}
+			addUserSegment(userSegments, segment)
 		}
 	}
 
@@ -439,52 +297,147 @@ func GetUserSegmentsFromStrava(numID int64, page int) (userSegmentSlice []*model
 		userSegmentSlice = append(userSegmentSlice, userSegment)
 	}
 
+	// keep the users time zone current from their latest activity unless they chose one
+	if zone := latestActivityTimeZone(activities); zone != "" && user.TimeZoneSource != models.TimeZoneFromUser && zone != user.TimeZone {
+		log.WithField("USER ID", user.ID).Infof("user %d time zone detected as %s", user.ID, zone)
+		if err := models.SaveUserTimeZone(user.ID, zone, models.TimeZoneFromStrava); err != nil {
+			log.WithError(err).Errorf("unable to save time zone for user %d", user.ID)
+			return nil, err
+		}
+		user.TimeZone = zone
+		user.TimeZoneSource = models.TimeZoneFromStrava
+	}
+
 	// store segment map for user
 	err = user.SaveUserSegments(userSegmentSlice)
 	if err != nil {
 		log.WithError(err).Errorf("unable to save user segments for user %d to database", user.ID)
 		return nil, err
 	}
+
+	// only record progress once the segments from these activities are stored
+	if err := models.SaveProcessedActivities(newlyProcessed); err != nil {
+		return nil, err
+	}
+	if !cursor.IsZero() {
+		if err := models.SaveActivityCursor(user.ID, cursor); err != nil {
+			return nil, err
+		}
+	}
 	return userSegmentSlice, nil
 }
 
+// latestActivityTimeZone returns the IANA time zone of the most recent activity.
+// Strava formats activity time zones like "(GMT-08:00) America/Los_Angeles".
+func latestActivityTimeZone(activities []*strava.ActivitySummary) string {
+	var latest *strava.ActivitySummary
+	for _, a := range activities {
+		if a.TimeZone != "" && (latest == nil || a.StartDate.After(latest.StartDate)) {
+			latest = a
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	zone := latest.TimeZone
+	if i := strings.LastIndex(zone, ") "); i >= 0 {
+		zone = zone[i+2:]
+	}
+	if _, err := time.LoadLocation(zone); err != nil {
+		log.Warnf("unrecognized Strava time zone %q", latest.TimeZone)
+		return ""
+	}
+	return zone
+}
+
+// addUserSegment stores a segment in the users segment map, keeping the existing challenge count.
+// Segments Strava flags private or hazardous are removed instead.
+func addUserSegment(userSegments map[int64]*models.UserSegment, segment *models.Segment) {
+	if segment.Restricted() {
+		delete(userSegments, segment.ID)
+		return
+	}
+	count := 0
+	if existing, ok := userSegments[segment.ID]; ok {
+		count = existing.Count
+	}
+	userSegments[segment.ID] = &models.UserSegment{
+		ID:           segment.ID,
+		Name:         segment.Name,
+		ActivityType: segment.ActivityType,
+		Count:        count,
+	}
+}
+
+// initialActivities is the number of recent activities read on a users first sync
+const initialActivities = 30
+
+// activitiesPerPage and maxActivityPages bound how much history a single sync pages through,
+// the cursor still moves forward so the next sync continues where this one stopped
+const (
+	activitiesPerPage = 50
+	maxActivityPages  = 10
+)
+
+// listNewActivities pages through a users activities started after the cursor.
+// Without a cursor only the most recent activities are returned.
+func listNewActivities(client *strava.Client, cursor *time.Time) ([]*strava.ActivitySummary, error) {
+	service := strava.NewCurrentAthleteService(client)
+	if cursor == nil {
+		log.Info("Fetching recent athlete activities from Strava...\n")
+		activities, err := service.ListActivities().Page(1).PerPage(initialActivities).Do()
+		if err != nil {
+			return nil, stravaError(err)
+		}
+		log.Infof("Finished fetching %v athlete activities from Strava...\n", len(activities))
+		return activities, nil
+	}
+
+	log.Infof("Fetching athlete activities after %v from Strava...\n", *cursor)
+	var activities []*strava.ActivitySummary
+	for page := 1; page <= maxActivityPages; page++ {
+		batch, err := service.ListActivities().After(int(cursor.Unix())).Page(page).PerPage(activitiesPerPage).Do()
+		if err != nil {
+			return nil, stravaError(err)
+		}
+		activities = append(activities, batch...)
+		if len(batch) < activitiesPerPage {
+			break
+		}
+	}
+	log.Infof("Finished fetching %v athlete activities from Strava...\n", len(activities))
+	return activities, nil
+}
+
 // GetSegmentsByUserIDFromStrava returns a list of segments for a specific user by ID from strava
 func GetSegmentsByUserIDFromStrava(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
-	id := chi.URLParam(r, "id")
 
 	// convert user id string from url param to number
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("USER ID", numID).Error("unable to convert USER ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to convert USER ID param",
-			"stack": err,
-		})
+		res.Error(err)
+		return
+	}
+
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
 		return
 	}
 
 	// Get users friends from strava and save to DB to prevent transactional overwrites
-	_, err = GetFriendsFromStrava(numID)
+	_, err = GetFriendsFromStrava(numID, ratelimit.Interactive)
 	if err != nil {
 		log.WithField("USER ID", numID).Errorf("unable to retrieve user %v friends from strava", numID)
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to retrieve users friends from strava",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
-	// page is the amount of activites to request from Strava
-	var page = 30
 	// get users segments from Strava
-	userSegments, err := GetUserSegmentsFromStrava(numID, page)
+	userSegments, err := GetUserSegmentsFromStrava(numID, ratelimit.Interactive)
 	if err != nil {
 		log.WithField("USER ID", numID).Errorf("unable to retrieve user %v segments from strava", numID)
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to retrieve users segments from strava",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
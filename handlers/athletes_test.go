@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
 )
 
 // TestGetAthleteByIDFromStravaSuccess retrieves the athlete by ID from Strava
@@ -222,3 +223,20 @@ func TestGetFriendsByIDFromStravaFailureURL(t *testing.T) {
 // 		t.Errorf("expected status code %v, got: %v", exp, rec.Code)
 // 	}
 // }
+
+func TestAddUserSegmentSkipsRestricted(t *testing.T) {
+	segments := map[int64]*models.UserSegment{1: {ID: 1, Count: 2}, 2: {ID: 2, Count: 1}}
+	addUserSegment(segments, &models.Segment{ID: 1, Name: "Hawk Hill"})
+	addUserSegment(segments, &models.Segment{ID: 2, Hazardous: true})
+	addUserSegment(segments, &models.Segment{ID: 3, Private: true})
+
+	if s := segments[1]; s == nil || s.Name != "Hawk Hill" || s.Count != 2 {
+		t.Errorf("expected the segment to be updated keeping its count, got: %+v", s)
+	}
+	if _, ok := segments[2]; ok {
+		t.Error("expected a hazardous segment to be removed")
+	}
+	if _, ok := segments[3]; ok {
+		t.Error("expected a private segment not to be added")
+	}
+}
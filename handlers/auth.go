@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+// errUnauthorized is returned when a request does not carry the users token
+var errUnauthorized = models.UnauthorizedError("missing or invalid access token")
+
+// requestToken reads the access token the client received on login,
+// the query param is accepted because EventSource cannot set headers
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// authenticateUser checks the request was made by the user with the given ID
+func authenticateUser(r *http.Request, userID int64) (*models.User, error) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, errUnauthorized
+	}
+	u, err := models.GetUserByID(userID)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(u.Token)) != 1 {
+		return nil, errUnauthorized
+	}
+	return u, nil
+}
+
+// authenticateToken returns the user the request's access token was issued to
+func authenticateToken(r *http.Request) (*models.User, error) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, errUnauthorized
+	}
+	u, err := models.GetUserByToken(token)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	return u, nil
+}
+
+// requestViewer returns the user the request's access token was issued to, nil for anonymous requests
+func requestViewer(r *http.Request) (*models.User, error) {
+	if requestToken(r) == "" {
+		return nil, nil
+	}
+	return authenticateToken(r)
+}
+
+// checkVisible returns an error unless viewer may see the profile and challenge history of u,
+// anonymous requests are asked to log in
+func checkVisible(u *models.User, viewer *models.User) error {
+	switch {
+	case u.VisibleTo(viewer):
+		return nil
+	case viewer == nil:
+		return errUnauthorized
+	}
+	return models.ForbiddenError(fmt.Sprintf("user %d does not share their profile with you", u.ID))
+}
+
+// visibleUser returns the user with id when the request's viewer may see their profile
+func visibleUser(r *http.Request, id int64) (*models.User, error) {
+	viewer, err := requestViewer(r)
+	if err != nil {
+		return nil, err
+	}
+	user, err := models.GetUserByID(id)
+	if err != nil {
+		return nil, models.InternalError("unable to get user by ID from database", err)
+	}
+	if err := checkVisible(user, viewer); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// segmentMaxAge is how long clients may reuse segment data without revalidating,
+// segments change at most weekly so a day keeps them well within Strava's freshness rule
+const segmentMaxAge = 24 * time.Hour
+
+// defaultCacheControl makes clients revalidate every read, which costs a 304 when nothing changed
+const defaultCacheControl = "private, no-cache"
+
+// ConditionalGet tags successful GET responses with a strong ETag hashed from the body
+// and answers requests whose If-None-Match matches it with 304 Not Modified.
+// Responses that are flushed while being written, such as event streams, are passed through untouched.
+func ConditionalGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &conditionalWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		cw.finish(r)
+	})
+}
+
+// CacheControl sets the Cache-Control header of successful responses
+func CacheControl(value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, value: value}, r)
+		})
+	}
+}
+
+// maxAge returns the Cache-Control value letting shared caches keep a response for d
+func maxAge(d time.Duration) string {
+	return fmt.Sprintf("public, max-age=%d", int(d.Seconds()))
+}
+
+// conditionalWriter buffers a response until the handler returns so its ETag can be computed
+type conditionalWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// Flush switches to writing straight through, a flushed response is never conditional
+func (w *conditionalWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.send()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// send writes the buffered status and body
+func (w *conditionalWriter) send() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// finish sends the buffered response, or 304 when the client already has it
+func (w *conditionalWriter) finish(r *http.Request) {
+	if w.streaming {
+		return
+	}
+	h := w.Header()
+	if w.status != http.StatusOK {
+		w.send()
+		return
+	}
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", defaultCacheControl)
+	}
+	etag := h.Get("ETag")
+	if etag == "" {
+		etag = strongETag(w.body.Bytes())
+		h.Set("ETag", etag)
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		// a 304 carries the validators but none of the representation headers
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.send()
+}
+
+// strongETag returns a quoted hash of body, the same bytes always get the same tag
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch reports whether an If-None-Match header matches etag,
+// the comparison is weak as RFC 7232 requires for If-None-Match
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControlWriter sets the Cache-Control header when the response succeeds,
+// errors keep the default so clients never cache them
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusOK {
+			w.Header().Set("Cache-Control", w.value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
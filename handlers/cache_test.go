@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+func cacheRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(ConditionalGet)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		New(w).Render(http.StatusOK, map[string]string{"id": chi.URLParam(r, "id")})
+	})
+	r.Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+		New(w).Error(models.NotFoundError("user", 1))
+	})
+	r.With(CacheControl(maxAge(segmentMaxAge))).Get("/segments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		res := New(w)
+		if chi.URLParam(r, "id") == "0" {
+			res.Error(models.NotFoundError("segment", 0))
+			return
+		}
+		res.Render(http.StatusOK, map[string]string{"id": chi.URLParam(r, "id")})
+	})
+	r.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 5000\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "data: {}\n\n")
+	})
+	r.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		New(w).Render(http.StatusCreated, map[string]string{"id": "1"})
+	})
+	return r
+}
+
+func cacheRequest(t *testing.T, h http.Handler, method, path, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConditionalGet(t *testing.T) {
+	h := cacheRouter()
+
+	first := cacheRequest(t, h, "GET", "/users/1", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || etag[0] != '"' {
+		t.Fatalf("expected 200 with a strong ETag, got: %v %q", first.Code, etag)
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != defaultCacheControl {
+		t.Errorf("expected Cache-Control %q, got: %q", defaultCacheControl, cc)
+	}
+	if again := cacheRequest(t, h, "GET", "/users/1", ""); again.Header().Get("ETag") != etag {
+		t.Errorf("expected the same ETag for the same body, got: %q and %q", etag, again.Header().Get("ETag"))
+	}
+	if other := cacheRequest(t, h, "GET", "/users/2", ""); other.Header().Get("ETag") == etag {
+		t.Errorf("expected a different ETag for a different body, got: %q", etag)
+	}
+
+	for _, header := range []string{etag, `"nope", ` + etag, "W/" + etag, "*"} {
+		rec := cacheRequest(t, h, "GET", "/users/1", header)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: expected status code %v, got: %v", header, http.StatusNotModified, rec.Code)
+		}
+		if rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: expected an empty 304 with the ETag, got: %q %q", header, rec.Header().Get("ETag"), rec.Body.String())
+		}
+	}
+	if rec := cacheRequest(t, h, "GET", "/users/1", `"nope"`); rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Errorf("stale ETag: expected a full 200, got: %v", rec.Code)
+	}
+}
+
+func TestConditionalGetSkips(t *testing.T) {
+	h := cacheRouter()
+
+	missing := cacheRequest(t, h, "GET", "/missing", "*")
+	if missing.Code != http.StatusNotFound || missing.Header().Get("ETag") != "" {
+		t.Errorf("errors: expected 404 without an ETag, got: %v %q", missing.Code, missing.Header().Get("ETag"))
+	}
+
+	created := cacheRequest(t, h, "POST", "/users", "*")
+	if created.Code != http.StatusCreated || created.Header().Get("ETag") != "" {
+		t.Errorf("POST: expected 201 without an ETag, got: %v %q", created.Code, created.Header().Get("ETag"))
+	}
+
+	events := cacheRequest(t, h, "GET", "/events", "*")
+	if events.Code != http.StatusOK || events.Header().Get("ETag") != "" {
+		t.Errorf("streams: expected 200 without an ETag, got: %v %q", events.Code, events.Header().Get("ETag"))
+	}
+	if exp := "retry: 5000\n\ndata: {}\n\n"; events.Body.String() != exp || !events.Flushed {
+		t.Errorf("streams: expected flushed body %q, got: %q", exp, events.Body.String())
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	h := cacheRouter()
+
+	segment := cacheRequest(t, h, "GET", "/segments/1", "")
+	if exp := "public, max-age=86400"; segment.Header().Get("Cache-Control") != exp {
+		t.Errorf("expected Cache-Control %q, got: %q", exp, segment.Header().Get("Cache-Control"))
+	}
+	if rec := cacheRequest(t, h, "GET", "/segments/1", segment.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+		t.Errorf("expected status code %v, got: %v", http.StatusNotModified, rec.Code)
+	}
+
+	missing := cacheRequest(t, h, "GET", "/segments/0", "")
+	if missing.Code != http.StatusNotFound || missing.Header().Get("Cache-Control") != "" {
+		t.Errorf("expected an uncached 404, got: %v %q", missing.Code, missing.Header().Get("Cache-Control"))
+	}
+}
+
+// TestConditionalGetSkipsStaticAssets tests ConditionalGet is mounted on the API only,
+// files from public are streamed with the FileServer's own headers
+func TestConditionalGetSkipsStaticAssets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "public")
+	if err != nil {
+		t.Fatal("unable to create directory", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "public"), 0755); err != nil {
+		t.Fatal("unable to create directory", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "public", "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal("unable to write file", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal("unable to get working directory", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal("unable to change directory", err)
+	}
+	defer os.Chdir(wd)
+	h := API()
+
+	asset := cacheRequest(t, h, "GET", "/app.js", "")
+	if asset.Code != http.StatusOK || asset.Header().Get("ETag") != "" || asset.Header().Get("Cache-Control") != "" {
+		t.Errorf("static: expected 200 without an ETag or Cache-Control, got: %v %q %q",
+			asset.Code, asset.Header().Get("ETag"), asset.Header().Get("Cache-Control"))
+	}
+
+	doc := cacheRequest(t, h, "GET", openAPIPath, "")
+	if doc.Code != http.StatusOK || doc.Header().Get("ETag") == "" {
+		t.Errorf("api: expected 200 with an ETag, got: %v %q", doc.Code, doc.Header().Get("ETag"))
+	}
+}
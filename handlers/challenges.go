@@ -1,100 +1,253 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/go-chi/chi"
 	log "github.com/sirupsen/logrus"
 	strava "github.com/strava/go.strava"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
 )
 
 // GetChallengeByID returns challenge by ID from the database
 func GetChallengeByID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-	// validate Challenge ID is a bson Object ID
-	if !bson.IsObjectIdHex(id) {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "Challenge ID cannot be converted to BSON Object ID"})
+	oid, err := objectIDParam(r, "id")
+	if err != nil {
+		res.Error(err)
 		return
 	}
 
-	oid := bson.ObjectIdHex(id)
 	log.WithField("id", oid).Info("looking for challenge by ID")
 	challenge, err := models.GetChallengeByID(oid)
 	if err != nil {
-		log.WithField("ID", id).Debug("unable to get challenge by ID")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": err})
+		log.WithField("ID", oid).Debug("unable to get challenge by ID")
+		res.Error(err)
+		return
+	}
+	if err := checkChallengeVisible(r, challenge); err != nil {
+		res.Error(err)
 		return
 	}
 
 	res.Render(http.StatusOK, challenge)
 }
 
+// checkChallengeVisible lets participants see their challenge and anyone else only when both
+// opponents share their challenge history with them
+func checkChallengeVisible(r *http.Request, c *models.Challenge) error {
+	viewer, err := requestViewer(r)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for _, o := range []*models.Opponent{c.Challenger, c.Challengee} {
+		if o == nil {
+			continue
+		}
+		if viewer != nil && o.ID == viewer.ID {
+			return nil
+		}
+		ids = append(ids, o.ID)
+	}
+	users, err := models.GetUsersByIDs(ids)
+	if err != nil {
+		return models.InternalError("unable to get challenge opponents from database", err)
+	}
+	for i := range users {
+		if err := checkVisible(&users[i], viewer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visibleChallenges returns the challenges the request's viewer may see
+func visibleChallenges(r *http.Request, challenges []models.Challenge) ([]models.Challenge, error) {
+	viewer, err := requestViewer(r)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, c := range challenges {
+		for _, o := range []*models.Opponent{c.Challenger, c.Challengee} {
+			if o != nil {
+				ids = append(ids, o.ID)
+			}
+		}
+	}
+	var users []models.User
+	if len(ids) > 0 {
+		if users, err = models.GetUsersByIDs(ids); err != nil {
+			return nil, models.InternalError("unable to get challenge opponents from database", err)
+		}
+	}
+	return filterChallenges(challenges, users, viewer), nil
+}
+
+// filterChallenges keeps the challenges viewer takes part in and those whose stored opponents
+// all share their profile with them, as checkChallengeVisible does for a single challenge
+func filterChallenges(challenges []models.Challenge, opponents []models.User, viewer *models.User) []models.Challenge {
+	hidden := map[int64]bool{}
+	for i := range opponents {
+		if !opponents[i].VisibleTo(viewer) {
+			hidden[opponents[i].ID] = true
+		}
+	}
+
+	kept := []models.Challenge{}
+	for _, c := range challenges {
+		visible := true
+		for _, o := range []*models.Opponent{c.Challenger, c.Challengee} {
+			if o == nil {
+				continue
+			}
+			if viewer != nil && o.ID == viewer.ID {
+				visible = true
+				break
+			}
+			if hidden[o.ID] {
+				visible = false
+			}
+		}
+		if visible {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// authenticateOpponent returns the user the request's access token was issued to when they take part
+// in the challenge, as its challengee when challengeeOnly is set
+func authenticateOpponent(r *http.Request, id bson.ObjectId, challengeeOnly bool) (*models.User, error) {
+	viewer, err := authenticateToken(r)
+	if err != nil {
+		return nil, err
+	}
+	c, err := models.GetChallengeByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Challengee != nil && c.Challengee.ID == viewer.ID {
+		return viewer, nil
+	}
+	if !challengeeOnly && c.Challenger != nil && c.Challenger.ID == viewer.ID {
+		return viewer, nil
+	}
+	if challengeeOnly {
+		return nil, models.ForbiddenError(fmt.Sprintf("only the challengee may respond to challenge %s", id.Hex()))
+	}
+	return nil, models.ForbiddenError(fmt.Sprintf("you do not take part in challenge %s", id.Hex()))
+}
+
 type createRequest struct {
 	SegmentID      int        `json:"segmentId"`
 	ChallengerID   int        `json:"challengerId"`
 	ChallengeeID   int        `json:"challengeeId"`
 	CompletionDate time.Time  `json:"completionDate"`
 	CreationDate   *time.Time `json:"creationDate"`
+	ActivityType   string     `json:"activityType"`
 }
 
-// CreateChallenge creates a new challenge with post content
+// CreateChallenge creates a new challenge with post content, only the challenger may create it.
+// Malformed requests are rejected with 400 and requests that break a challenge rule with 422.
 func CreateChallenge(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	body, err := ioutil.ReadAll(r.Body)
+	req, err := decodeCreateRequest(r)
 	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not read request body",
-			"stack": err,
-		})
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, int64(req.ChallengerID)); err != nil {
+		res.Error(err)
 		return
 	}
+	challenge, err := createChallenge(req)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.Render(http.StatusOK, challenge)
+}
 
-	var req createRequest
-	err = json.Unmarshal(body, &req)
+// PostChallenge creates a new challenge for the authenticated challenger and responds with 201 and its location
+func PostChallenge(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	req, err := decodeCreateRequest(r)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, int64(req.ChallengerID)); err != nil {
+		res.Error(err)
+		return
+	}
+	challenge, err := createChallenge(req)
 	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not unmarshal request to create challenge",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
+	w.Header().Set("Location", v2Prefix+"/challenges/"+challenge.ID.Hex())
+	res.Render(http.StatusCreated, challenge)
+}
+
+// decodeCreateRequest parses a create challenge request and checks the required fields are present
+func decodeCreateRequest(r *http.Request) (createRequest, error) {
+	var req createRequest
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return req, models.InvalidError("Could not read request body", nil)
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		var errs ValidationErrors
+		errs.Add("body", "malformed", "could not unmarshal request to create challenge: %v", err)
+		return req, models.InvalidError("invalid challenge request", errs)
+	}
+	if errs := validateCreateRequest(req); len(errs) > 0 {
+		return req, models.InvalidError("invalid challenge request", errs)
+	}
+	return req, nil
+}
+
+// createChallenge checks a request against the challenge rules and stores the new challenge
+func createChallenge(req createRequest) (*models.Challenge, error) {
 	log.Infof("SegmentID: %v", req.SegmentID)
 	log.Infof("ChallengerID: %v", req.ChallengerID)
 	log.Infof("ChallengeeID: %v", req.ChallengeeID)
 	log.Infof("CompletionDate: %v", req.CompletionDate)
 	log.Infof("CreationDate: %v", req.CreationDate)
-	var t time.Time
-	if req.CreationDate != nil {
-		t = *req.CreationDate
-	} else {
-		t = time.Now()
-	}
-	created := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-	log.Infof("created date %v formatted successfully", created)
-
-	e := req.CompletionDate
-	expires := time.Date(e.Year(), e.Month(), e.Day(), 23, 59, 59, 0, e.Location())
-	log.Infof("expires date %v formatted successfully", expires)
-
 	challengerUser, err := models.GetUserByID(int64(req.ChallengerID))
+	if models.IsNotFound(err) {
+		var errs ValidationErrors
+		errs.Add("challengerId", "not_found", "challenger %d does not exist", req.ChallengerID)
+		return nil, models.ValidationError("challenge request failed validation", errs)
+	}
 	if err != nil {
 		log.WithField("CHALLENGER ID", req.ChallengerID).Error("unable to retrieve challenger from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to retrieve challenger from database",
-			"stack": err,
-		})
-		return
+		return nil, models.InternalError("unable to retrieve challenger from database", err)
 	}
+
+	// challenge windows cover whole days in the challengers time zone and are stored in UTC
+	loc := challengerUser.Location()
+	t := time.Now()
+	creationDay := t.In(loc)
+	if req.CreationDate != nil {
+		t = *req.CreationDate
+		creationDay = t
+	}
+	created, expires := challengeWindow(creationDay, req.CompletionDate, loc)
+	log.Infof("challenge window %v to %v in %v", created, expires, loc)
 	challenger := models.Opponent{
 		ID:        challengerUser.ID,
 		Name:      challengerUser.FullName,
@@ -103,6 +256,7 @@ func CreateChallenge(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Infof("challenger %v formatted successfully", challenger.ID)
 
+	var errs ValidationErrors
 	var challengee models.Opponent
 	for _, friend := range challengerUser.Friends {
 		if friend.ID == int64(req.ChallengeeID) {
@@ -116,24 +270,30 @@ func CreateChallenge(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
-	if challengee.ID == 0 {
-		log.WithField("CHALLENGEE ID", req.ChallengeeID).Error("unable to retrieve challengee from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to retrieve challengee from database",
-			"stack": err,
-		})
-		return
+	if challengee.ID == 0 && req.ChallengeeID != req.ChallengerID {
+		log.WithField("CHALLENGEE ID", req.ChallengeeID).Error("challengee is not a friend of the challenger")
+		errs.Add("challengeeId", "not_friend", "challengee %d is not a friend of the challenger", req.ChallengeeID)
 	}
-	log.Infof("challengee %v formatted successfully", challengee.ID)
 
 	segment, err := models.GetSegmentByID(int64(req.SegmentID))
-	if err != nil {
+	if models.IsNotFound(err) {
+		errs.Add("segmentId", "not_found", "segment %d does not exist", req.SegmentID)
+	} else if err != nil {
 		log.WithField("SEGMENT ID", req.SegmentID).Error("unable to get segment by ID")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to get segment by ID",
-			"stack": err,
-		})
-		return
+		return nil, models.InternalError("unable to get segment by ID", err)
+	}
+
+	errs = append(errs, validateChallengeRules(req, created, expires, segment, time.Now(), loc)...)
+	if len(errs) == 0 {
+		open, err := models.GetOpenChallengesBetween(challenger.ID, challengee.ID)
+		if err != nil {
+			return nil, models.InternalError("unable to get open challenges from database", err)
+		}
+		errs = validateOpenChallenges(req, open)
+	}
+	if len(errs) > 0 {
+		log.WithField("CHALLENGER ID", req.ChallengerID).Infof("rejecting challenge: %v", errs)
+		return nil, models.ValidationError("challenge request failed validation", errs)
 	}
 	log.Infof("segment %v found from DB", segment.ID)
 
@@ -145,6 +305,7 @@ func CreateChallenge(w http.ResponseWriter, r *http.Request) {
 		Status:     "pending",
 		Created:    &created,
 		Expires:    &expires,
+		TimeZone:   loc.String(),
 		CreatedAt:  t,
 		UpdatedAt:  t,
 	}
@@ -153,17 +314,23 @@ func CreateChallenge(w http.ResponseWriter, r *http.Request) {
 	err = models.CreateChallenge(challenge)
 	if err != nil {
 		log.Error("Could not create challenge in database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not create challenge in database",
-			"stack": err,
-		})
-		return
+		return nil, models.InternalError("Could not create challenge in database", err)
 	}
-	res.Render(http.StatusOK, challenge)
+	emitChallengeEvent(notify.ChallengeReceived, &challenge, challengee.ID)
+	return &challenge, nil
+}
+
+// challengeWindow returns the start of the creation day and the end of the completion day in loc.
+// The calendar dates are taken as sent by the client and the boundaries are returned in UTC.
+func challengeWindow(creation, completion time.Time, loc *time.Location) (time.Time, time.Time) {
+	created := time.Date(creation.Year(), creation.Month(), creation.Day(), 0, 0, 0, 0, loc)
+	expires := time.Date(completion.Year(), completion.Month(), completion.Day(), 23, 59, 59, 0, loc)
+	return created.UTC(), expires.UTC()
 }
 
-// UpdateChallengeEffort grabs challenge effort information for a user from Strava
-func UpdateChallengeEffort(ID bson.ObjectId, UserID int64) (*models.Challenge, error) {
+// UpdateChallengeEffort grabs challenge effort information for a user from Strava,
+// the challenge is nil when Strava has no effort in the challenge window
+func UpdateChallengeEffort(ID bson.ObjectId, UserID int64, p ratelimit.Priority) (*models.Challenge, error) {
 	// Get challenge by ChallengeID from DB
 	c, err := models.GetChallengeByID(ID)
 	if err != nil {
@@ -178,7 +345,7 @@ func UpdateChallengeEffort(ID bson.ObjectId, UserID int64) (*models.Challenge, e
 	}
 
 	// use our access token to grab generic segment info
-	client := strava.NewClient(u.Token)
+	client := newStravaClient(u.Token, p)
 
 	// request efforts by segment ID between start and end dates
 	log.Infof("Fetching segment %v info...", c.Segment.ID)
@@ -186,10 +353,8 @@ func UpdateChallengeEffort(ID bson.ObjectId, UserID int64) (*models.Challenge, e
 	log.Infof("ending on %v", *c.Expires)
 	efforts, err := strava.NewSegmentsService(client).ListEfforts(c.Segment.ID).AthleteId(u.ID).DateRange(*c.Created, *c.Expires).Do()
 	if err != nil {
-
-		return nil, err
+		return nil, stravaError(err)
 	}
-	log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
 
 	// check for segment efforts
 	if len(efforts) > 0 {
@@ -203,12 +368,16 @@ func UpdateChallengeEffort(ID bson.ObjectId, UserID int64) (*models.Challenge, e
 			c.Challengee.AverageWatts = &e.AveragePower
 			c.Challengee.AverageHeartRate = &e.AverageHeartrate
 			c.Challengee.MaxHeartRate = &e.MaximumHeartrate
+			firstEffort := !c.Challengee.Completed
 			c.Challengee.Completed = true
 			c.UpdatedAt = time.Now()
 			if err := c.UpdateChallenge(); err != nil {
 				log.Error("unable to update challengee values in challenge")
 				return nil, err
 			}
+			if firstEffort {
+				emitChallengeEvent(notify.OpponentCompleted, c, c.Challenger.ID)
+			}
 			// check if challenger has completed to calculate winner
 			if c.Challenger.Completed == true {
 				// calculate winner
@@ -221,12 +390,16 @@ func UpdateChallengeEffort(ID bson.ObjectId, UserID int64) (*models.Challenge, e
 			c.Challenger.AverageWatts = &e.AveragePower
 			c.Challenger.AverageHeartRate = &e.AverageHeartrate
 			c.Challenger.MaxHeartRate = &e.MaximumHeartrate
+			firstEffort := !c.Challenger.Completed
 			c.Challenger.Completed = true
 			c.UpdatedAt = time.Now()
 			if err := c.UpdateChallenge(); err != nil {
 				log.Error("unable to update challenger values in challenge")
 				return nil, err
 			}
+			if firstEffort {
+				emitChallengeEvent(notify.OpponentCompleted, c, c.Challengee.ID)
+			}
 			// check if challengee has completed to calculate winner
 			if c.Challengee.Completed == true {
 				// calculate winner
@@ -238,11 +411,14 @@ func UpdateChallengeEffort(ID bson.ObjectId, UserID int64) (*models.Challenge, e
 		}
 	} else {
 		log.Info("No efforts returned from Strava")
-		return nil, err
+		return nil, nil
 	}
 	return c, nil
 }
 
+// errNoEffort is returned when Strava has no effort of the user on the segment within the challenge window
+var errNoEffort = &models.Error{Kind: models.KindNotFound, Message: "no effort found for this segment in the challenge window"}
+
 type completeRequest struct {
 	ID     bson.ObjectId `json:"id"`
 	UserID int64         `json:"userId"`
@@ -252,36 +428,63 @@ type completeRequest struct {
 func CompleteChallengeByID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Error("Could not ready request body")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not read request body",
-			"stack": err,
-		})
+	var req completeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Could not unmarshal request to complete challenge")
+		res.Error(models.InvalidError("Could not unmarshal request to complete challenge", nil))
+		return
+	}
+	if req.ID == "" {
+		res.Error(models.InvalidError("challenge ID is required", nil))
 		return
 	}
+	completeChallenge(res, r, req.ID, req.UserID)
+}
 
+// CompleteChallenge records the effort of the user in the request body for the challenge in the request path
+func CompleteChallenge(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	id, err := objectIDParam(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
 	var req completeRequest
-	err = json.Unmarshal(body, &req)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error(models.InvalidError("Could not unmarshal request to complete challenge", nil))
+		return
+	}
+	completeChallenge(res, r, id, req.UserID)
+}
+
+// completeChallenge records the effort of userID, the request must be made by that user
+// and they must take part in the challenge
+func completeChallenge(res *Response, r *http.Request, id bson.ObjectId, userID int64) {
+	log.Infof("ChallengeID: %v", id)
+	log.Infof("UserID: %v", userID)
+	if userID <= 0 {
+		res.Error(models.InvalidError("user ID is required", nil))
+		return
+	}
+	viewer, err := authenticateOpponent(r, id, false)
 	if err != nil {
-		log.Error("Could not unmarshal request to complete challenge")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not unmarshal request to complete challenge",
-			"stack": err,
-		})
+		res.Error(err)
+		return
+	}
+	if viewer.ID != userID {
+		res.Error(models.ForbiddenError("you may only record your own effort"))
 		return
 	}
-	log.Infof("ChallengeID: %v", req.ID)
-	log.Infof("UserID: %v", req.UserID)
 
-	c, err := UpdateChallengeEffort(req.ID, req.UserID)
-	if c == nil || err != nil {
+	c, err := UpdateChallengeEffort(id, userID, ratelimit.Interactive)
+	if err != nil {
 		log.Error("Could not update challenge effort")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not update challenge effort",
-			"stack": err,
-		})
+		res.Error(models.InternalError("Could not update challenge effort", err))
+		return
+	}
+	if c == nil {
+		res.Error(errNoEffort)
 		return
 	}
 
@@ -395,209 +598,294 @@ func UpdateChallengeResult(id bson.ObjectId) error {
 		log.Error("Unable to update challenge")
 		return err
 	}
+	emitChallengeEvent(notify.ChallengeSettled, c, c.Challenger.ID, c.Challengee.ID)
 	return nil
 }
 
 // CronComplete finds a list of expired challenges and processes them for completion
-func CronComplete() {
+func CronComplete(ctx context.Context) error {
 	expired, err := models.GetExpiredChallenges()
 	if err != nil {
 		log.Error("Unable to find expired challenges")
-		return
+		return err
 	}
 	log.Infof("%d expired challenges returned from GetExpiredChallenges", len(*expired))
 	for _, challenge := range *expired {
+		// stop between challenges when the job runner is draining
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		log.Infof("challenge %v is expired on %v", challenge.ID, challenge.Expires)
 		// only update challenge efforts if a challenge is not pending
 		if challenge.Status != "pending" {
 			// update efforts for both participants before determining a winner or loser
-			UpdateChallengeEffort(challenge.ID, challenge.Challengee.ID)
-			UpdateChallengeEffort(challenge.ID, challenge.Challenger.ID)
+			UpdateChallengeEffort(challenge.ID, challenge.Challengee.ID, ratelimit.Bulk)
+			UpdateChallengeEffort(challenge.ID, challenge.Challenger.ID, ratelimit.Bulk)
 		}
 		if err := UpdateChallengeResult(challenge.ID); err != nil {
 			log.Error("Unable to update challenge result")
 		}
 	}
+	return nil
 }
 
 type updateRequest struct {
 	ID bson.ObjectId `json:"id"`
 }
 
-// AcceptChallengeByID accepts a challenge
+// AcceptChallengeByID accepts a challenge, only its challengee may accept it
 func AcceptChallengeByID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	body, err := ioutil.ReadAll(r.Body)
+	req, err := decodeUpdateRequest(r)
 	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not read request body",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-
-	var req updateRequest
-	err = json.Unmarshal(body, &req)
-	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not unmarshal request to update challenge",
-			"stack": err,
-		})
+	if _, err := authenticateOpponent(r, req.ID, true); err != nil {
+		res.Error(err)
 		return
 	}
-
-	log.Infof("accepting challenge %v", req.ID)
-	err = models.UpdateChallengeStatus(req.ID, "active", time.Now())
-	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not update challenge in database",
-			"stack": err,
-		})
+	if _, err := acceptChallenge(req.ID); err != nil {
+		res.Error(err)
 		return
 	}
 	res.Render(http.StatusOK, "challenge accepted")
 }
 
-// DeclineChallengeByID decline a challenge
+// DeclineChallengeByID decline a challenge, only its challengee may decline it
 func DeclineChallengeByID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	body, err := ioutil.ReadAll(r.Body)
+	req, err := decodeUpdateRequest(r)
 	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not read request body",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-
-	var req updateRequest
-	err = json.Unmarshal(body, &req)
-	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not unmarshal request to update challenge",
-			"stack": err,
-		})
+	if _, err := authenticateOpponent(r, req.ID, true); err != nil {
+		res.Error(err)
 		return
 	}
-
-	log.Infof("declining challenge %v", req.ID)
-	err = models.RemoveChallenge(req.ID)
-	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not remove challenge in database",
-			"stack": err,
-		})
+	if _, err := declineChallenge(req.ID); err != nil {
+		res.Error(err)
 		return
 	}
 	res.Render(http.StatusOK, "challenge declined")
 }
 
-// GetAllChallengesByUserID gets all pending challenges by user ID
-func GetAllChallengesByUserID(w http.ResponseWriter, r *http.Request) {
+// AcceptChallenge accepts the challenge in the request path and returns it
+func AcceptChallenge(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	id, err := objectIDParam(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert user ID param")
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to convert user ID param",
-			"stack": err,
-		})
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateOpponent(r, id, true); err != nil {
+		res.Error(err)
 		return
 	}
-	allChallenges, err := models.GetAllChallenges(numID)
+	c, err := acceptChallenge(id)
 	if err != nil {
-		log.WithField("ID", numID).Errorf("Could not retrieve challenges from database for user %v", numID)
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not retrieve pending challenges from database",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-	res.Render(http.StatusOK, allChallenges)
+	res.Render(http.StatusOK, c)
 }
 
-// GetPendingChallengesByUserID gets all pending challenges by user ID
-func GetPendingChallengesByUserID(w http.ResponseWriter, r *http.Request) {
+// DeclineChallenge declines the challenge in the request path and returns the removed challenge
+func DeclineChallenge(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	id, err := objectIDParam(r, "id")
 	if err != nil {
-		log.WithField("USER ID", numID).Error("unable to convert user ID param")
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to convert user ID param",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-	pendingChallenges, err := models.GetPendingChallenges(numID)
+	if _, err := authenticateOpponent(r, id, true); err != nil {
+		res.Error(err)
+		return
+	}
+	c, err := declineChallenge(id)
 	if err != nil {
-		log.WithField("USER ID", numID).Error("Could not retrieve pending challenges from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not retrieve pending challenges from database",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-	res.Render(http.StatusOK, pendingChallenges)
+	res.Render(http.StatusOK, c)
 }
 
-// GetActiveChallengesByUserID gets all active challenges by user ID
+func decodeUpdateRequest(r *http.Request) (updateRequest, error) {
+	var req updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, models.InvalidError("Could not unmarshal request to update challenge", nil)
+	}
+	if req.ID == "" {
+		return req, models.InvalidError("challenge ID is required", nil)
+	}
+	return req, nil
+}
+
+// acceptChallenge makes a pending challenge active and notifies the challenger,
+// replaying it on a challenge that is no longer pending fails without notifying anyone
+func acceptChallenge(id bson.ObjectId) (*models.Challenge, error) {
+	log.Infof("accepting challenge %v", id)
+	c, err := models.UpdatePendingChallengeStatus(id, "active", time.Now())
+	if err != nil {
+		return nil, models.InternalError("Could not update challenge in database", err)
+	}
+	emitChallengeEvent(notify.ChallengeAccepted, c, c.Challenger.ID)
+	return c, nil
+}
+
+// declineChallenge removes a pending challenge and notifies the challenger,
+// replaying it on a challenge that is no longer pending fails without notifying anyone
+func declineChallenge(id bson.ObjectId) (*models.Challenge, error) {
+	log.Infof("declining challenge %v", id)
+	declined, err := models.RemovePendingChallenge(id)
+	if err != nil {
+		return nil, models.InternalError("Could not remove challenge in database", err)
+	}
+	emitChallengeEvent(notify.ChallengeDeclined, declined, declined.Challenger.ID)
+	return declined, nil
+}
+
+// GetAllChallengesByUserID gets a users challenges by user ID
+func GetAllChallengesByUserID(w http.ResponseWriter, r *http.Request) {
+	listChallengesV1(w, r, "", "expires")
+}
+
+// GetPendingChallengesByUserID gets a users pending challenges by user ID
+func GetPendingChallengesByUserID(w http.ResponseWriter, r *http.Request) {
+	listChallengesV1(w, r, "pending", "expires")
+}
+
+// GetActiveChallengesByUserID gets a users active challenges by user ID
 func GetActiveChallengesByUserID(w http.ResponseWriter, r *http.Request) {
-	res := New(w)
+	listChallengesV1(w, r, "active", "expires")
+}
 
-	id := chi.URLParam(r, "id")
+// GetCompletedChallengesByUserID gets a users completed challenges by user ID
+func GetCompletedChallengesByUserID(w http.ResponseWriter, r *http.Request) {
+	listChallengesV1(w, r, "completed", "updated")
+}
 
-	numID, err := strconv.ParseInt(id, 10, 64)
+// listChallengesV1 renders the first page of a users challenges as a plain list.
+// v1 lists have no cursor, clients that need more than maxPageLimit challenges page through v2.
+func listChallengesV1(w http.ResponseWriter, r *http.Request, status, sort string) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("USER ID", numID).Error("unable to convert user ID param")
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to convert user ID param",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-	activeChallenges, err := models.GetActiveChallenges(numID)
+	limit, err := parseLimit(r, maxPageLimit, maxPageLimit)
 	if err != nil {
-		log.WithField("ID", numID).Error("Could not retrieve active challenges from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not retrieve active challenges from database",
-			"stack": err,
-		})
+		res.Error(err)
+		return
+	}
+	if _, err := visibleUser(r, numID); err != nil {
+		res.Error(err)
 		return
 	}
-	res.Render(http.StatusOK, activeChallenges)
+	challenges, _, err := models.GetChallenges(models.ChallengeQuery{UserID: numID, Status: status, Sort: sort, Limit: limit})
+	if err != nil {
+		log.WithField("USER ID", numID).Errorf("Could not retrieve %s challenges from database", status)
+		res.Error(models.InternalError("Could not retrieve challenges from database", err))
+		return
+	}
+	// the other opponent's privacy setting hides the challenge too
+	if challenges, err = visibleChallenges(r, challenges); err != nil {
+		res.Error(err)
+		return
+	}
+	res.Render(http.StatusOK, challenges)
 }
 
-// GetCompletedChallengesByUserID gets all completed challenges by user ID
-func GetCompletedChallengesByUserID(w http.ResponseWriter, r *http.Request) {
-	res := New(w)
+// challengeStatuses are the values of the status filter of a users challenge list
+var challengeStatuses = map[string]bool{"": true, "pending": true, "active": true, "completed": true}
+
+// challengeSorts are the fields a users challenge list can be sorted by
+var challengeSorts = []string{"expires", "created", "updated"}
 
-	id := chi.URLParam(r, "id")
+// challengeQuery reads the filter, sort and cursor query params of a users challenge list
+func challengeQuery(r *http.Request, userID int64) (models.ChallengeQuery, error) {
+	q := models.ChallengeQuery{UserID: userID, ActivityType: r.URL.Query().Get("activityType")}
 
-	numID, err := strconv.ParseInt(id, 10, 64)
+	q.Status = r.URL.Query().Get("status")
+	if !challengeStatuses[q.Status] {
+		return q, models.InvalidError("status must be pending, active or completed", map[string]string{"status": q.Status})
+	}
+	var err error
+	if q.OpponentID, err = int64Query(r, "opponent"); err != nil {
+		return q, err
+	}
+	if q.SegmentID, err = int64Query(r, "segment"); err != nil {
+		return q, err
+	}
+	if q.From, err = parseDate(r, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseDate(r, "to"); err != nil {
+		return q, err
+	}
+	if q.Sort, q.Desc, err = parseSort(r, challengeSorts, "expires"); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseLimit(r, defaultPageLimit, maxPageLimit); err != nil {
+		return q, err
+	}
+	var after models.ChallengeCursor
+	ok, err := decodeCursor(r, &after)
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert user ID param")
-		res.Render(http.StatusBadRequest, map[string]interface{}{
-			"error": "unable to convert user ID param",
-			"stack": err,
-		})
+		return q, err
+	}
+	if ok {
+		if !after.ID.Valid() {
+			return q, models.InvalidError("invalid cursor param", nil)
+		}
+		q.After = &after
+	}
+	return q, nil
+}
+
+// GetChallengesByUserID gets a page of a users challenges filtered by status, opponent, segment,
+// activity type and creation date
+func GetChallengesByUserID(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
 		return
 	}
-	completedChallenges, err := models.GetCompletedChallenges(numID)
+	if _, err := visibleUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+	q, err := challengeQuery(r, numID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	challenges, total, err := models.GetChallenges(q)
+	if err != nil {
+		res.Error(models.InternalError("Could not retrieve challenges from database", err))
+		return
+	}
+
+	// the cursor follows the stored challenges, those hidden by the other opponent's
+	// privacy setting are left out of the page and its total
+	var body page
+	if len(challenges) == q.Limit {
+		body.Next = encodeCursor(q.Cursor(challenges[len(challenges)-1]))
+	}
+	visible, err := visibleChallenges(r, challenges)
 	if err != nil {
-		log.WithField("ID", numID).Error("Could not retrieve active challenges from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Could not retrieve active challenges from database",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
-	res.Render(http.StatusOK, completedChallenges)
+	body.Items, body.Total = visible, total-(len(challenges)-len(visible))
+	res.Render(http.StatusOK, body)
 }
@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	strava "github.com/strava/go.strava"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // TestGetChallengeByIDSuccess tests to successfully get a challenge by ID from the database
@@ -61,7 +66,7 @@ func TestGetChallengeByIDFailureID(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusNotFound; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -83,7 +88,7 @@ func TestGetChallengeByIDFailureString(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -136,3 +141,129 @@ func TestGetChallengeByIDFailureString(t *testing.T) {
 // 		t.Errorf("unable to remove challenge: %s", err)
 // 	}
 // }
+
+func TestChallengeWindowInChallengerTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	creation := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	completion := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+
+	created, expires := challengeWindow(creation, completion, loc)
+	if exp := time.Date(2018, 6, 1, 7, 0, 0, 0, time.UTC); !created.Equal(exp) || created.Location() != time.UTC {
+		t.Errorf("expected window to start at %v, got %v", exp, created)
+	}
+	// an evening ride in California on the last day is inside the window
+	if exp := time.Date(2018, 6, 5, 6, 59, 59, 0, time.UTC); !expires.Equal(exp) {
+		t.Errorf("expected window to end at %v, got %v", exp, expires)
+	}
+}
+
+func TestLatestActivityTimeZone(t *testing.T) {
+	activities := []*strava.ActivitySummary{
+		{StartDate: time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC), TimeZone: "(GMT-05:00) America/New_York"},
+		{StartDate: time.Date(2018, 6, 2, 0, 0, 0, 0, time.UTC), TimeZone: "(GMT-08:00) America/Los_Angeles"},
+		{StartDate: time.Date(2018, 6, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	if zone := latestActivityTimeZone(activities); zone != "America/Los_Angeles" {
+		t.Errorf("expected America/Los_Angeles, got %q", zone)
+	}
+	if zone := latestActivityTimeZone(nil); zone != "" {
+		t.Errorf("expected no time zone, got %q", zone)
+	}
+}
+
+func TestChallengeActionsRequireAccessToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/challenges/create", CreateChallenge)
+	r.Post("/challenges", PostChallenge)
+	r.Put("/challenges/accept", AcceptChallengeByID)
+	r.Put("/challenges/decline", DeclineChallengeByID)
+	r.Put("/challenges/complete", CompleteChallengeByID)
+	r.Post("/challenges/{id}/accept", AcceptChallenge)
+	r.Post("/challenges/{id}/decline", DeclineChallenge)
+	r.Post("/challenges/{id}/complete", CompleteChallenge)
+
+	id := bson.NewObjectId().Hex()
+	create := fmt.Sprintf(`{"segmentId": 1, "challengerId": 2, "challengeeId": 3, "completionDate": %q}`,
+		time.Now().AddDate(0, 0, 3).Format(time.RFC3339))
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/challenges/create", create},
+		{"POST", "/challenges", create},
+		{"PUT", "/challenges/accept", fmt.Sprintf(`{"id": %q}`, id)},
+		{"PUT", "/challenges/decline", fmt.Sprintf(`{"id": %q}`, id)},
+		{"PUT", "/challenges/complete", fmt.Sprintf(`{"id": %q, "userId": 2}`, id)},
+		{"POST", "/challenges/" + id + "/accept", ""},
+		{"POST", "/challenges/" + id + "/decline", ""},
+		{"POST", "/challenges/" + id + "/complete", `{"userId": 2}`},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if exp := http.StatusUnauthorized; rec.Code != exp {
+			t.Errorf("%s %s: expected status code %v, got: %v", tc.method, tc.path, exp, rec.Code)
+		}
+	}
+}
+
+func TestV1ChallengeListsLimit(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/{id}/challenges", GetAllChallengesByUserID)
+	r.Get("/{id}/challenges/pending", GetPendingChallengesByUserID)
+	r.Get("/{id}/challenges/active", GetActiveChallengesByUserID)
+	r.Get("/{id}/challenges/completed", GetCompletedChallengesByUserID)
+
+	for _, path := range []string{"/1/challenges", "/1/challenges/pending", "/1/challenges/active", "/1/challenges/completed"} {
+		for _, limit := range []string{"0", "-1", "fred"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", path+"?limit="+limit, nil))
+			if exp := http.StatusBadRequest; rec.Code != exp {
+				t.Errorf("%s?limit=%s: expected status code %v, got: %v", path, limit, exp, rec.Code)
+			}
+		}
+	}
+}
+
+func TestFilterChallenges(t *testing.T) {
+	private := &models.UserSettings{Privacy: models.PrivacyPrivate}
+	friendsOnly := &models.UserSettings{Privacy: models.PrivacyFriends}
+	opponents := []models.User{
+		{ID: 1},
+		{ID: 2, Settings: friendsOnly, Friends: []*models.Friend{{ID: 3}}},
+		{ID: 4, Settings: private},
+	}
+	challenge := func(challenger, challengee int64) models.Challenge {
+		return models.Challenge{Challenger: &models.Opponent{ID: challenger}, Challengee: &models.Opponent{ID: challengee}}
+	}
+	challenges := []models.Challenge{challenge(1, 2), challenge(4, 1), challenge(1, 5)}
+
+	tests := []struct {
+		viewer *models.User
+		exp    []int
+	}{
+		{nil, []int{2}},
+		{&models.User{ID: 3}, []int{0, 2}},
+		{&models.User{ID: 4}, []int{1, 2}},
+		{&models.User{ID: 2}, []int{0, 2}},
+	}
+	for _, tc := range tests {
+		kept := filterChallenges(challenges, opponents, tc.viewer)
+		if len(kept) != len(tc.exp) {
+			t.Errorf("viewer %+v: expected %d challenges, got: %+v", tc.viewer, len(tc.exp), kept)
+			continue
+		}
+		for i, c := range kept {
+			if exp := challenges[tc.exp[i]]; c.Challenger.ID != exp.Challenger.ID || c.Challengee.ID != exp.Challengee.ID {
+				t.Errorf("viewer %+v: expected challenge %d, got: %+v", tc.viewer, tc.exp[i], c)
+			}
+		}
+	}
+	if kept := filterChallenges(nil, nil, nil); kept == nil || len(kept) != 0 {
+		t.Errorf("expected an empty list, got: %#v", kept)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// v2Prefix is the path of the current API version
+const v2Prefix = "/api/v2"
+
+// v1 API deprecation schedule, clients have until the sunset date to move to v2
+var (
+	v1DeprecatedAt = mustParseDate(utils.GetEnvStringDefault("API_V1_DEPRECATED_AT", "2026-10-19"))
+	v1SunsetAt     = mustParseDate(utils.GetEnvStringDefault("API_V1_SUNSET_AT", "2027-04-19"))
+)
+
+func mustParseDate(value string) time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.WithError(err).Fatalf("unable to parse date %q", value)
+	}
+	return t
+}
+
+// Deprecated marks every response as deprecated with the Deprecation and Sunset headers
+// and links to the documentation of the version replacing it.
+func Deprecated(deprecatedAt, sunsetAt time.Time, successor string) func(http.Handler) http.Handler {
+	deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	sunset := sunsetAt.UTC().Format(http.TimeFormat)
+	link := fmt.Sprintf(`<%s>; rel="successor-version"`, successor)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Sunset", sunset)
+			w.Header().Add("Link", link)
+			log.WithField("PATH", r.URL.Path).Debug("deprecated API version requested")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
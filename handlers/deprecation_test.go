@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeprecatedHeaders(t *testing.T) {
+	deprecatedAt := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	sunsetAt := time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)
+	h := Deprecated(deprecatedAt, sunsetAt, "/api/v2")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/health", nil))
+	if exp := fmt.Sprintf("@%d", deprecatedAt.Unix()); w.Header().Get("Deprecation") != exp {
+		t.Errorf("expected Deprecation %s, got %q", exp, w.Header().Get("Deprecation"))
+	}
+	if exp := "Mon, 19 Apr 2027 00:00:00 GMT"; w.Header().Get("Sunset") != exp {
+		t.Errorf("expected Sunset %s, got %q", exp, w.Header().Get("Sunset"))
+	}
+	if exp := `</api/v2>; rel="successor-version"`; w.Header().Get("Link") != exp {
+		t.Errorf("expected Link %s, got %q", exp, w.Header().Get("Link"))
+	}
+}
+
+// TestAPIVersions tests v1 routes are deprecated and v2 routes are resource oriented
+func TestAPIVersions(t *testing.T) {
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	tests := []struct {
+		method     string
+		path       string
+		body       string
+		status     int
+		deprecated bool
+	}{
+		{"PUT", "/api/challenges/accept", `{"id": 1}`, http.StatusBadRequest, true},
+		{"PUT", "/api/challenges/decline", `{}`, http.StatusBadRequest, true},
+		{"GET", "/api/users/fred", "", http.StatusBadRequest, true},
+		{"GET", "/strava/update/users/status", "", 0, true},
+		{"POST", "/api/v2/challenges/bsonID/accept", "", http.StatusBadRequest, false},
+		{"POST", "/api/v2/challenges/bsonID/decline", "", http.StatusBadRequest, false},
+		{"POST", "/api/v2/challenges", `{"segmentId": 1}`, http.StatusBadRequest, false},
+		{"GET", "/api/v2/users/fred/challenges", "", http.StatusBadRequest, false},
+		{"GET", "/api/v2/users/1/challenges?status=lost", "", http.StatusBadRequest, false},
+		{"PUT", "/api/v2/challenges/accept", "", http.StatusMethodNotAllowed, false},
+	}
+	for _, tc := range tests {
+		req, err := http.NewRequest(tc.method, server.URL+tc.path, bytes.NewBufferString(tc.body))
+		if err != nil {
+			t.Error("unable to generate request", err)
+		}
+		if tc.status == 0 {
+			// only the headers are checked, the handler needs the database
+			req.Method = "OPTIONS"
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		resp.Body.Close()
+
+		if tc.status != 0 && resp.StatusCode != tc.status {
+			t.Errorf("%s %s: expected status code %v, got: %v", tc.method, tc.path, tc.status, resp.StatusCode)
+		}
+		if deprecated := resp.Header.Get("Deprecation") != ""; deprecated != tc.deprecated {
+			t.Errorf("%s %s: expected deprecated %v", tc.method, tc.path, tc.deprecated)
+		}
+		if tc.deprecated && resp.Header.Get("Sunset") == "" {
+			t.Errorf("%s %s: expected a Sunset header", tc.method, tc.path)
+		}
+	}
+}
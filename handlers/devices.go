@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+type deviceRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+// RegisterDevice stores a device token so the user receives push notifications,
+// a token another user registered is rejected with 409
+func RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	var req deviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error(models.InvalidError("Could not unmarshal request to register device", nil))
+		return
+	}
+	if req.Token == "" {
+		res.Error(models.InvalidError("device token is required", nil))
+		return
+	}
+	if req.Platform != models.PlatformIOS && req.Platform != models.PlatformAndroid {
+		res.Error(models.InvalidError("platform must be ios or android", nil))
+		return
+	}
+
+	device, err := models.RegisterDevice(numID, req.Token, req.Platform)
+	if err != nil {
+		res.Error(models.InternalError("unable to register device", err))
+		return
+	}
+	res.Render(http.StatusOK, device)
+}
+
+// RemoveDevice stops push notifications to a device token
+func RemoveDevice(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	if err := models.RemoveDevice(numID, chi.URLParam(r, "token")); err != nil {
+		res.Error(models.InternalError("unable to remove device", err))
+		return
+	}
+	res.Render(http.StatusOK, "device removed")
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestDevicesRequireAccessToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/{id}/devices", RegisterDevice)
+	r.Delete("/{id}/devices/{token}", RemoveDevice)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/1/devices", strings.NewReader(`{"token":"abc","platform":"ios"}`)),
+		httptest.NewRequest("DELETE", "/1/devices/abc", nil),
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if exp := http.StatusUnauthorized; rec.Code != exp {
+			t.Errorf("%s %s: expected status code %v, got: %v", req.Method, req.URL, exp, rec.Code)
+		}
+	}
+}
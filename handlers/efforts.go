@@ -2,10 +2,9 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
-	"github.com/go-chi/chi"
 	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
 	log "github.com/sirupsen/logrus"
 	"github.com/strava/go.strava"
 )
@@ -14,48 +13,33 @@ import (
 func GetEffortsBySegmentIDFromStravaWithUserID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to convert segment ID param",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
-	user, err := models.GetUserByID(numID)
+	// efforts are read with the users own Strava token
+	user, err := authenticateUser(r, numID)
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to retrieve user from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "unable to retrieve user from database"})
+		res.Error(err)
 		return
 	}
-	segmentID := chi.URLParam(r, "segmentID")
-	numSegmentID, err := strconv.ParseInt(segmentID, 10, 64)
+	numSegmentID, err := int64Param(r, "segmentID")
 	if err != nil {
-		log.WithField("Segment ID", numSegmentID).Debug("unable to convert Segment ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to convert Segment ID param",
-			"stack": err,
-		})
+		res.Error(err)
 		return
 	}
 
 	// use the users access token to grab segment effort info
-	client := strava.NewClient(user.Token)
+	client := newStravaClient(user.Token, ratelimit.Interactive)
 
 	log.Infof("Fetching segment %v info...", numSegmentID)
 	efforts, err := strava.NewSegmentsService(client).ListEfforts(numSegmentID).AthleteId(user.ID).Do()
 	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "Unable to retrieve segment efforts info",
-			"stack": err,
-		})
+		res.Error(models.InternalError("Unable to retrieve segment efforts info", err))
 		return
 	}
-	log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
 
 	res.Render(http.StatusOK, efforts)
 }
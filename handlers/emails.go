@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/jobs"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	"github.com/jrzimmerman/bestrida-server-go/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// mailer delivers notification and digest emails
+var mailer = notify.MailerFromEnv()
+
+// publicURL is where links in emails point
+var publicURL = utils.GetEnvStringDefault("PUBLIC_URL", "http://www.bestridaapp.com")
+
+// digestInterval is how often a user receives the digest, retries never send it twice
+const digestInterval = 7 * 24 * time.Hour
+
+func unsubscribeURL(token string) string {
+	return publicURL + v2Prefix + "/email/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// emailNotificationJob emails a queued notification to a user who opted in
+func emailNotificationJob(ctx context.Context, job *models.Job) error {
+	userID, err := jobs.PayloadInt64(job, "userId")
+	if err != nil {
+		return err
+	}
+	n, err := payloadNotification(job)
+	if err != nil {
+		return err
+	}
+
+	prefs, err := models.GetEmailPreferences(userID)
+	if err != nil {
+		return err
+	}
+	if !prefs.Notifications {
+		return nil
+	}
+	u, err := models.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if u.Email == "" {
+		log.WithField("USER ID", userID).Info("user has no email address, skipping notification email")
+		return nil
+	}
+
+	e, err := notify.RenderNotificationEmail(u.Email, n, unsubscribeURL(prefs.UnsubscribeToken))
+	if err != nil {
+		return err
+	}
+	return mailer.Send(e)
+}
+
+// emailDigestJob sends the weekly digest of pending, settled and expiring challenges
+func emailDigestJob(ctx context.Context, job *models.Job) error {
+	now := time.Now()
+	// allow a little slack so a late running job still sends next week
+	subscribers, err := models.GetDigestSubscribers(now.Add(-digestInterval + time.Hour))
+	if err != nil {
+		return err
+	}
+	log.Infof("sending weekly digest to %d users", len(subscribers))
+
+	var failed int
+	for _, prefs := range subscribers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := sendDigest(prefs, now); err != nil {
+			log.WithError(err).Errorf("unable to send digest to user %d", prefs.UserID)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("unable to send digest to %d of %d users", failed, len(subscribers))
+	}
+	return nil
+}
+
+func sendDigest(prefs models.EmailPreferences, now time.Time) error {
+	u, err := models.GetUserByID(prefs.UserID)
+	if err != nil {
+		return err
+	}
+	if u.Email == "" {
+		return models.MarkDigestSent(u.ID, now)
+	}
+
+	pending, err := models.GetPendingChallenges(u.ID)
+	if err != nil {
+		return err
+	}
+	active, err := models.GetActiveChallenges(u.ID)
+	if err != nil {
+		return err
+	}
+	var expiring []models.Challenge
+	for _, c := range *active {
+		if c.Expires != nil && c.Expires.Before(now.Add(digestInterval)) {
+			expiring = append(expiring, c)
+		}
+	}
+	settled, err := models.GetChallengesSettledSince(u.ID, now.Add(-digestInterval))
+	if err != nil {
+		return err
+	}
+
+	d := notify.NewDigest(u, *pending, settled, expiring)
+	if d.Empty() {
+		log.WithField("USER ID", u.ID).Info("nothing to include in digest")
+		return models.MarkDigestSent(u.ID, now)
+	}
+	e, err := notify.RenderDigestEmail(u.Email, d, unsubscribeURL(prefs.UnsubscribeToken))
+	if err != nil {
+		return err
+	}
+	if err := mailer.Send(e); err != nil {
+		return err
+	}
+	return models.MarkDigestSent(u.ID, now)
+}
+
+type emailPreferencesRequest struct {
+	Notifications bool `json:"notifications"`
+	Digest        bool `json:"digest"`
+}
+
+// GetEmailPreferences returns which emails a user receives
+func GetEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	prefs, err := models.GetEmailPreferences(numID)
+	if err != nil {
+		res.Error(models.InternalError("unable to get email preferences from database", err))
+		return
+	}
+	res.Render(http.StatusOK, prefs)
+}
+
+// UpdateEmailPreferences opts a user in or out of notification and digest emails
+func UpdateEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	var req emailPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error(models.InvalidError("Could not unmarshal request to update email preferences", nil))
+		return
+	}
+
+	prefs, err := models.SaveEmailPreferences(numID, req.Notifications, req.Digest)
+	if err != nil {
+		res.Error(models.InternalError("unable to save email preferences", err))
+		return
+	}
+	res.Render(http.StatusOK, prefs)
+}
+
+// UnsubscribeEmail opts a user out of all emails from the link in an email,
+// POST supports one click unsubscribe from mail clients
+func UnsubscribeEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "missing unsubscribe token", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.UnsubscribeEmail(token); err != nil {
+		if models.IsNotFound(err) {
+			http.Error(w, "unsubscribe link is invalid", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "unable to unsubscribe, please try again later", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("<!DOCTYPE html><html><body><p>You have been unsubscribed from Bestrida emails.</p></body></html>"))
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestUnsubscribeEmailMissingToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/unsubscribe", UnsubscribeEmail)
+	server := httptest.NewServer(r)
+
+	// Create the http request
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/unsubscribe", server.URL), nil)
+	if err != nil {
+		t.Error("unable to generate request", err)
+	}
+
+	// Send the request to the API
+	resp, err := http.DefaultClient.Do(req)
+
+	// Check the status code
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
+		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+	}
+}
+
+func TestEmailPreferencesRequireAccessToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/{id}/email", GetEmailPreferences)
+	r.Put("/{id}/email", UpdateEmailPreferences)
+
+	for _, method := range []string{"GET", "PUT"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, "/1/email", strings.NewReader(`{"notifications":true,"digest":true}`)))
+		if exp := http.StatusUnauthorized; rec.Code != exp {
+			t.Errorf("%s: expected status code %v, got: %v", method, exp, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jrzimmerman/bestrida-server-go/graph"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// graphSchema resolves the queries sent to /graphql
+var graphSchema = graph.NewSchema()
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQL executes a query over users, segments and challenges for the user the access token was issued to
+func GraphQL(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	viewer, err := authenticateToken(r)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	var req graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		res.Error(models.InvalidError("Could not unmarshal GraphQL query", nil))
+		return
+	}
+
+	response := graphSchema.Exec(graph.NewContext(r.Context(), viewer), req.Query, req.OperationName, req.Variables)
+	if len(response.Errors) > 0 {
+		log.WithField("USER ID", viewer.ID).Warnf("GraphQL query returned errors: %v", response.Errors)
+	}
+	res.Render(http.StatusOK, response)
+}
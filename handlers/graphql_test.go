@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+func TestGraphQLRequiresAccessToken(t *testing.T) {
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"query": "{ viewer { id } }"}`))
+	if err != nil {
+		t.Fatal("unable to send request", err)
+	}
+	defer resp.Body.Close()
+
+	if exp := http.StatusUnauthorized; resp.StatusCode != exp {
+		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+	}
+	var body ErrorBody
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Errorf("unable to decode response: %s", err)
+	}
+	if body.Code != models.KindUnauthorized {
+		t.Errorf("expected code %s, got %s", models.KindUnauthorized, body.Code)
+	}
+}
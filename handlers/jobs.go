@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jrzimmerman/bestrida-server-go/jobs"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// names of the background jobs run by the job runner
+const (
+	CompleteChallengesJob = "challenges.complete"
+	SyncFriendsJob        = "strava.friends"
+	SyncSegmentsJob       = "strava.segments"
+	SyncAllUsersJob       = "strava.users"
+	SegmentSweepJob       = "segments.sweep"
+	PushNotificationJob   = "notifications.push"
+	EmailNotificationJob  = "notifications.email"
+	EmailDigestJob        = "email.digest"
+	WebhookDeliveryJob    = "webhooks.deliver"
+	RemindChallengesJob   = "challenges.remind"
+)
+
+// jobRunner is used by handlers to enqueue background work
+var jobRunner *jobs.Runner
+
+// errJobsUnavailable is returned by handlers that start background work before the runner is registered
+var errJobsUnavailable = models.UnavailableError("background jobs are not running")
+
+// RegisterJobs registers all background job handlers with the runner
+func RegisterJobs(r *jobs.Runner) {
+	jobRunner = r
+	r.Register(CompleteChallengesJob, completeChallengesJob)
+	r.Register(SyncFriendsJob, syncFriendsJob)
+	r.Register(SyncSegmentsJob, syncSegmentsJob)
+	r.Register(SyncAllUsersJob, syncAllUsersJob)
+	r.Register(SegmentSweepJob, segmentSweepJob)
+	r.Register(PushNotificationJob, pushNotificationJob)
+	r.Register(EmailNotificationJob, emailNotificationJob)
+	r.Register(EmailDigestJob, emailDigestJob)
+	r.Register(WebhookDeliveryJob, webhookDeliveryJob)
+	r.Register(RemindChallengesJob, remindChallengesJob)
+}
+
+// enqueueJob adds a job to the queue, logging instead of failing the request
+func enqueueJob(name string, payload bson.M) {
+	if jobRunner == nil {
+		log.Errorf("job runner not registered, unable to enqueue job %s", name)
+		return
+	}
+	if _, err := jobRunner.Enqueue(name, payload); err != nil {
+		log.WithError(err).Errorf("unable to enqueue job %s", name)
+	}
+}
+
+func completeChallengesJob(ctx context.Context, job *models.Job) error {
+	return CronComplete(ctx)
+}
+
+func syncFriendsJob(ctx context.Context, job *models.Job) error {
+	userID, err := jobs.PayloadInt64(job, "userId")
+	if err != nil {
+		return err
+	}
+	_, err = GetFriendsFromStrava(userID, ratelimit.Bulk)
+	return err
+}
+
+func syncSegmentsJob(ctx context.Context, job *models.Job) error {
+	userID, err := jobs.PayloadInt64(job, "userId")
+	if err != nil {
+		return err
+	}
+	_, err = GetUserSegmentsFromStrava(userID, ratelimit.Bulk)
+	return err
+}
+
+// GetJobRuns returns the job run history
+func GetJobRuns(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			log.WithField("LIMIT", l).Error("unable to convert limit param")
+			res.Error(models.InvalidError("unable to convert limit param", nil))
+			return
+		}
+		limit = n
+	}
+
+	runs, err := models.GetJobRuns(r.URL.Query().Get("name"), limit)
+	if err != nil {
+		log.WithError(err).Error("unable to get job runs from database")
+		res.Error(models.InternalError("unable to get job runs from database", err))
+		return
+	}
+	res.Render(http.StatusOK, runs)
+}
+
+// GetDeadJobs returns jobs that exhausted their retries
+func GetDeadJobs(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	dead, err := models.GetJobsByStatus(models.JobDead, 100)
+	if err != nil {
+		log.WithError(err).Error("unable to get dead jobs from database")
+		res.Error(models.InternalError("unable to get dead jobs from database", err))
+		return
+	}
+	res.Render(http.StatusOK, dead)
+}
+
+// GetLeases returns the instance holding each distributed lease
+func GetLeases(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	leases, err := models.GetLeases()
+	if err != nil {
+		log.WithError(err).Error("unable to get leases from database")
+		res.Error(models.InternalError("unable to get leases from database", err))
+		return
+	}
+	res.Render(http.StatusOK, map[string]interface{}{
+		"instance": jobs.WorkerID(),
+		"leases":   leases,
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jrzimmerman/bestrida-server-go/jobs"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// notification inbox page sizes
+const (
+	defaultNotificationLimit = 20
+	maxNotificationLimit     = 100
+)
+
+// pushNotifier delivers push notifications to registered devices
+var pushNotifier = notify.FromEnv()
+
+// emitChallengeEvent notifies participants of a challenge lifecycle event.
+// The inbox entry and realtime event are sent right away, delivery happens on the job queue
+// so requests never wait on APNs, FCM or SMTP.
+func emitChallengeEvent(event string, c *models.Challenge, recipients ...int64) {
+	for _, id := range recipients {
+		n := notify.ChallengeNotification(event, c, id)
+		models.CreateNotification(models.Notification{
+			UserID:      id,
+			Type:        n.Type,
+			Title:       n.Title,
+			Body:        n.Body,
+			ChallengeID: c.ID,
+		})
+		enqueueJob(PushNotificationJob, bson.M{"userId": id, "notification": n})
+		enqueueJob(EmailNotificationJob, bson.M{"userId": id, "notification": n})
+		publishEvent(id, event, streamEvent{Notification: n, Challenge: c})
+	}
+	emitWebhookEvent(event, c)
+}
+
+// pushNotificationJob sends a queued notification to each of the users devices
+func pushNotificationJob(ctx context.Context, job *models.Job) error {
+	userID, err := jobs.PayloadInt64(job, "userId")
+	if err != nil {
+		return err
+	}
+	n, err := payloadNotification(job)
+	if err != nil {
+		return err
+	}
+	u, err := models.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !pushAllowed(u.CurrentSettings().Notifications, n.Type) {
+		log.WithField("USER ID", userID).Infof("user %d turned off %s push notifications", userID, n.Type)
+		return nil
+	}
+	return pushNotifier.Push(userID, n)
+}
+
+// payloadNotification reads the notification queued by emitChallengeEvent
+func payloadNotification(job *models.Job) (notify.Notification, error) {
+	var n notify.Notification
+	raw, err := bson.Marshal(job.Payload["notification"])
+	if err != nil {
+		return n, fmt.Errorf("job %s payload has an invalid notification: %v", job.Name, err)
+	}
+	if err := bson.Unmarshal(raw, &n); err != nil {
+		return n, fmt.Errorf("job %s payload has an invalid notification: %v", job.Name, err)
+	}
+	return n, nil
+}
+
+// GetNotificationsByUserID returns a page of the users notification inbox and their unread count
+func GetNotificationsByUserID(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	limit, err := parseLimit(r, defaultNotificationLimit, maxNotificationLimit)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	var before bson.ObjectId
+	if b := r.URL.Query().Get("before"); b != "" {
+		if !bson.IsObjectIdHex(b) {
+			res.Error(models.InvalidError("invalid before param", nil))
+			return
+		}
+		before = bson.ObjectIdHex(b)
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := models.GetNotifications(numID, before, unreadOnly, limit)
+	if err != nil {
+		res.Error(models.InternalError("unable to get notifications from database", err))
+		return
+	}
+	unread, err := models.CountUnreadNotifications(numID)
+	if err != nil {
+		res.Error(models.InternalError("unable to count unread notifications", err))
+		return
+	}
+
+	body := map[string]interface{}{
+		"notifications": notifications,
+		"unread":        unread,
+	}
+	if len(notifications) == limit {
+		body["next"] = notifications[len(notifications)-1].ID.Hex()
+	}
+	res.Render(http.StatusOK, body)
+}
+
+// MarkNotificationRead marks a single notification in the users inbox as read
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	notificationID, err := objectIDParam(r, "notificationID")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	if err := models.MarkNotificationRead(numID, notificationID); err != nil {
+		res.Error(models.InternalError("unable to mark notification read", err))
+		return
+	}
+	res.Render(http.StatusOK, "notification read")
+}
+
+// MarkAllNotificationsRead marks every notification in the users inbox as read
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	updated, err := models.MarkAllNotificationsRead(numID)
+	if err != nil {
+		res.Error(models.InternalError("unable to mark notifications read", err))
+		return
+	}
+	res.Render(http.StatusOK, map[string]interface{}{"updated": updated})
+}
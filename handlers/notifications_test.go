@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/realtime"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMarkNotificationReadInvalidID(t *testing.T) {
+	r := chi.NewRouter()
+	r.Put("/{id}/notifications/{notificationID}/read", MarkNotificationRead)
+	server := httptest.NewServer(r)
+
+	// Create the http request
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/1/notifications/invalid/read", server.URL), nil)
+	if err != nil {
+		t.Error("unable to generate request", err)
+	}
+
+	// Send the request to the API
+	resp, err := http.DefaultClient.Do(req)
+
+	// Check the status code
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
+		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+	}
+}
+
+func TestNotificationsRequireAccessToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/{id}/notifications", GetNotificationsByUserID)
+	r.Put("/{id}/notifications/read", MarkAllNotificationsRead)
+	r.Put("/{id}/notifications/{notificationID}/read", MarkNotificationRead)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/1/notifications", nil),
+		httptest.NewRequest("PUT", "/1/notifications/read", nil),
+		httptest.NewRequest("PUT", "/1/notifications/5a1b2c3d4e5f6a7b8c9d0e1f/read", nil),
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if exp := http.StatusUnauthorized; rec.Code != exp {
+			t.Errorf("%s %s: expected status code %v, got: %v", req.Method, req.URL, exp, rec.Code)
+		}
+	}
+}
+
+// TestAnonymousChallengeRequestsNotifyNoOne tests challenge requests without the acting user's
+// access token are rejected before any notification, push, email or webhook is sent
+func TestAnonymousChallengeRequestsNotifyNoOne(t *testing.T) {
+	defer func(h *realtime.Hub) { eventHub = h }(eventHub)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventHub = realtime.NewHub(realtime.NewLocalBackend())
+	eventHub.Start(ctx)
+	sub := eventHub.Subscribe(3)
+	defer eventHub.Unsubscribe(sub)
+
+	server := httptest.NewServer(API())
+	defer server.Close()
+	id := bson.NewObjectId().Hex()
+	create := fmt.Sprintf(`{"segmentId": 1, "challengerId": 2, "challengeeId": 3, "completionDate": %q}`,
+		time.Now().AddDate(0, 0, 3).Format(time.RFC3339))
+	for path, body := range map[string]string{
+		"/api/v2/challenges":                     create,
+		"/api/v2/challenges/" + id + "/accept":   "",
+		"/api/v2/challenges/" + id + "/decline":  "",
+		"/api/v2/challenges/" + id + "/complete": `{"userId": 3}`,
+	} {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		resp.Body.Close()
+		if exp := http.StatusUnauthorized; resp.StatusCode != exp {
+			t.Errorf("%s: expected status code %v, got: %v", path, exp, resp.StatusCode)
+		}
+	}
+
+	// events are delivered in order, anything the requests published arrives before this one
+	publishEvent(3, "test", struct{}{})
+	select {
+	case e := <-sub.C:
+		if e.Type != "test" {
+			t.Errorf("expected no events from anonymous requests, got: %s", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the test event to be delivered")
+	}
+}
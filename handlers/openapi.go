@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// schema is a JSON Schema object as used by OpenAPI 3.0
+type schema map[string]interface{}
+
+// apiParam is a query param of an operation
+type apiParam struct {
+	name        string
+	description string
+	schema      schema
+}
+
+// apiOperation documents a single route, request and response bodies are
+// either a schema or a value of the Go type the handler decodes or renders
+type apiOperation struct {
+	method   string
+	path     string
+	handler  http.HandlerFunc
+	summary  string
+	query    []apiParam
+	request  interface{}
+	status   int
+	response interface{}
+	auth     bool
+	// viewer marks operations where an optional access token decides what a private profile shows
+	viewer bool
+	// admin marks operational routes served through AdminOnly
+	admin bool
+}
+
+// deprecated reports whether the operation belongs to the v1 API
+func (op apiOperation) deprecated() bool {
+	if strings.HasPrefix(op.path, v2Prefix) || op.path == openAPIPath {
+		return false
+	}
+	return strings.HasPrefix(op.path, "/api/") || strings.HasPrefix(op.path, "/strava/")
+}
+
+// conditional reports whether ConditionalGet tags the response with an ETag,
+// it is mounted under /api only and event streams are flushed as they are written and never are
+func (op apiOperation) conditional() bool {
+	return op.method == http.MethodGet && op.status == http.StatusOK && strings.HasPrefix(op.path, "/api/") &&
+		!strings.HasSuffix(op.path, "/events")
+}
+
+// operationID is the name of the handler, suffixed with V1 for deprecated routes sharing a v2 handler
+func (op apiOperation) operationID() string {
+	name := runtime.FuncForPC(reflect.ValueOf(op.handler).Pointer()).Name()
+	name = name[strings.LastIndex(name, ".")+1:]
+	if op.deprecated() {
+		name += "V1"
+	}
+	return name
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  map[string]interface{}
+)
+
+// GetOpenAPI returns the OpenAPI 3 document describing every API route
+func GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+	openAPIOnce.Do(func() { openAPIDoc = openAPIDocument(apiOperations()) })
+	res.Render(http.StatusOK, openAPIDoc)
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPIDocument builds the document for ops, schemas are reflected from the Go types
+func openAPIDocument(ops []apiOperation) map[string]interface{} {
+	reg := &schemaRegistry{components: map[string]schema{}}
+	paths := map[string]map[string]interface{}{}
+	operationIDs := map[string]bool{}
+
+	for _, op := range ops {
+		var params []interface{}
+		for _, name := range pathParam.FindAllStringSubmatch(op.path, -1) {
+			params = append(params, schema{"name": name[1], "in": "path", "required": true, "schema": pathParamSchema(op.path, name[1])})
+		}
+		for _, p := range op.query {
+			params = append(params, schema{"name": p.name, "in": "query", "description": p.description, "schema": p.schema})
+		}
+
+		responses := schema{"default": schema{"$ref": "#/components/responses/Error"}}
+		success := schema{"description": http.StatusText(op.status)}
+		if op.response != nil {
+			success["content"] = schema{"application/json": schema{"schema": reg.body(op.response)}}
+		}
+		responses[fmt.Sprint(op.status)] = success
+		if op.conditional() {
+			success["headers"] = schema{"ETag": schema{"$ref": "#/components/headers/ETag"}}
+			responses[fmt.Sprint(http.StatusNotModified)] = schema{"$ref": "#/components/responses/NotModified"}
+			params = append(params, schema{"$ref": "#/components/parameters/IfNoneMatch"})
+		}
+
+		// routes serving the same handler for several methods are told apart by the method
+		id := op.operationID()
+		if operationIDs[id] {
+			id += strings.Title(strings.ToLower(op.method))
+		}
+		operationIDs[id] = true
+
+		operation := schema{
+			"operationId": id,
+			"summary":     op.summary,
+			"tags":        []string{operationTag(op.path)},
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.request != nil {
+			operation["requestBody"] = schema{
+				"required": true,
+				"content":  schema{"application/json": schema{"schema": reg.body(op.request)}},
+			}
+		}
+		switch {
+		case op.admin:
+			operation["security"] = []schema{{"adminAuth": []string{}}}
+		case op.auth:
+			operation["security"] = []schema{{"bearerAuth": []string{}}, {"accessToken": []string{}}}
+		case op.viewer:
+			operation["security"] = []schema{{"bearerAuth": []string{}}, {"accessToken": []string{}}, {}}
+		}
+		if op.deprecated() {
+			operation["deprecated"] = true
+		}
+
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]interface{}{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = operation
+	}
+
+	reg.components["Error"] = reg.of(reflect.TypeOf(ErrorBody{}))
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":       "Bestrida API",
+			"version":     "2.0.0",
+			"description": "Challenge your Strava friends on the segments you ride and run. The v1 routes are deprecated in favour of " + v2Prefix + ".",
+		},
+		"servers": []schema{{"url": "/"}},
+		"paths":   paths,
+		"components": schema{
+			"schemas": reg.components,
+			"parameters": schema{
+				"IfNoneMatch": schema{
+					"name":        "If-None-Match",
+					"in":          "header",
+					"description": "ETag of the response the client already has, a match returns 304",
+					"schema":      schema{"type": "string"},
+				},
+			},
+			"headers": schema{
+				"ETag": schema{"description": "Strong validator hashed from the response body", "schema": schema{"type": "string"}},
+			},
+			"responses": schema{
+				"NotModified": schema{
+					"description": "Not Modified",
+					"headers":     schema{"ETag": schema{"$ref": "#/components/headers/ETag"}},
+				},
+				"Error": schema{
+					"description": "Error",
+					"content":     schema{"application/json": schema{"schema": schema{"$ref": "#/components/schemas/Error"}}},
+				},
+			},
+			"securitySchemes": schema{
+				"bearerAuth":  schema{"type": "http", "scheme": "bearer"},
+				"accessToken": schema{"type": "apiKey", "in": "query", "name": "access_token"},
+				"adminAuth":   schema{"type": "http", "scheme": "bearer", "description": "The ADMIN_TOKEN the server runs with"},
+			},
+		},
+	}
+}
+
+// operationTag groups operations by the resource they act on
+func operationTag(path string) string {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, v2Prefix), "/api"), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		switch part := parts[i]; {
+		case part == "" || strings.HasPrefix(part, "{") || part == "openapi.json":
+		case part == "users" || part == "segments" || part == "challenges" || part == "jobs" || part == "strava" ||
+			part == "notifications" || part == "webhooks" || part == "devices" || part == "email" || part == "athletes":
+			return part
+		}
+	}
+	return "meta"
+}
+
+// pathParamSchema returns the schema of a path param, challenge, notification and webhook IDs are Object IDs
+func pathParamSchema(path, name string) schema {
+	switch {
+	case name == "token":
+		return schema{"type": "string"}
+	case name == "notificationID" || name == "webhookID" || (name == "id" && strings.Contains(path, "/challenges/{id}")):
+		return objectIDSchema
+	}
+	return schema{"type": "integer", "format": "int64"}
+}
+
+var objectIDSchema = schema{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+
+// schemaRegistry reflects Go types into named component schemas
+type schemaRegistry struct {
+	components map[string]schema
+}
+
+// body returns the schema of a request or response body
+func (reg *schemaRegistry) body(v interface{}) schema {
+	if s, ok := v.(schema); ok {
+		return reg.resolve(s)
+	}
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		// handlers render a pointer to a value they found or created, never nil
+		t = t.Elem()
+	}
+	return reg.of(t)
+}
+
+// value returns the schema of a property or item, pointers are nullable
+func (reg *schemaRegistry) value(v interface{}) schema {
+	if s, ok := v.(schema); ok {
+		return reg.resolve(s)
+	}
+	return reg.of(reflect.TypeOf(v))
+}
+
+// resolve replaces the Go values in the properties and items of a hand written schema with their schemas
+func (reg *schemaRegistry) resolve(s schema) schema {
+	out := schema{}
+	for k, v := range s {
+		switch k {
+		case "properties":
+			props := schema{}
+			for name, p := range v.(properties) {
+				props[name] = reg.value(p)
+			}
+			out[k] = props
+		case "items":
+			out[k] = reg.value(v)
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// properties of a hand written object schema, values are schemas or values of Go types
+type properties map[string]interface{}
+
+// object returns the schema of a response body that is not a Go struct
+func object(props properties, required ...string) schema {
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// arrayOf returns the schema of an array of v
+func arrayOf(v interface{}) schema {
+	return schema{"type": "array", "items": v}
+}
+
+// pageOf returns the schema of a page of a list endpoint
+func pageOf(item interface{}) schema {
+	return object(properties{"items": arrayOf(item), "total": 0, "next": ""}, "items", "total")
+}
+
+// extraProperties documents fields added by custom JSON marshalers
+var extraProperties = map[reflect.Type]schema{
+	reflect.TypeOf(models.Challenge{}): {
+		"createdLocal": schema{"type": "string", "format": "date-time"},
+		"expiresLocal": schema{"type": "string", "format": "date-time"},
+	},
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(bson.ObjectId(""))
+)
+
+// of returns the schema of t, structs are registered as components and referenced
+func (reg *schemaRegistry) of(t reflect.Type) schema {
+	switch t {
+	case timeType:
+		return schema{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return objectIDSchema
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(reg.of(t.Elem()))
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return schema{"type": "string", "format": "byte"}
+		}
+		return schema{"type": "array", "items": reg.of(t.Elem()), "nullable": t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": reg.of(t.Elem()), "nullable": true}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := reg.components[name]; !ok {
+			// registered before the fields so recursive types terminate
+			reg.components[name] = schema{}
+			reg.components[name] = reg.object(t)
+		}
+		return schema{"$ref": "#/components/schemas/" + name}
+	}
+	return schema{}
+}
+
+// object returns the schema of a struct from its JSON field tags,
+// fields without omitempty are always rendered and are required
+func (reg *schemaRegistry) object(t reflect.Type) schema {
+	props := schema{}
+	var required []string
+	var fields func(t reflect.Type)
+	fields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, opts = tag[:i], tag[i:]
+			}
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				fields(f.Type)
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = reg.of(f.Type)
+			if !strings.Contains(opts, ",omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	fields(t)
+	for name, s := range extraProperties[t] {
+		props[name] = s
+	}
+
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+// nullable marks a schema as accepting null, references are wrapped as siblings of $ref are ignored
+func nullable(s schema) schema {
+	if _, ok := s["$ref"]; ok {
+		return schema{"allOf": []schema{s}, "nullable": true}
+	}
+	out := schema{"nullable": true}
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// componentName names the schema of a struct, types outside the models package are prefixed with their package
+func componentName(t reflect.Type) string {
+	name := strings.Title(t.Name())
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "models" || pkg == "handlers" {
+		return name
+	}
+	return strings.Title(strings.TrimPrefix(pkg, "go.")) + name
+}
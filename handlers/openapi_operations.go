@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	"github.com/jrzimmerman/bestrida-server-go/segments"
+	strava "github.com/strava/go.strava"
+)
+
+// openAPIPath serves the OpenAPI document, it is not versioned
+const openAPIPath = "/api/openapi.json"
+
+var (
+	limitParam  = apiParam{"limit", "maximum number of items in the page", schema{"type": "integer", "minimum": 1, "maximum": maxPageLimit}}
+	cursorParam = apiParam{"cursor", "next cursor of the previous page", schema{"type": "string"}}
+)
+
+func sortParam(options ...string) apiParam {
+	var values []string
+	for _, o := range options {
+		values = append(values, o, "-"+o)
+	}
+	return apiParam{"sort", "sort field, prefixed with - for descending order", schema{"type": "string", "enum": values}}
+}
+
+// message is the body of responses that only confirm an action
+var message = schema{"type": "string"}
+
+type completeChallengeRequest struct {
+	UserID int64 `json:"userId"`
+}
+
+// apiOperations documents every route registered by API, the contract tests
+// fail when a route is added without documenting it here
+func apiOperations() []apiOperation {
+	challengeList := []apiParam{
+		{"status", "only challenges with this status for the user", schema{"type": "string", "enum": []string{"pending", "active", "completed"}}},
+		{"opponent", "only challenges against this user ID", schema{"type": "integer", "format": "int64"}},
+		{"segment", "only challenges on this segment ID", schema{"type": "integer", "format": "int64"}},
+		{"activityType", "only challenges on segments of this activity type", schema{"type": "string"}},
+		{"from", "only challenges created at or after this date", schema{"type": "string", "format": "date-time"}},
+		{"to", "only challenges created before this date", schema{"type": "string", "format": "date-time"}},
+		sortParam("expires", "created", "updated"), limitParam, cursorParam,
+	}
+	notificationList := []apiParam{
+		{"limit", "maximum number of notifications", schema{"type": "integer", "minimum": 1, "maximum": maxNotificationLimit}},
+		{"before", "only notifications older than this notification ID", objectIDSchema},
+		{"unread", "only unread notifications", schema{"type": "boolean"}},
+	}
+	search := []apiParam{
+		{"q", "words to match against user names and cities and segment names, cities and states", schema{"type": "string", "maxLength": maxSearchQuery}},
+		{"limit", "maximum number of users and of segments", schema{"type": "integer", "minimum": 1, "maximum": maxSearchLimit}},
+	}
+	unsubscribe := []apiParam{{"token", "unsubscribe token from the email", schema{"type": "string"}}}
+	jobRuns := []apiParam{
+		{"name", "only runs of this job", schema{"type": "string"}},
+		{"limit", "maximum number of runs", schema{"type": "integer", "minimum": 1}},
+	}
+	notifications := object(properties{"notifications": []models.Notification{}, "unread": 0, "next": ""}, "notifications", "unread")
+	leases := object(properties{"instance": "", "leases": []models.Lease{}}, "instance", "leases")
+	syncStatus := object(properties{"checkpoint": &models.SyncCheckpoint{}, "failed": []models.UserSync{}}, "checkpoint", "failed")
+	webhookCreated := object(properties{"webhook": &models.Webhook{}, "secret": ""}, "webhook", "secret")
+	timeZone := object(properties{"timeZone": ""}, "timeZone")
+	// a JSON Merge Patch sends only the settings it changes, null resets one to its default
+	settingsPatch := object(properties{"notifications": models.NotificationSettings{}, "challengeDays": 0, "privacy": "", "units": "", "hideFromLeaderboards": false, "timeZone": ""})
+	health := object(properties{"status": ""}, "status")
+	updated := object(properties{"updated": 0}, "updated")
+	graphQLResponse := object(properties{"data": schema{"type": "object", "nullable": true}, "errors": arrayOf(schema{"type": "object"})})
+	events := schema{"type": "string", "description": "text/event-stream of challenge and notification events"}
+
+	ops := []apiOperation{
+		{method: "GET", path: openAPIPath, handler: GetOpenAPI, summary: "OpenAPI document of the API", status: http.StatusOK, response: schema{"type": "object"}},
+		{method: "POST", path: "/graphql", handler: GraphQL, summary: "GraphQL query over users, segments and challenges", request: graphQLRequest{}, status: http.StatusOK, response: graphQLResponse, auth: true},
+	}
+
+	// v2
+	ops = append(ops, []apiOperation{
+		{method: "GET", path: "/api/v2/health", handler: GetHealthCheck, summary: "Health check", status: http.StatusOK, response: health},
+		{method: "GET", path: "/api/v2/email/unsubscribe", handler: UnsubscribeEmail, summary: "Unsubscribe from all emails, returns an HTML page", query: unsubscribe, status: http.StatusOK},
+		{method: "POST", path: "/api/v2/email/unsubscribe", handler: UnsubscribeEmail, summary: "One click unsubscribe from all emails", query: unsubscribe, status: http.StatusOK},
+		{method: "GET", path: "/api/v2/jobs/runs", handler: GetJobRuns, summary: "Recent background job runs", query: jobRuns, status: http.StatusOK, response: []models.JobRun{}, admin: true},
+		{method: "GET", path: "/api/v2/jobs/dead", handler: GetDeadJobs, summary: "Background jobs that exhausted their retries", status: http.StatusOK, response: []models.Job{}, admin: true},
+		{method: "GET", path: "/api/v2/jobs/leases", handler: GetLeases, summary: "Instances holding each distributed lease", status: http.StatusOK, response: leases, admin: true},
+		{method: "GET", path: "/api/v2/strava/quota", handler: GetStravaQuota, summary: "Strava API quota usage", status: http.StatusOK, response: ratelimit.Usage{}, admin: true},
+		{method: "POST", path: "/api/v2/strava/sync", handler: UpdateAllUsersFromStrava, summary: "Start a sync of all users from Strava", status: http.StatusAccepted, response: &models.Job{}, admin: true},
+		{method: "GET", path: "/api/v2/strava/sync", handler: GetUserSyncStatus, summary: "Progress of the all users sync", status: http.StatusOK, response: syncStatus, admin: true},
+
+		{method: "GET", path: "/api/v2/search", handler: Search, summary: "Search users and cached segments, friends first", query: search, status: http.StatusOK, response: searchResults{}, viewer: true},
+		{method: "GET", path: "/api/v2/users/{id}", handler: GetUserByID, summary: "Get a user", status: http.StatusOK, response: &models.User{}, viewer: true},
+		{method: "GET", path: "/api/v2/users/{id}/friends", handler: ListFriendsByUserID, summary: "List a users friends", query: []apiParam{sortParam("name", "challenges", "wins", "losses"), limitParam, cursorParam}, status: http.StatusOK, response: pageOf(&models.Friend{}), viewer: true},
+		{method: "GET", path: "/api/v2/users/{id}/segments", handler: ListSegmentsByUserID, summary: "List a users segments", query: []apiParam{{"activityType", "only segments of this activity type", schema{"type": "string"}}, sortParam("name", "count"), limitParam, cursorParam}, status: http.StatusOK, response: pageOf(&models.UserSegment{}), viewer: true},
+		{method: "GET", path: "/api/v2/users/{id}/segments/{segmentID}/efforts", handler: GetEffortsBySegmentIDFromStravaWithUserID, summary: "A users efforts on a segment from Strava", status: http.StatusOK, response: []*strava.SegmentEffortSummary{}, auth: true},
+		{method: "GET", path: "/api/v2/users/{id}/challenges", handler: GetChallengesByUserID, summary: "List a users challenges", query: challengeList, status: http.StatusOK, response: pageOf(models.Challenge{}), viewer: true},
+		{method: "GET", path: "/api/v2/users/{id}/events", handler: StreamEvents, summary: "Stream a users events as Server-Sent Events", status: http.StatusOK, response: events, auth: true},
+		{method: "PUT", path: "/api/v2/users/{id}/timezone", handler: UpdateUserTimeZone, summary: "Set the time zone challenge windows are computed in", request: timeZoneRequest{}, status: http.StatusOK, response: timeZone, auth: true},
+		{method: "GET", path: "/api/v2/users/{id}/settings", handler: GetUserSettings, summary: "Get a users settings", status: http.StatusOK, response: models.UserSettings{}, auth: true},
+		{method: "PATCH", path: "/api/v2/users/{id}/settings", handler: UpdateUserSettings, summary: "Update a users settings with a JSON Merge Patch", request: settingsPatch, status: http.StatusOK, response: models.UserSettings{}, auth: true},
+		{method: "GET", path: "/api/v2/users/{id}/email", handler: GetEmailPreferences, summary: "Get a users email preferences", status: http.StatusOK, response: &models.EmailPreferences{}, auth: true},
+		{method: "PUT", path: "/api/v2/users/{id}/email", handler: UpdateEmailPreferences, summary: "Update a users email preferences", request: emailPreferencesRequest{}, status: http.StatusOK, response: &models.EmailPreferences{}, auth: true},
+		{method: "POST", path: "/api/v2/users/{id}/sync/athlete", handler: GetAthleteByIDFromStrava, summary: "Sync a user from Strava", status: http.StatusOK, response: &models.User{}, auth: true},
+		{method: "POST", path: "/api/v2/users/{id}/sync/friends", handler: GetFriendsByUserIDFromStrava, summary: "Sync a users friends from Strava", status: http.StatusOK, response: []*models.Friend{}, auth: true},
+		{method: "POST", path: "/api/v2/users/{id}/sync/segments", handler: GetSegmentsByUserIDFromStrava, summary: "Sync a users segments from Strava", status: http.StatusOK, response: []*models.UserSegment{}, auth: true},
+		{method: "GET", path: "/api/v2/users/{id}/notifications", handler: GetNotificationsByUserID, summary: "A page of a users notifications", query: notificationList, status: http.StatusOK, response: notifications, auth: true},
+		{method: "PUT", path: "/api/v2/users/{id}/notifications/read", handler: MarkAllNotificationsRead, summary: "Mark all notifications read", status: http.StatusOK, response: updated, auth: true},
+		{method: "PUT", path: "/api/v2/users/{id}/notifications/{notificationID}/read", handler: MarkNotificationRead, summary: "Mark a notification read", status: http.StatusOK, response: message, auth: true},
+		{method: "GET", path: "/api/v2/users/{id}/webhooks", handler: GetWebhooksByUserID, summary: "List a users webhooks", status: http.StatusOK, response: []models.Webhook{}, auth: true},
+		{method: "POST", path: "/api/v2/users/{id}/webhooks", handler: CreateWebhook, summary: "Register a webhook", request: webhookRequest{}, status: http.StatusCreated, response: webhookCreated, auth: true},
+		{method: "DELETE", path: "/api/v2/users/{id}/webhooks/{webhookID}", handler: RemoveWebhook, summary: "Remove a webhook", status: http.StatusOK, response: message, auth: true},
+		{method: "GET", path: "/api/v2/users/{id}/webhooks/{webhookID}/deliveries", handler: GetWebhookDeliveries, summary: "Recent deliveries of a webhook", status: http.StatusOK, response: []models.WebhookDelivery{}, auth: true},
+		{method: "POST", path: "/api/v2/users/{id}/devices", handler: RegisterDevice, summary: "Register a device for push notifications", request: deviceRequest{}, status: http.StatusOK, response: &models.Device{}, auth: true},
+		{method: "DELETE", path: "/api/v2/users/{id}/devices/{token}", handler: RemoveDevice, summary: "Stop push notifications to a device", status: http.StatusOK, response: message, auth: true},
+
+		{method: "GET", path: "/api/v2/segments/cache", handler: GetSegmentCacheMetrics, summary: "Segment cache metrics", status: http.StatusOK, response: segments.Metrics{}, admin: true},
+		{method: "GET", path: "/api/v2/segments/{id}", handler: GetSegmentByID, summary: "Get a segment", status: http.StatusOK, response: &models.Segment{}},
+		{method: "GET", path: "/api/v2/segments/{id}/strava", handler: GetSegmentByIDFromStrava, summary: "Get a segment from Strava", status: http.StatusOK, response: &models.Segment{}},
+
+		{method: "POST", path: "/api/v2/challenges", handler: PostChallenge, summary: "Create a challenge", request: createRequest{}, status: http.StatusCreated, response: &models.Challenge{}, auth: true},
+		{method: "GET", path: "/api/v2/challenges/{id}", handler: GetChallengeByID, summary: "Get a challenge", status: http.StatusOK, response: &models.Challenge{}, viewer: true},
+		{method: "POST", path: "/api/v2/challenges/{id}/accept", handler: AcceptChallenge, summary: "Accept a challenge", status: http.StatusOK, response: &models.Challenge{}, auth: true},
+		{method: "POST", path: "/api/v2/challenges/{id}/decline", handler: DeclineChallenge, summary: "Decline a challenge", status: http.StatusOK, response: &models.Challenge{}, auth: true},
+		{method: "POST", path: "/api/v2/challenges/{id}/complete", handler: CompleteChallenge, summary: "Record a users effort on a challenge", request: completeChallengeRequest{}, status: http.StatusOK, response: &models.Challenge{}, auth: true},
+	}...)
+
+	// v1
+	ops = append(ops, []apiOperation{
+		{method: "GET", path: "/api/health", handler: GetHealthCheck, summary: "Health check", status: http.StatusOK, response: health},
+		{method: "GET", path: "/api/email/unsubscribe", handler: UnsubscribeEmail, summary: "Unsubscribe from all emails, returns an HTML page", query: unsubscribe, status: http.StatusOK},
+		{method: "POST", path: "/api/email/unsubscribe", handler: UnsubscribeEmail, summary: "One click unsubscribe from all emails", query: unsubscribe, status: http.StatusOK},
+		{method: "GET", path: "/api/jobs/runs", handler: GetJobRuns, summary: "Recent background job runs", query: jobRuns, status: http.StatusOK, response: []models.JobRun{}, admin: true},
+		{method: "GET", path: "/api/jobs/dead", handler: GetDeadJobs, summary: "Background jobs that exhausted their retries", status: http.StatusOK, response: []models.Job{}, admin: true},
+		{method: "GET", path: "/api/jobs/leases", handler: GetLeases, summary: "Instances holding each distributed lease", status: http.StatusOK, response: leases, admin: true},
+
+		{method: "GET", path: "/api/search", handler: Search, summary: "Search users and cached segments, friends first", query: search, status: http.StatusOK, response: searchResults{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}", handler: GetUserByID, summary: "Get a user", status: http.StatusOK, response: &models.User{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}/friends", handler: GetFriendsByUserID, summary: "All of a users friends", status: http.StatusOK, response: []*models.Friend{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}/events", handler: StreamEvents, summary: "Stream a users events as Server-Sent Events", status: http.StatusOK, response: events, auth: true},
+		{method: "PUT", path: "/api/users/{id}/timezone", handler: UpdateUserTimeZone, summary: "Set the time zone challenge windows are computed in", request: timeZoneRequest{}, status: http.StatusOK, response: timeZone, auth: true},
+		{method: "GET", path: "/api/users/{id}/settings", handler: GetUserSettings, summary: "Get a users settings", status: http.StatusOK, response: models.UserSettings{}, auth: true},
+		{method: "PATCH", path: "/api/users/{id}/settings", handler: UpdateUserSettings, summary: "Update a users settings with a JSON Merge Patch", request: settingsPatch, status: http.StatusOK, response: models.UserSettings{}, auth: true},
+		{method: "GET", path: "/api/users/{id}/email", handler: GetEmailPreferences, summary: "Get a users email preferences", status: http.StatusOK, response: &models.EmailPreferences{}, auth: true},
+		{method: "PUT", path: "/api/users/{id}/email", handler: UpdateEmailPreferences, summary: "Update a users email preferences", request: emailPreferencesRequest{}, status: http.StatusOK, response: &models.EmailPreferences{}, auth: true},
+		{method: "GET", path: "/api/users/{id}/notifications", handler: GetNotificationsByUserID, summary: "A page of a users notifications", query: notificationList, status: http.StatusOK, response: notifications, auth: true},
+		{method: "PUT", path: "/api/users/{id}/notifications/read", handler: MarkAllNotificationsRead, summary: "Mark all notifications read", status: http.StatusOK, response: updated, auth: true},
+		{method: "PUT", path: "/api/users/{id}/notifications/{notificationID}/read", handler: MarkNotificationRead, summary: "Mark a notification read", status: http.StatusOK, response: message, auth: true},
+		{method: "GET", path: "/api/users/{id}/webhooks", handler: GetWebhooksByUserID, summary: "List a users webhooks", status: http.StatusOK, response: []models.Webhook{}, auth: true},
+		{method: "POST", path: "/api/users/{id}/webhooks", handler: CreateWebhook, summary: "Register a webhook", request: webhookRequest{}, status: http.StatusCreated, response: webhookCreated, auth: true},
+		{method: "DELETE", path: "/api/users/{id}/webhooks/{webhookID}", handler: RemoveWebhook, summary: "Remove a webhook", status: http.StatusOK, response: message, auth: true},
+		{method: "GET", path: "/api/users/{id}/webhooks/{webhookID}/deliveries", handler: GetWebhookDeliveries, summary: "Recent deliveries of a webhook", status: http.StatusOK, response: []models.WebhookDelivery{}, auth: true},
+		{method: "POST", path: "/api/users/{id}/devices", handler: RegisterDevice, summary: "Register a device for push notifications", request: deviceRequest{}, status: http.StatusOK, response: &models.Device{}, auth: true},
+		{method: "DELETE", path: "/api/users/{id}/devices/{token}", handler: RemoveDevice, summary: "Stop push notifications to a device", status: http.StatusOK, response: message, auth: true},
+		{method: "GET", path: "/api/users/{id}/segments", handler: GetSegmentsByUserID, summary: "All of a users segments", status: http.StatusOK, response: []*models.UserSegment{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}/segments/{segmentID}", handler: GetSegmentByIDWithUserID, summary: "Not implemented, returns an empty response", status: http.StatusOK, auth: true},
+		{method: "GET", path: "/api/users/{id}/segments/{segmentID}/strava", handler: GetSegmentByIDFromStravaWithUserID, summary: "Not implemented, returns an empty response", status: http.StatusOK, auth: true},
+		{method: "GET", path: "/api/users/{id}/segments/{segmentID}/efforts", handler: GetEffortsBySegmentIDFromStravaWithUserID, summary: "A users efforts on a segment from Strava", status: http.StatusOK, response: []*strava.SegmentEffortSummary{}, auth: true},
+		{method: "GET", path: "/api/users/{id}/challenges", handler: GetAllChallengesByUserID, summary: "All of a users challenges, the first page only", query: []apiParam{limitParam}, status: http.StatusOK, response: []models.Challenge{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}/challenges/pending", handler: GetPendingChallengesByUserID, summary: "A users pending challenges, the first page only", query: []apiParam{limitParam}, status: http.StatusOK, response: []models.Challenge{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}/challenges/active", handler: GetActiveChallengesByUserID, summary: "A users active challenges, the first page only", query: []apiParam{limitParam}, status: http.StatusOK, response: []models.Challenge{}, viewer: true},
+		{method: "GET", path: "/api/users/{id}/challenges/completed", handler: GetCompletedChallengesByUserID, summary: "A users completed challenges, the first page only", query: []apiParam{limitParam}, status: http.StatusOK, response: []models.Challenge{}, viewer: true},
+
+		{method: "GET", path: "/api/segments/cache", handler: GetSegmentCacheMetrics, summary: "Segment cache metrics", status: http.StatusOK, response: segments.Metrics{}, admin: true},
+		{method: "GET", path: "/api/segments/{id}", handler: GetSegmentByID, summary: "Get a segment", status: http.StatusOK, response: &models.Segment{}},
+		{method: "GET", path: "/api/segments/{id}/strava", handler: GetSegmentByIDFromStrava, summary: "Get a segment from Strava", status: http.StatusOK, response: &models.Segment{}},
+
+		{method: "GET", path: "/api/challenges/{id}", handler: GetChallengeByID, summary: "Get a challenge", status: http.StatusOK, response: &models.Challenge{}, viewer: true},
+		{method: "PUT", path: "/api/challenges/accept", handler: AcceptChallengeByID, summary: "Accept a challenge", request: updateRequest{}, status: http.StatusOK, response: message, auth: true},
+		{method: "PUT", path: "/api/challenges/decline", handler: DeclineChallengeByID, summary: "Decline a challenge", request: updateRequest{}, status: http.StatusOK, response: message, auth: true},
+		{method: "PUT", path: "/api/challenges/complete", handler: CompleteChallengeByID, summary: "Record a users effort on a challenge", request: completeRequest{}, status: http.StatusOK, response: &models.Challenge{}, auth: true},
+		{method: "POST", path: "/api/challenges/create", handler: CreateChallenge, summary: "Create a challenge", request: createRequest{}, status: http.StatusOK, response: &models.Challenge{}, auth: true},
+
+		{method: "GET", path: "/api/athletes/{id}", handler: GetAthleteByIDFromStrava, summary: "Sync a user from Strava", status: http.StatusOK, response: &models.User{}, auth: true},
+		{method: "GET", path: "/api/athletes/{id}/friends", handler: GetFriendsByUserIDFromStrava, summary: "Sync a users friends from Strava", status: http.StatusOK, response: []*models.Friend{}, auth: true},
+		{method: "GET", path: "/api/athletes/{id}/segments", handler: GetSegmentsByUserIDFromStrava, summary: "Sync a users segments from Strava", status: http.StatusOK, response: []*models.UserSegment{}, auth: true},
+
+		{method: "GET", path: "/strava/quota", handler: GetStravaQuota, summary: "Strava API quota usage", status: http.StatusOK, response: ratelimit.Usage{}, admin: true},
+		{method: "POST", path: "/strava/update/users", handler: UpdateAllUsersFromStrava, summary: "Start a sync of all users from Strava", status: http.StatusAccepted, response: &models.Job{}, admin: true},
+		{method: "GET", path: "/strava/update/users/status", handler: GetUserSyncStatus, summary: "Progress of the all users sync", status: http.StatusOK, response: syncStatus, admin: true},
+	}...)
+	return ops
+}
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/realtime"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// undocumentedRoutes are browser pages rather than API routes
+var undocumentedRoutes = regexp.MustCompile(`^(/|/\*|/strava/auth.*)$`)
+
+// loadOpenAPI returns the document as clients decode it
+func loadOpenAPI(t *testing.T) map[string]interface{} {
+	w := httptest.NewRecorder()
+	GetOpenAPI(w, httptest.NewRequest("GET", openAPIPath, nil))
+	var doc map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("unable to decode OpenAPI document: %s", err)
+	}
+	return doc
+}
+
+// TestOpenAPIDocumentsRoutes tests every route is documented with the handler it is served by
+func TestOpenAPIDocumentsRoutes(t *testing.T) {
+	documented := map[string]uintptr{}
+	for _, op := range apiOperations() {
+		key := op.method + " " + op.path
+		if _, ok := documented[key]; ok {
+			t.Errorf("%s documented twice", key)
+		}
+		documented[key] = reflect.ValueOf(op.handler).Pointer()
+	}
+
+	routed := map[string]bool{}
+	err := chi.Walk(API(), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if undocumentedRoutes.MatchString(route) {
+			return nil
+		}
+		key := method + " " + route
+		routed[key] = true
+		pointer, ok := documented[key]
+		if !ok {
+			t.Errorf("%s is not documented", key)
+		} else if pointer != reflect.ValueOf(handler).Pointer() {
+			t.Errorf("%s is documented with a different handler", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to walk routes: %s", err)
+	}
+	for key := range documented {
+		if !routed[key] {
+			t.Errorf("%s is documented but not routed", key)
+		}
+	}
+}
+
+// TestOpenAPIDocument tests the document is served and every reference resolves
+func TestOpenAPIDocument(t *testing.T) {
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + openAPIPath)
+	if err != nil {
+		t.Fatal("unable to send request", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") != "" {
+		t.Errorf("expected an undeprecated 200, got %v %q", resp.StatusCode, resp.Header.Get("Deprecation"))
+	}
+	var doc map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("unexpected version %v", doc["openapi"])
+	}
+
+	ids := map[string]bool{}
+	for path, methods := range doc["paths"].(map[string]interface{}) {
+		for method, op := range methods.(map[string]interface{}) {
+			id := op.(map[string]interface{})["operationId"].(string)
+			if ids[id] {
+				t.Errorf("%s %s: duplicate operation ID %s", method, path, id)
+			}
+			ids[id] = true
+			deprecated, _ := op.(map[string]interface{})["deprecated"].(bool)
+			if deprecated != (apiOperation{path: path}).deprecated() {
+				t.Errorf("%s %s: unexpected deprecated %v", method, path, deprecated)
+			}
+		}
+	}
+
+	for path, exp := range map[string]bool{"/api/health": true, "/strava/quota": true, "/api/v2/health": false, "/graphql": false, openAPIPath: false} {
+		if (apiOperation{path: path}).deprecated() != exp {
+			t.Errorf("%s: expected deprecated %v", path, exp)
+		}
+	}
+
+	var refs func(v interface{})
+	refs = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok && lookupRef(doc, ref) == nil {
+				t.Errorf("unresolved reference %s", ref)
+			}
+			for _, child := range v {
+				refs(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				refs(child)
+			}
+		}
+	}
+	refs(doc)
+}
+
+// TestOpenAPIErrorResponses tests the error body of every operation with a path ID matches the Error schema
+func TestOpenAPIErrorResponses(t *testing.T) {
+	doc := loadOpenAPI(t)
+	errorSchema := map[string]interface{}{"$ref": "#/components/schemas/Error"}
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	for _, op := range apiOperations() {
+		// event streams are not JSON and stub handlers write nothing
+		if !strings.Contains(op.path, "{id}") || op.response == nil || strings.HasSuffix(op.path, "/events") {
+			continue
+		}
+		path := pathParam.ReplaceAllString(op.path, "fred")
+		req, err := http.NewRequest(op.method, server.URL+path, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal("unable to generate request", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		var body interface{}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("%s %s: unable to decode response: %s", op.method, path, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status code %v, got: %v", op.method, path, http.StatusBadRequest, resp.StatusCode)
+		}
+		for _, problem := range validateSchema(doc, errorSchema, body, "body") {
+			t.Errorf("%s %s: %s", op.method, path, problem)
+		}
+	}
+}
+
+// viewerRoutes are the per user routes anonymous requests may call, they go through
+// checkVisible or render the user's ViewFor the viewer so private profiles show only a name
+var viewerRoutes = map[string]bool{
+	"GET /api/v2/users/{id}":                   true,
+	"GET /api/v2/users/{id}/friends":           true,
+	"GET /api/v2/users/{id}/segments":          true,
+	"GET /api/v2/users/{id}/challenges":        true,
+	"GET /api/users/{id}":                      true,
+	"GET /api/users/{id}/friends":              true,
+	"GET /api/users/{id}/segments":             true,
+	"GET /api/users/{id}/challenges":           true,
+	"GET /api/users/{id}/challenges/pending":   true,
+	"GET /api/users/{id}/challenges/active":    true,
+	"GET /api/users/{id}/challenges/completed": true,
+}
+
+// TestPerUserRoutesRequireAccess tests every route for a single user either requires the user's
+// access token or is one of the viewerRoutes
+func TestPerUserRoutesRequireAccess(t *testing.T) {
+	// requests must get past the hub and body checks some handlers make before authenticating
+	defer func(h *realtime.Hub) { eventHub = h }(eventHub)
+	eventHub = realtime.NewHub(realtime.NewLocalBackend())
+	bodies := map[string]string{
+		"/webhooks": `{"url":"https://example.com/hook","events":["challenge.created"]}`,
+	}
+	server := httptest.NewServer(API())
+	defer server.Close()
+	params := strings.NewReplacer("{id}", "1", "{segmentID}", "2", "{notificationID}", bson.NewObjectId().Hex(),
+		"{webhookID}", bson.NewObjectId().Hex(), "{token}", "device")
+
+	for _, op := range apiOperations() {
+		if !strings.Contains(op.path, "/users/{id}") && !strings.Contains(op.path, "/athletes/{id}") {
+			continue
+		}
+		key := op.method + " " + op.path
+		if viewerRoutes[key] {
+			if op.auth || !op.viewer {
+				t.Errorf("%s: expected to be documented as visible to viewers", key)
+			}
+			continue
+		}
+		if !op.auth {
+			t.Errorf("%s: expected to require the user's access token", key)
+		}
+
+		body, ok := bodies[op.path[strings.LastIndex(op.path, "/"):]]
+		if !ok {
+			body = "{}"
+		}
+		req, err := http.NewRequest(op.method, server.URL+params.Replace(op.path), strings.NewReader(body))
+		if err != nil {
+			t.Fatal("unable to generate request", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected status code %v without a token, got: %v", key, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+}
+
+// TestOpenAPIResponseSchemas tests the bodies handlers render match the documented schemas,
+// both fully populated and zero values of every Go type are rendered through Response
+func TestOpenAPIResponseSchemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+	paths := doc["paths"].(map[string]interface{})
+
+	for _, op := range apiOperations() {
+		if op.response == nil {
+			continue
+		}
+		if _, ok := op.response.(schema); ok {
+			continue
+		}
+		documented := paths[op.path].(map[string]interface{})[strings.ToLower(op.method)].(map[string]interface{})
+		content := documented["responses"].(map[string]interface{})[fmt.Sprint(op.status)].(map[string]interface{})["content"]
+		s := content.(map[string]interface{})["application/json"].(map[string]interface{})["schema"]
+
+		typ := reflect.TypeOf(op.response)
+		for _, sample := range []reflect.Value{reflect.Zero(typ), sampleValue(typ, 0)} {
+			if sample.Kind() == reflect.Ptr && sample.IsNil() {
+				continue
+			}
+			w := httptest.NewRecorder()
+			New(w).Render(op.status, sample.Interface())
+			var body interface{}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("%s %s: unable to decode response: %s", op.method, op.path, err)
+			}
+			for _, problem := range validateSchema(doc, s, body, "body") {
+				t.Errorf("%s %s: %s", op.method, op.path, problem)
+			}
+		}
+	}
+
+	// bodies without a Go type are checked against handlers that do not need the database
+	for _, tc := range []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/api/v2/health", GetHealthCheck},
+		{"/api/v2/strava/quota", GetStravaQuota},
+		{"/api/v2/segments/cache", GetSegmentCacheMetrics},
+	} {
+		w := httptest.NewRecorder()
+		tc.handler(w, httptest.NewRequest("GET", tc.path, nil))
+		var body interface{}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: unable to decode response: %s", tc.path, err)
+		}
+		get := paths[tc.path].(map[string]interface{})["get"].(map[string]interface{})
+		content := get["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"]
+		s := content.(map[string]interface{})["application/json"].(map[string]interface{})["schema"]
+		for _, problem := range validateSchema(doc, s, body, "body") {
+			t.Errorf("%s: %s", tc.path, problem)
+		}
+	}
+}
+
+// sampleValue returns a value of t with every field set
+func sampleValue(t reflect.Type, depth int) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		v.Set(reflect.ValueOf(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)))
+		return v
+	case t == reflect.TypeOf(bson.ObjectId("")):
+		v.Set(reflect.ValueOf(bson.NewObjectId()))
+		return v
+	case depth > 4:
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.Set(sampleValue(t.Elem(), depth+1).Addr())
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.String:
+		v.SetString("America/Los_Angeles")
+	case reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), sampleValue(t.Elem(), depth+1)))
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			v.Index(i).Set(sampleValue(t.Elem(), depth+1))
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		v.SetMapIndex(sampleValue(t.Key(), depth+1), sampleValue(t.Elem(), depth+1))
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				v.Field(i).Set(sampleValue(t.Field(i).Type, depth+1))
+			}
+		}
+	}
+	return v
+}
+
+func lookupRef(doc map[string]interface{}, ref string) map[string]interface{} {
+	var node interface{} = doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[part]
+	}
+	m, _ := node.(map[string]interface{})
+	return m
+}
+
+// validateSchema checks a decoded JSON value against an OpenAPI schema, properties that are not documented are reported
+func validateSchema(doc map[string]interface{}, s interface{}, v interface{}, at string) []string {
+	sch, _ := s.(map[string]interface{})
+	if ref, ok := sch["$ref"].(string); ok {
+		return validateSchema(doc, lookupRef(doc, ref), v, at)
+	}
+	if v == nil {
+		if nullable, _ := sch["nullable"].(bool); nullable || len(sch) == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null is not nullable", at)}
+	}
+	var problems []string
+	if all, ok := sch["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			problems = append(problems, validateSchema(doc, sub, v, at)...)
+		}
+	}
+	if enum, ok := sch["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", at, v, enum))
+		}
+	}
+
+	switch sch["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected an object, got %T", at, v))
+		}
+		props, hasProps := sch["properties"].(map[string]interface{})
+		additional, hasAdditional := sch["additionalProperties"]
+		required, _ := sch["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %s", at, name))
+			}
+		}
+		var keys []string
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := props[k]; ok {
+				problems = append(problems, validateSchema(doc, p, obj[k], at+"."+k)...)
+			} else if hasAdditional {
+				problems = append(problems, validateSchema(doc, additional, obj[k], at+"."+k)...)
+			} else if hasProps {
+				problems = append(problems, fmt.Sprintf("%s: undocumented property %s", at, k))
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected an array, got %T", at, v))
+		}
+		for i, item := range arr {
+			problems = append(problems, validateSchema(doc, sch["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected a string, got %T", at, v))
+		}
+		if sch["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, str))
+			}
+		}
+		if pattern, ok := sch["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(str) {
+			problems = append(problems, fmt.Sprintf("%s: %q does not match %s", at, str, pattern))
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			problems = append(problems, fmt.Sprintf("%s: expected an integer, got %v", at, v))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a number, got %T", at, v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a boolean, got %T", at, v))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+const (
+	defaultPageLimit = 25
+	maxPageLimit     = 100
+)
+
+// page is the response body of a paginated list endpoint
+type page struct {
+	Items interface{} `json:"items"`
+	Total int         `json:"total"`
+	Next  string      `json:"next,omitempty"`
+}
+
+// parseLimit reads the limit query param, capped at max
+func parseLimit(r *http.Request, def, max int) (int, error) {
+	l := r.URL.Query().Get("limit")
+	if l == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n <= 0 {
+		return 0, models.InvalidError("limit param must be a positive number", map[string]string{"limit": l})
+	}
+	if n > max {
+		n = max
+	}
+	return n, nil
+}
+
+// parseSort reads the sort query param, a leading - sorts in descending order
+func parseSort(r *http.Request, options []string, def string) (string, bool, error) {
+	return sortOption(r.URL.Query().Get("sort"), options, def)
+}
+
+// sortOption checks value is one of options, optionally prefixed with - to sort descending
+func sortOption(value string, options []string, def string) (string, bool, error) {
+	if value == "" {
+		value = def
+	}
+	field := strings.TrimPrefix(value, "-")
+	for _, option := range options {
+		if field == option {
+			return field, field != value, nil
+		}
+	}
+	return "", false, models.InvalidError(
+		fmt.Sprintf("sort param must be one of %s", strings.Join(options, ", ")),
+		map[string]string{"sort": value},
+	)
+}
+
+// parseDate reads a date query param as either a date or an RFC 3339 timestamp
+func parseDate(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, models.InvalidError(fmt.Sprintf("%s param must be a date", name), map[string]string{name: value})
+}
+
+// encodeCursor returns the opaque cursor clients send back to get the next page
+func encodeCursor(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads the cursor query param into v, it reports whether a cursor was sent
+func decodeCursor(r *http.Request, v interface{}) (bool, error) {
+	value := r.URL.Query().Get("cursor")
+	if value == "" {
+		return false, nil
+	}
+	if err := unmarshalCursor(value, v); err != nil {
+		return false, models.InvalidError("invalid cursor param", map[string]string{"cursor": value})
+	}
+	return true, nil
+}
+
+// unmarshalCursor decodes a cursor made by encodeCursor into v
+func unmarshalCursor(value string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// listCursor marks the last item of an in memory list page by its sort key and ID
+type listCursor struct {
+	Key string `json:"k"`
+	ID  int64  `json:"id"`
+}
+
+// listKey returns the sort key of the ith item of an in memory list
+type listKey func(i int) listCursor
+
+// pageList sorts an in memory list of n items by key and returns the bounds of the page after the cursor
+func pageList(r *http.Request, n int, key listKey, swap func(i, j int), desc bool, limit int) (int, int, string, error) {
+	var after listCursor
+	ok, err := decodeCursor(r, &after)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	less := func(a, b listCursor) bool {
+		if desc {
+			a, b = b, a
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.ID < b.ID
+	}
+	sort.Sort(keySorter{n: n, less: func(i, j int) bool { return less(key(i), key(j)) }, swap: swap})
+
+	start := 0
+	if ok {
+		start = sort.Search(n, func(i int) bool { return less(after, key(i)) })
+	}
+	end := start + limit
+	if end > n {
+		end = n
+	}
+	next := ""
+	if end < n {
+		next = encodeCursor(key(end - 1))
+	}
+	return start, end, next, nil
+}
+
+type keySorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+}
+
+func (s keySorter) Len() int           { return s.n }
+func (s keySorter) Less(i, j int) bool { return s.less(i, j) }
+func (s keySorter) Swap(i, j int)      { s.swap(i, j) }
+
+// countKey pads a count so it sorts as a string
+func countKey(n int) string {
+	return fmt.Sprintf("%010d", n)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestParseListParams(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?limit=500&sort=-wins&from=2018-06-01", nil)
+	if limit, err := parseLimit(r, 25, 100); err != nil || limit != 100 {
+		t.Errorf("expected limit to be capped at 100, got %d %v", limit, err)
+	}
+	if field, desc, err := parseSort(r, []string{"name", "wins"}, "name"); err != nil || field != "wins" || !desc {
+		t.Errorf("expected descending wins sort, got %s %v %v", field, desc, err)
+	}
+	if from, err := parseDate(r, "from"); err != nil || !from.Equal(time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected from date %v %v", from, err)
+	}
+	if to, err := parseDate(r, "to"); err != nil || to != nil {
+		t.Errorf("expected no to date, got %v %v", to, err)
+	}
+
+	bad := httptest.NewRequest("GET", "/?limit=0&sort=distance&from=june&cursor=!!", nil)
+	if _, err := parseLimit(bad, 25, 100); models.ErrorKind(err) != models.KindInvalid {
+		t.Errorf("expected invalid limit, got %v", err)
+	}
+	if _, _, err := parseSort(bad, []string{"name"}, "name"); models.ErrorKind(err) != models.KindInvalid {
+		t.Errorf("expected invalid sort, got %v", err)
+	}
+	if _, err := parseDate(bad, "from"); models.ErrorKind(err) != models.KindInvalid {
+		t.Errorf("expected invalid date, got %v", err)
+	}
+	var c listCursor
+	if _, err := decodeCursor(bad, &c); models.ErrorKind(err) != models.KindInvalid {
+		t.Errorf("expected invalid cursor, got %v", err)
+	}
+}
+
+func TestChallengeQueryCursor(t *testing.T) {
+	expires := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+	id := bson.NewObjectId()
+	q := models.ChallengeQuery{Sort: "expires"}
+	cursor := encodeCursor(q.Cursor(models.Challenge{ID: id, Expires: &expires}))
+
+	r := httptest.NewRequest("GET", "/?status=active&opponent=2&segment=3&sort=-created&cursor="+cursor, nil)
+	q, err := challengeQuery(r, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if q.UserID != 1 || q.Status != "active" || q.OpponentID != 2 || q.SegmentID != 3 {
+		t.Errorf("unexpected filters %+v", q)
+	}
+	if q.Sort != "created" || !q.Desc || q.Limit != defaultPageLimit {
+		t.Errorf("unexpected sort %+v", q)
+	}
+	if q.After == nil || q.After.ID != id || !q.After.Value.Equal(expires) {
+		t.Errorf("expected cursor after %v, got %+v", id, q.After)
+	}
+
+	for _, query := range []string{"status=lost", "opponent=fred", "cursor=e30"} {
+		if _, err := challengeQuery(httptest.NewRequest("GET", "/?"+query, nil), 1); models.ErrorKind(err) != models.KindInvalid {
+			t.Errorf("%s: expected invalid error, got %v", query, err)
+		}
+	}
+}
+
+func TestPageList(t *testing.T) {
+	friends := []*models.Friend{
+		{ID: 1, FullName: "Cara", Wins: 2},
+		{ID: 2, FullName: "alex", Wins: 5},
+		{ID: 3, FullName: "Bo", Wins: 2},
+	}
+	key := func(i int) listCursor { return listCursor{Key: countKey(friends[i].Wins), ID: friends[i].ID} }
+	swap := func(i, j int) { friends[i], friends[j] = friends[j], friends[i] }
+
+	var seen []int64
+	cursor := ""
+	for pages := 0; pages < 3; pages++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		if cursor != "" {
+			r = httptest.NewRequest("GET", "/?cursor="+cursor, nil)
+		}
+		start, end, next, err := pageList(r, len(friends), key, swap, true, 2)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		for _, f := range friends[start:end] {
+			seen = append(seen, f.ID)
+		}
+		if cursor = next; cursor == "" {
+			break
+		}
+	}
+	if exp := []int64{2, 3, 1}; len(seen) != len(exp) || seen[0] != exp[0] || seen[1] != exp[1] || seen[2] != exp[2] {
+		t.Errorf("expected friends %v, got %v", exp, seen)
+	}
+}
+
+func TestListEndpointsInvalidParams(t *testing.T) {
+	server := httptest.NewServer(API())
+	defer server.Close()
+
+	for _, path := range []string{
+		"/api/v2/users/1/friends?sort=age",
+		"/api/v2/users/1/segments?limit=-1",
+		"/api/v2/users/1/challenges?from=yesterday",
+		"/api/v2/users/1/challenges?cursor=nope",
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %v, got: %v", path, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
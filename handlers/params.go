@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// int64Param parses a numeric URL param such as a user or segment ID
+func int64Param(r *http.Request, name string) (int64, error) {
+	value := chi.URLParam(r, name)
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, models.InvalidError(fmt.Sprintf("%s param must be a number", name), map[string]string{name: value})
+	}
+	return id, nil
+}
+
+// objectIDParam parses a URL param holding a bson Object ID such as a challenge ID
+func objectIDParam(r *http.Request, name string) (bson.ObjectId, error) {
+	value := chi.URLParam(r, name)
+	if !bson.IsObjectIdHex(value) {
+		return "", models.InvalidError(fmt.Sprintf("%s param must be an Object ID", name), map[string]string{name: value})
+	}
+	return bson.ObjectIdHex(value), nil
+}
+
+// int64Query parses an optional numeric query param, it is zero when missing
+func int64Query(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, models.InvalidError(fmt.Sprintf("%s param must be a number", name), map[string]string{name: value})
+	}
+	return id, nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+// mergePatchType is the media type of a JSON Merge Patch, plain JSON is accepted as well
+const mergePatchType = "application/merge-patch+json"
+
+// mergePatch applies a JSON Merge Patch (RFC 7396) to target.
+// Objects are merged key by key, null removes a key and any other value replaces it.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// applyMergePatch reads a merge patch from the request body and applies it to v.
+// Keys the patch removes are reset from defaults, a value of the same type as v.
+func applyMergePatch(r *http.Request, v, defaults interface{}) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != mergePatchType && mt != "application/json" {
+			return models.InvalidError("Content-Type must be "+mergePatchType, map[string]string{"contentType": ct})
+		}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return models.InvalidError("Could not read request body", nil)
+	}
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return models.InvalidError("request body must be a JSON merge patch", nil)
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return models.InvalidError("request body must be a JSON object", nil)
+	}
+
+	var target interface{}
+	if err := roundTrip(v, &target); err != nil {
+		return models.InternalError("unable to apply patch", err)
+	}
+	merged, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return models.InternalError("unable to apply patch", err)
+	}
+
+	// start from the defaults so removed keys fall back to them
+	if err := roundTrip(defaults, v); err != nil {
+		return models.InternalError("unable to apply patch", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var errs ValidationErrors
+		errs.Add("body", "malformed", "%v", err)
+		return models.InvalidError("invalid patch", errs)
+	}
+	return nil
+}
+
+// roundTrip copies from into to through their JSON encoding
+func roundTrip(from, to interface{}) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, to)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	"github.com/jrzimmerman/bestrida-server-go/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// reminderWindows are how long before a challenge expires participants are reminded, largest first
+var reminderWindows = mustParseReminderWindows(utils.GetEnvStringDefault("REMINDER_WINDOWS", "24h,2h"))
+
+// parseReminderWindows reads a comma separated list of durations such as "24h,2h"
+func parseReminderWindows(s string) ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("reminder window %s must be positive", part)
+		}
+		windows = append(windows, d)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i] > windows[j] })
+	return windows, nil
+}
+
+func mustParseReminderWindows(s string) []time.Duration {
+	windows, err := parseReminderWindows(s)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid REMINDER_WINDOWS")
+	}
+	return windows
+}
+
+// reminderWindow returns the smallest window the time left falls in,
+// so a challenge created a few hours before it ends only gets the closest reminder
+func reminderWindow(windows []time.Duration, left time.Duration) (time.Duration, bool) {
+	var match time.Duration
+	for _, w := range windows {
+		if left <= w {
+			match = w
+		}
+	}
+	return match, match > 0
+}
+
+// remindChallengesJob reminds participants who have not ridden a segment that their challenge ends soon
+func remindChallengesJob(ctx context.Context, job *models.Job) error {
+	if len(reminderWindows) == 0 {
+		return nil
+	}
+	now := time.Now()
+	challenges, err := models.GetChallengesExpiringBetween(now, now.Add(reminderWindows[0]))
+	if err != nil {
+		return err
+	}
+
+	var sent int
+	for i := range challenges {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c := &challenges[i]
+		window, ok := reminderWindow(reminderWindows, c.Expires.Sub(now))
+		if !ok {
+			continue
+		}
+		for _, o := range []*models.Opponent{c.Challenger, c.Challengee} {
+			if o == nil || o.Completed {
+				continue
+			}
+			claimed, err := models.ClaimReminder(c.ID, o.ID, window)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			emitChallengeEvent(notify.ChallengeExpiring, c, o.ID)
+			sent++
+		}
+	}
+	log.Infof("sent %d expiration reminders for %d expiring challenges", sent, len(challenges))
+	return nil
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReminderWindows(t *testing.T) {
+	windows, err := parseReminderWindows("2h, 24h,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(windows) != 2 || windows[0] != 24*time.Hour || windows[1] != 2*time.Hour {
+		t.Errorf("expected windows sorted largest first, got %v", windows)
+	}
+	if _, err := parseReminderWindows("tomorrow"); err == nil {
+		t.Error("expected invalid window to fail")
+	}
+	if _, err := parseReminderWindows("-1h"); err == nil {
+		t.Error("expected negative window to fail")
+	}
+}
+
+func TestReminderWindow(t *testing.T) {
+	windows := []time.Duration{24 * time.Hour, 2 * time.Hour}
+	for left, exp := range map[time.Duration]time.Duration{
+		30 * time.Hour:   0,
+		20 * time.Hour:   24 * time.Hour,
+		90 * time.Minute: 2 * time.Hour,
+	} {
+		got, ok := reminderWindow(windows, left)
+		if got != exp || ok != (exp > 0) {
+			t.Errorf("expected %v left to match window %v, got %v", left, exp, got)
+		}
+	}
+}
@@ -2,7 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	log "github.com/sirupsen/logrus"
 )
 
 // ContextResponseWriter implements the ResponseWriter interface for the context package
@@ -40,3 +46,71 @@ func (r *Response) Render(code int, content interface{}) {
 	r.context.WriteHeader(code)
 	json.NewEncoder(r.context.ResponseWriter).Encode(content)
 }
+
+// ErrorBody is the JSON shape of every error response
+type ErrorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// codeRateLimited is returned when the Strava quota for the request is exhausted
+const codeRateLimited = "rate_limited"
+
+// errorStatus maps error codes to response status codes
+var errorStatus = map[string]int{
+	models.KindInvalid:      http.StatusBadRequest,
+	models.KindValidation:   http.StatusUnprocessableEntity,
+	models.KindUnauthorized: http.StatusUnauthorized,
+	models.KindForbidden:    http.StatusForbidden,
+	models.KindNotFound:     http.StatusNotFound,
+	models.KindConflict:     http.StatusConflict,
+	models.KindUpstream:     http.StatusBadGateway,
+	models.KindInternal:     http.StatusInternalServerError,
+	models.KindUnavailable:  http.StatusServiceUnavailable,
+	codeRateLimited:         http.StatusServiceUnavailable,
+}
+
+// Error renders err with the status code for its kind.
+// Untyped errors are logged and rendered as internal errors without leaking their message.
+func (r *Response) Error(err error) {
+	body := errorBody(err)
+	body.RequestID = r.context.Header().Get(RequestIDHeader)
+
+	status, ok := errorStatus[body.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	if status >= http.StatusInternalServerError {
+		log.WithField("REQUEST ID", body.RequestID).WithError(err).Errorf("%s: %s", body.Code, body.Message)
+	}
+	r.Render(status, body)
+}
+
+// errorBody classifies err into the code and message shown to clients
+func errorBody(err error) ErrorBody {
+	var body ErrorBody
+	var e *models.Error
+	switch {
+	case errors.As(err, &e):
+		body.Code, body.Message, body.Details = e.Kind, e.Message, e.Details
+		// an internal error only adds context to a cause that may say more, such as not found
+		if e.Kind == models.KindInternal && e.Err != nil {
+			var cause *models.Error
+			if errors.As(e.Err, &cause) {
+				body.Code, body.Message, body.Details = cause.Kind, cause.Message, cause.Details
+			} else if errors.Is(e.Err, ratelimit.ErrLimited) {
+				body.Code = codeRateLimited
+			} else {
+				body.Code = models.ErrorKind(e.Err)
+			}
+		}
+	case errors.Is(err, ratelimit.ErrLimited):
+		body.Code, body.Message = codeRateLimited, "strava rate limit reached, try again later"
+	default:
+		body.Code = models.ErrorKind(err)
+		body.Message = strings.Replace(body.Code, "_", " ", -1)
+	}
+	return body
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	strava "github.com/strava/go.strava"
+	"gopkg.in/mgo.v2"
+)
+
+func TestResponseErrorStatusCodes(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{models.InvalidError("bad id", nil), http.StatusBadRequest, models.KindInvalid},
+		{models.ValidationError("bad date", nil), http.StatusUnprocessableEntity, models.KindValidation},
+		{errUnauthorized, http.StatusUnauthorized, models.KindUnauthorized},
+		{models.ForbiddenError("private"), http.StatusForbidden, models.KindForbidden},
+		{models.NotFoundError("user", 1), http.StatusNotFound, models.KindNotFound},
+		{mgo.ErrNotFound, http.StatusNotFound, models.KindNotFound},
+		{models.InternalError("unable to get user", models.NotFoundError("user", 1)), http.StatusNotFound, models.KindNotFound},
+		{models.InternalError("unable to get user", mgo.ErrNotFound), http.StatusNotFound, models.KindNotFound},
+		{models.ConflictError("exists", nil), http.StatusConflict, models.KindConflict},
+		{stravaError(strava.Error{Message: "Authorization Error"}), http.StatusBadGateway, models.KindUpstream},
+		{stravaError(strava.Error{Message: "Record Not Found"}), http.StatusNotFound, models.KindNotFound},
+		{fmt.Errorf("get segment: %w", ratelimit.ErrLimited), http.StatusServiceUnavailable, codeRateLimited},
+		{errJobsUnavailable, http.StatusServiceUnavailable, models.KindUnavailable},
+		{errNoEffort, http.StatusNotFound, models.KindNotFound},
+		{errors.New("connection reset"), http.StatusInternalServerError, models.KindInternal},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		New(w).Error(tc.err)
+		if w.Code != tc.status {
+			t.Errorf("%v: expected status code %v, got: %v", tc.err, tc.status, w.Code)
+		}
+		var body ErrorBody
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode response: %s", err)
+		}
+		if body.Code != tc.code {
+			t.Errorf("%v: expected code %s, got %s", tc.err, tc.code, body.Code)
+		}
+		if body.Message == "" {
+			t.Errorf("%v: expected a message", tc.err)
+		}
+	}
+}
+
+func TestResponseErrorHidesCause(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(w).Error(models.InternalError("unable to save user", errors.New("mongo password rejected")))
+
+	var body ErrorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Errorf("unable to decode response: %s", err)
+	}
+	if body.Message != "unable to save user" {
+		t.Errorf("unexpected message %q", body.Message)
+	}
+}
+
+// TestRequestIDInErrors tests the request ID is echoed in the header and error body
+func TestRequestIDInErrors(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Get("/{id}", GetUserByID)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for _, sent := range []string{"req-123", ""} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/fred", server.URL), nil)
+		if err != nil {
+			t.Error("unable to generate request", err)
+		}
+		if sent != "" {
+			req.Header.Set(RequestIDHeader, sent)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+
+		if exp := http.StatusBadRequest; resp.StatusCode != exp {
+			t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+		}
+		var body ErrorBody
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode response: %s", err)
+		}
+		resp.Body.Close()
+
+		id := resp.Header.Get(RequestIDHeader)
+		if id == "" || body.RequestID != id {
+			t.Errorf("expected request ID %q in body, got %q", id, body.RequestID)
+		}
+		if sent != "" && id != sent {
+			t.Errorf("expected request ID %q to be reused, got %q", sent, id)
+		}
+		if body.Code != models.KindInvalid || body.Details == nil {
+			t.Errorf("unexpected error body %+v", body)
+		}
+	}
+}
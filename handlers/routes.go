@@ -57,6 +57,7 @@ func API() (mux *chi.Mux) {
 	strava.ClientSecret = clientSecret
 
 	mux = chi.NewRouter()
+	mux.Use(RequestID)
 	mux.Use(CORS)
 
 	mux.HandleFunc(path, authenticator.HandlerFunc(oAuthSuccess, oAuthFailure))
@@ -65,67 +66,196 @@ func API() (mux *chi.Mux) {
 	publicDir := filepath.Join(workDir, "public")
 	FileServer(mux, "/", http.Dir(publicDir))
 
-	mux.Route("/api", func(r chi.Router) {
-		r.Get("/health", GetHealthCheck)
-		r.Route("/users", func(r chi.Router) {
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", GetUserByID)
-				r.Get("/friends", GetFriendsByUserID)
-
-				r.Route("/segments", func(r chi.Router) {
-					r.Get("/", GetSegmentsByUserID)
-					r.Route("/{segmentID}", func(r chi.Router) {
-						r.Get("/", GetSegmentByIDWithUserID)
-						r.Get("/strava", GetSegmentByIDFromStravaWithUserID)
-						r.Route("/efforts", func(r chi.Router) {
-							r.Get("/", GetEffortsBySegmentIDFromStravaWithUserID)
-						})
-					})
-				})
+	// only API responses are tagged for revalidation, static assets keep the FileServer's own headers
+	mux.With(ConditionalGet).Get(openAPIPath, GetOpenAPI)
+	mux.Post("/graphql", GraphQL)
+	mux.Route(v2Prefix, func(r chi.Router) {
+		r.Use(ConditionalGet)
+		apiV2(r)
+	})
 
-				r.Route("/challenges", func(r chi.Router) {
-					r.Get("/", GetAllChallengesByUserID)
-					r.Get("/pending", GetPendingChallengesByUserID)
-					r.Get("/active", GetActiveChallengesByUserID)
-					r.Get("/completed", GetCompletedChallengesByUserID)
-				})
+	// v1 stays available until its sunset date, responses point clients at v2
+	mux.Route("/api", func(r chi.Router) {
+		r.Use(Deprecated(v1DeprecatedAt, v1SunsetAt, v2Prefix))
+		r.Use(ConditionalGet)
+		apiV1(r)
+	})
 
+	mux.Route("/strava", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(Deprecated(v1DeprecatedAt, v1SunsetAt, v2Prefix))
+			r.Use(AdminOnly)
+			r.Get("/quota", GetStravaQuota)
+			r.Route("/update", func(r chi.Router) {
+				// starting a sync changes state, it is not served on GET
+				r.Post("/users", UpdateAllUsersFromStrava)
+				r.Get("/users/status", GetUserSyncStatus)
 			})
 		})
+		r.Route("/auth", func(r chi.Router) {
+			r.Get("/", AuthHandler)
+			r.Get("/callback", AuthHandler)
+		})
+	})
+
+	return mux
+}
+
+// apiV1 registers the deprecated v1 routes, verb style paths take IDs in the request body
+func apiV1(r chi.Router) {
+	r.Get("/health", GetHealthCheck)
+	r.Route("/email", func(r chi.Router) {
+		r.Get("/unsubscribe", UnsubscribeEmail)
+		r.Post("/unsubscribe", UnsubscribeEmail)
+	})
+	r.Route("/jobs", func(r chi.Router) {
+		r.Use(AdminOnly)
+		r.Get("/runs", GetJobRuns)
+		r.Get("/dead", GetDeadJobs)
+		r.Get("/leases", GetLeases)
+	})
+	r.Get("/search", Search)
+	r.Route("/users", func(r chi.Router) {
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", GetUserByID)
+			r.Get("/friends", GetFriendsByUserID)
+			r.Get("/events", StreamEvents)
+			r.Put("/timezone", UpdateUserTimeZone)
+			r.Get("/settings", GetUserSettings)
+			r.Patch("/settings", UpdateUserSettings)
+			r.Get("/email", GetEmailPreferences)
+			r.Put("/email", UpdateEmailPreferences)
+			r.Route("/notifications", func(r chi.Router) {
+				r.Get("/", GetNotificationsByUserID)
+				r.Put("/read", MarkAllNotificationsRead)
+				r.Put("/{notificationID}/read", MarkNotificationRead)
+			})
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Get("/", GetWebhooksByUserID)
+				r.Post("/", CreateWebhook)
+				r.Delete("/{webhookID}", RemoveWebhook)
+				r.Get("/{webhookID}/deliveries", GetWebhookDeliveries)
+			})
+			r.Route("/devices", func(r chi.Router) {
+				r.Post("/", RegisterDevice)
+				r.Delete("/{token}", RemoveDevice)
+			})
+
+			r.Route("/segments", func(r chi.Router) {
+				r.Get("/", GetSegmentsByUserID)
+				r.Route("/{segmentID}", func(r chi.Router) {
+					r.Get("/", GetSegmentByIDWithUserID)
+					r.Get("/strava", GetSegmentByIDFromStravaWithUserID)
+					r.Route("/efforts", func(r chi.Router) {
+						r.Get("/", GetEffortsBySegmentIDFromStravaWithUserID)
+					})
+				})
+			})
 
-		r.Route("/segments", func(r chi.Router) {
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", GetSegmentByID)
-				r.Get("/strava", GetSegmentByIDFromStrava)
+			r.Route("/challenges", func(r chi.Router) {
+				r.Get("/", GetAllChallengesByUserID)
+				r.Get("/pending", GetPendingChallengesByUserID)
+				r.Get("/active", GetActiveChallengesByUserID)
+				r.Get("/completed", GetCompletedChallengesByUserID)
 			})
+
 		})
+	})
 
-		r.Route("/challenges", func(r chi.Router) {
-			r.Get("/{id}", GetChallengeByID)
-			r.Put("/accept", AcceptChallengeByID)
-			r.Put("/decline", DeclineChallengeByID)
-			r.Put("/complete", CompleteChallengeByID)
-			r.Post("/create", CreateChallenge)
+	r.Route("/segments", func(r chi.Router) {
+		r.With(AdminOnly).Get("/cache", GetSegmentCacheMetrics)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(CacheControl(maxAge(segmentMaxAge)))
+			r.Get("/", GetSegmentByID)
+			r.Get("/strava", GetSegmentByIDFromStrava)
 		})
+	})
 
-		r.Route("/athletes", func(r chi.Router) {
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", GetAthleteByIDFromStrava)
-				r.Get("/friends", GetFriendsByUserIDFromStrava)
-				r.Get("/segments", GetSegmentsByUserIDFromStrava)
-			})
+	r.Route("/challenges", func(r chi.Router) {
+		r.Get("/{id}", GetChallengeByID)
+		r.Put("/accept", AcceptChallengeByID)
+		r.Put("/decline", DeclineChallengeByID)
+		r.Put("/complete", CompleteChallengeByID)
+		r.Post("/create", CreateChallenge)
+	})
+
+	r.Route("/athletes", func(r chi.Router) {
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", GetAthleteByIDFromStrava)
+			r.Get("/friends", GetFriendsByUserIDFromStrava)
+			r.Get("/segments", GetSegmentsByUserIDFromStrava)
 		})
 	})
+}
 
-	mux.Route("/strava", func(r chi.Router) {
-		r.Route("/update", func(r chi.Router) {
-			r.Get("/users", UpdateAllUsersFromStrava)
+// apiV2 registers resource oriented routes with IDs in the path
+func apiV2(r chi.Router) {
+	r.Get("/health", GetHealthCheck)
+	r.Route("/email", func(r chi.Router) {
+		r.Get("/unsubscribe", UnsubscribeEmail)
+		r.Post("/unsubscribe", UnsubscribeEmail)
+	})
+	r.Route("/jobs", func(r chi.Router) {
+		r.Use(AdminOnly)
+		r.Get("/runs", GetJobRuns)
+		r.Get("/dead", GetDeadJobs)
+		r.Get("/leases", GetLeases)
+	})
+	r.Route("/strava", func(r chi.Router) {
+		r.Use(AdminOnly)
+		r.Get("/quota", GetStravaQuota)
+		r.Post("/sync", UpdateAllUsersFromStrava)
+		r.Get("/sync", GetUserSyncStatus)
+	})
+
+	r.Get("/search", Search)
+	r.Route("/users/{id}", func(r chi.Router) {
+		r.Get("/", GetUserByID)
+		r.Get("/friends", ListFriendsByUserID)
+		r.Get("/segments", ListSegmentsByUserID)
+		r.Get("/segments/{segmentID}/efforts", GetEffortsBySegmentIDFromStravaWithUserID)
+		r.Get("/challenges", GetChallengesByUserID)
+		r.Get("/events", StreamEvents)
+		r.Put("/timezone", UpdateUserTimeZone)
+		r.Get("/settings", GetUserSettings)
+		r.Patch("/settings", UpdateUserSettings)
+		r.Get("/email", GetEmailPreferences)
+		r.Put("/email", UpdateEmailPreferences)
+		r.Route("/sync", func(r chi.Router) {
+			r.Post("/athlete", GetAthleteByIDFromStrava)
+			r.Post("/friends", GetFriendsByUserIDFromStrava)
+			r.Post("/segments", GetSegmentsByUserIDFromStrava)
 		})
-		r.Route("/auth", func(r chi.Router) {
-			r.Get("/", AuthHandler)
-			r.Get("/callback", AuthHandler)
+		r.Route("/notifications", func(r chi.Router) {
+			r.Get("/", GetNotificationsByUserID)
+			r.Put("/read", MarkAllNotificationsRead)
+			r.Put("/{notificationID}/read", MarkNotificationRead)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", GetWebhooksByUserID)
+			r.Post("/", CreateWebhook)
+			r.Delete("/{webhookID}", RemoveWebhook)
+			r.Get("/{webhookID}/deliveries", GetWebhookDeliveries)
+		})
+		r.Route("/devices", func(r chi.Router) {
+			r.Post("/", RegisterDevice)
+			r.Delete("/{token}", RemoveDevice)
 		})
 	})
 
-	return mux
+	r.Route("/segments", func(r chi.Router) {
+		r.With(AdminOnly).Get("/cache", GetSegmentCacheMetrics)
+		r.With(CacheControl(maxAge(segmentMaxAge))).Get("/{id}", GetSegmentByID)
+		r.With(CacheControl(maxAge(segmentMaxAge))).Get("/{id}/strava", GetSegmentByIDFromStrava)
+	})
+
+	r.Route("/challenges", func(r chi.Router) {
+		r.Post("/", PostChallenge)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", GetChallengeByID)
+			r.Post("/accept", AcceptChallenge)
+			r.Post("/decline", DeclineChallenge)
+			r.Post("/complete", CompleteChallenge)
+		})
+	})
 }
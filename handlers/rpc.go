@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	"github.com/jrzimmerman/bestrida-server-go/rpc/bestridapb"
+	"github.com/jrzimmerman/bestrida-server-go/utils"
+)
+
+// rpcToken is the shared secret internal consumers send as a bearer token,
+// calls are not authenticated when it is empty so RPCAddr only listens on localhost
+var rpcToken = utils.GetEnvStringDefault("GRPC_TOKEN", "")
+
+// RPCAddr returns the address the gRPC server listens on. Without a token the service is
+// unauthenticated, it is bound to the loopback interface instead of all interfaces.
+func RPCAddr(port string) string {
+	if rpcToken == "" {
+		log.Warnf("GRPC_TOKEN is not set, serving gRPC on localhost only")
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return ":" + port
+}
+
+// rpcCodes maps error codes to gRPC status codes
+var rpcCodes = map[string]codes.Code{
+	models.KindInvalid:      codes.InvalidArgument,
+	models.KindValidation:   codes.InvalidArgument,
+	models.KindUnauthorized: codes.Unauthenticated,
+	models.KindForbidden:    codes.PermissionDenied,
+	models.KindNotFound:     codes.NotFound,
+	models.KindConflict:     codes.AlreadyExists,
+	models.KindUpstream:     codes.Unavailable,
+	models.KindInternal:     codes.Internal,
+	models.KindUnavailable:  codes.Unavailable,
+	codeRateLimited:         codes.ResourceExhausted,
+}
+
+// RPCServer returns the gRPC server for internal consumers,
+// it serves users, segments and challenges from the same models and rules as the REST API
+func RPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.StreamInterceptor(streamInterceptor),
+	)
+	bestridapb.RegisterUsersServer(srv, usersServer{})
+	bestridapb.RegisterSegmentsServer(srv, segmentsServer{})
+	bestridapb.RegisterChallengesServer(srv, challengesServer{})
+	return srv
+}
+
+// rpcError converts err to a gRPC status with the same code and message a REST client would see
+func rpcError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	body := errorBody(err)
+	code, ok := rpcCodes[body.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	if code == codes.Internal || code == codes.Unavailable {
+		log.WithField("METHOD", method).WithError(err).Errorf("%s: %s", body.Code, body.Message)
+	}
+	return status.Error(code, body.Message)
+}
+
+// authenticateRPC checks the call carries the shared token
+func authenticateRPC(ctx context.Context) error {
+	if rpcToken == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, h := range md.Get("authorization") {
+		token := strings.TrimPrefix(h, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(rpcToken)) == 1 {
+			return nil
+		}
+	}
+	return errUnauthorized
+}
+
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authenticateRPC(ctx); err != nil {
+		return nil, rpcError(info.FullMethod, err)
+	}
+	resp, err := handler(ctx, req)
+	return resp, rpcError(info.FullMethod, err)
+}
+
+func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authenticateRPC(ss.Context()); err != nil {
+		return rpcError(info.FullMethod, err)
+	}
+	return rpcError(info.FullMethod, handler(srv, ss))
+}
+
+// rpcObjectID parses a challenge ID sent in a request
+func rpcObjectID(name, value string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(value) {
+		return "", models.InvalidError(name+" must be an Object ID", map[string]string{name: value})
+	}
+	return bson.ObjectIdHex(value), nil
+}
+
+func rpcTime(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func rpcUser(u *models.User) *bestridapb.User {
+	return &bestridapb.User{
+		Id:             u.ID,
+		FirstName:      u.FirstName,
+		LastName:       u.LastName,
+		FullName:       u.FullName,
+		Photo:          u.Photo,
+		City:           u.City,
+		State:          u.State,
+		Country:        u.Country,
+		Wins:           int32(u.Wins),
+		Losses:         int32(u.Losses),
+		ChallengeCount: int32(u.ChallengeCount),
+		TimeZone:       u.TimeZone,
+	}
+}
+
+func rpcSegment(s *models.Segment) *bestridapb.Segment {
+	if s == nil {
+		return nil
+	}
+	return &bestridapb.Segment{
+		Id:                 s.ID,
+		Name:               s.Name,
+		ActivityType:       s.ActivityType,
+		Distance:           s.Distance,
+		AverageGrade:       s.AverageGrade,
+		MaximumGrade:       s.MaximumGrade,
+		ElevationHigh:      s.ElevationHigh,
+		ElevationLow:       s.ElevationLow,
+		ClimbCategory:      int32(s.ClimbCategory),
+		City:               s.City,
+		State:              s.State,
+		Country:            s.Country,
+		TotalElevationGain: s.TotalElevationGain,
+	}
+}
+
+func rpcOpponent(o *models.Opponent) *bestridapb.Opponent {
+	if o == nil {
+		return nil
+	}
+	p := &bestridapb.Opponent{Id: o.ID, Name: o.Name, Photo: o.Photo, Completed: o.Completed}
+	if o.Time != nil {
+		p.Effort = &bestridapb.Effort{Time: int32(*o.Time)}
+		if o.AverageCadence != nil {
+			p.Effort.AverageCadence = *o.AverageCadence
+		}
+		if o.AverageWatts != nil {
+			p.Effort.AverageWatts = *o.AverageWatts
+		}
+		if o.AverageHeartRate != nil {
+			p.Effort.AverageHeartRate = *o.AverageHeartRate
+		}
+		if o.MaxHeartRate != nil {
+			p.Effort.MaxHeartRate = *o.MaxHeartRate
+		}
+	}
+	return p
+}
+
+func rpcChallenge(c *models.Challenge) *bestridapb.Challenge {
+	if c == nil {
+		return nil
+	}
+	p := &bestridapb.Challenge{
+		Id:         c.ID.Hex(),
+		Segment:    rpcSegment(c.Segment),
+		Challenger: rpcOpponent(c.Challenger),
+		Challengee: rpcOpponent(c.Challengee),
+		Status:     c.Status,
+		Created:    rpcTime(c.Created),
+		Expires:    rpcTime(c.Expires),
+		Completed:  rpcTime(c.Completed),
+		Expired:    c.Expired,
+		TimeZone:   c.TimeZone,
+	}
+	if c.WinnerID != nil {
+		p.WinnerId = *c.WinnerID
+	}
+	if c.WinnerName != nil {
+		p.WinnerName = *c.WinnerName
+	}
+	if c.LoserID != nil {
+		p.LoserId = *c.LoserID
+	}
+	if c.LoserName != nil {
+		p.LoserName = *c.LoserName
+	}
+	return p
+}
+
+type usersServer struct {
+	bestridapb.UnimplementedUsersServer
+}
+
+func (usersServer) GetUser(ctx context.Context, req *bestridapb.GetUserRequest) (*bestridapb.User, error) {
+	u, err := models.GetUserByID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	// calls carry the service token, not a user's, so they see what anonymous requests do
+	view := u.ViewFor(nil)
+	return rpcUser(&view), nil
+}
+
+func (usersServer) ListFriends(ctx context.Context, req *bestridapb.ListFriendsRequest) (*bestridapb.ListFriendsResponse, error) {
+	u, err := models.GetUserByID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	view := u.ViewFor(nil)
+	resp := &bestridapb.ListFriendsResponse{}
+	for _, f := range view.Friends {
+		resp.Friends = append(resp.Friends, &bestridapb.Friend{
+			Id:             f.ID,
+			FirstName:      f.FirstName,
+			LastName:       f.LastName,
+			FullName:       f.FullName,
+			Photo:          f.Photo,
+			ChallengeCount: int32(f.ChallengeCount),
+			Wins:           int32(f.Wins),
+			Losses:         int32(f.Losses),
+		})
+	}
+	return resp, nil
+}
+
+func (usersServer) ListUserSegments(ctx context.Context, req *bestridapb.ListUserSegmentsRequest) (*bestridapb.ListUserSegmentsResponse, error) {
+	u, err := models.GetUserByID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	view := u.ViewFor(nil)
+	resp := &bestridapb.ListUserSegmentsResponse{}
+	for _, s := range view.Segments {
+		if req.ActivityType != "" && s.ActivityType != req.ActivityType {
+			continue
+		}
+		resp.Segments = append(resp.Segments, &bestridapb.UserSegment{
+			Id:           s.ID,
+			Name:         s.Name,
+			Count:        int32(s.Count),
+			ActivityType: s.ActivityType,
+		})
+	}
+	return resp, nil
+}
+
+type segmentsServer struct {
+	bestridapb.UnimplementedSegmentsServer
+}
+
+func (segmentsServer) GetSegment(ctx context.Context, req *bestridapb.GetSegmentRequest) (*bestridapb.Segment, error) {
+	s, err := models.GetSegmentByID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Restricted() {
+		return nil, models.NotFoundError("segment", req.Id)
+	}
+	return rpcSegment(s), nil
+}
+
+type challengesServer struct {
+	bestridapb.UnimplementedChallengesServer
+}
+
+func (challengesServer) GetChallenge(ctx context.Context, req *bestridapb.GetChallengeRequest) (*bestridapb.Challenge, error) {
+	id, err := rpcObjectID("id", req.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, err := models.GetChallengeByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return rpcChallenge(c), nil
+}
+
+// listChallengesQuery checks a list request the same way challengeQuery checks the REST query params
+func listChallengesQuery(req *bestridapb.ListChallengesRequest) (models.ChallengeQuery, error) {
+	q := models.ChallengeQuery{
+		UserID:       req.UserId,
+		Status:       req.Status,
+		OpponentID:   req.OpponentId,
+		SegmentID:    req.SegmentId,
+		ActivityType: req.ActivityType,
+		Limit:        int(req.PageSize),
+	}
+	if q.UserID <= 0 {
+		return q, models.InvalidError("user ID is required", nil)
+	}
+	if !challengeStatuses[q.Status] {
+		return q, models.InvalidError("status must be pending, active or completed", map[string]string{"status": q.Status})
+	}
+	var err error
+	if q.Sort, q.Desc, err = sortOption(req.Sort, challengeSorts, "expires"); err != nil {
+		return q, err
+	}
+	switch {
+	case q.Limit <= 0:
+		q.Limit = defaultPageLimit
+	case q.Limit > maxPageLimit:
+		q.Limit = maxPageLimit
+	}
+	if req.PageToken != "" {
+		var after models.ChallengeCursor
+		if err := unmarshalCursor(req.PageToken, &after); err != nil || !after.ID.Valid() {
+			return q, models.InvalidError("invalid page token", map[string]string{"pageToken": req.PageToken})
+		}
+		q.After = &after
+	}
+	return q, nil
+}
+
+func (challengesServer) ListChallenges(ctx context.Context, req *bestridapb.ListChallengesRequest) (*bestridapb.ListChallengesResponse, error) {
+	q, err := listChallengesQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	challenges, total, err := models.GetChallenges(q)
+	if err != nil {
+		return nil, models.InternalError("Could not retrieve challenges from database", err)
+	}
+	resp := &bestridapb.ListChallengesResponse{Total: int32(total)}
+	for i := range challenges {
+		resp.Challenges = append(resp.Challenges, rpcChallenge(&challenges[i]))
+	}
+	if len(challenges) == q.Limit {
+		resp.NextPageToken = encodeCursor(q.Cursor(challenges[len(challenges)-1]))
+	}
+	return resp, nil
+}
+
+func (challengesServer) CreateChallenge(ctx context.Context, req *bestridapb.CreateChallengeRequest) (*bestridapb.Challenge, error) {
+	create := createRequest{
+		SegmentID:    int(req.SegmentId),
+		ChallengerID: int(req.ChallengerId),
+		ChallengeeID: int(req.ChallengeeId),
+		ActivityType: req.ActivityType,
+	}
+	if req.CompletionDate != nil {
+		create.CompletionDate = req.CompletionDate.AsTime()
+	}
+	if errs := validateCreateRequest(create); len(errs) > 0 {
+		return nil, models.InvalidError("invalid challenge request", errs)
+	}
+	c, err := createChallenge(create)
+	if err != nil {
+		return nil, err
+	}
+	return rpcChallenge(c), nil
+}
+
+func (challengesServer) AcceptChallenge(ctx context.Context, req *bestridapb.AcceptChallengeRequest) (*bestridapb.Challenge, error) {
+	id, err := rpcObjectID("id", req.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, err := acceptChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	return rpcChallenge(c), nil
+}
+
+func (challengesServer) DeclineChallenge(ctx context.Context, req *bestridapb.DeclineChallengeRequest) (*bestridapb.Challenge, error) {
+	id, err := rpcObjectID("id", req.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, err := declineChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	return rpcChallenge(c), nil
+}
+
+func (challengesServer) CompleteChallenge(ctx context.Context, req *bestridapb.CompleteChallengeRequest) (*bestridapb.Challenge, error) {
+	id, err := rpcObjectID("id", req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId <= 0 {
+		return nil, models.InvalidError("user ID is required", nil)
+	}
+	c, err := UpdateChallengeEffort(id, req.UserId, ratelimit.Interactive)
+	if err != nil {
+		return nil, models.InternalError("Could not update challenge effort", err)
+	}
+	if c == nil {
+		return nil, errNoEffort
+	}
+	return rpcChallenge(c), nil
+}
+
+// WatchChallengeEvents streams the same challenge events as the SSE endpoint until the client goes away
+func (challengesServer) WatchChallengeEvents(req *bestridapb.WatchChallengeEventsRequest, stream bestridapb.Challenges_WatchChallengeEventsServer) error {
+	if req.UserId <= 0 {
+		return models.InvalidError("user ID is required", nil)
+	}
+	if eventHub == nil {
+		return models.UnavailableError("realtime events are not available")
+	}
+
+	sub := eventHub.Subscribe(req.UserId)
+	defer eventHub.Unsubscribe(sub)
+	log.WithField("USER ID", req.UserId).Info("challenge event stream opened")
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			log.WithField("USER ID", req.UserId).Info("challenge event stream closed")
+			return nil
+		case <-eventHub.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if !strings.HasPrefix(e.Type, "challenge.") {
+				continue
+			}
+			var data streamEvent
+			if err := json.Unmarshal(e.Data, &data); err != nil {
+				log.WithError(err).Errorf("unable to decode %s event", e.Type)
+				continue
+			}
+			if err := stream.Send(&bestridapb.ChallengeEvent{
+				Id:        e.ID,
+				Type:      e.Type,
+				UserId:    e.UserID,
+				Title:     data.Notification.Title,
+				Body:      data.Notification.Body,
+				Challenge: rpcChallenge(data.Challenge),
+				CreatedAt: timestamppb.New(e.CreatedAt),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
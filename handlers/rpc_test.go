@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	"github.com/jrzimmerman/bestrida-server-go/realtime"
+	"github.com/jrzimmerman/bestrida-server-go/rpc/bestridapb"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// dialRPC serves the gRPC service in memory and returns a connection to it
+func dialRPC(t *testing.T) (*grpc.ClientConn, func()) {
+	lis := bufconn.Listen(1 << 20)
+	srv := RPCServer()
+	go srv.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("unable to dial gRPC server: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		// wait for the handlers to return so tests can restore the globals they read
+		srv.GracefulStop()
+	}
+}
+
+func TestRPCInvalidRequests(t *testing.T) {
+	conn, closeConn := dialRPC(t)
+	defer closeConn()
+	challenges := bestridapb.NewChallengesClient(conn)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"get challenge", func() error {
+			_, err := challenges.GetChallenge(ctx, &bestridapb.GetChallengeRequest{Id: "fred"})
+			return err
+		}},
+		{"accept challenge", func() error {
+			_, err := challenges.AcceptChallenge(ctx, &bestridapb.AcceptChallengeRequest{Id: "fred"})
+			return err
+		}},
+		{"complete without user", func() error {
+			_, err := challenges.CompleteChallenge(ctx, &bestridapb.CompleteChallengeRequest{Id: bson.NewObjectId().Hex()})
+			return err
+		}},
+		{"create without segment", func() error {
+			_, err := challenges.CreateChallenge(ctx, &bestridapb.CreateChallengeRequest{ChallengerId: 1, ChallengeeId: 2})
+			return err
+		}},
+		{"list with bad sort", func() error {
+			_, err := challenges.ListChallenges(ctx, &bestridapb.ListChallengesRequest{UserId: 1, Sort: "fred"})
+			return err
+		}},
+		{"list with bad page token", func() error {
+			_, err := challenges.ListChallenges(ctx, &bestridapb.ListChallengesRequest{UserId: 1, PageToken: "fred"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if code := status.Code(tt.call()); code != codes.InvalidArgument {
+			t.Errorf("%s: expected code %v, got: %v", tt.name, codes.InvalidArgument, code)
+		}
+	}
+}
+
+func TestRPCRequiresToken(t *testing.T) {
+	defer func(token string) { rpcToken = token }(rpcToken)
+	rpcToken = "secret"
+
+	conn, closeConn := dialRPC(t)
+	defer closeConn()
+	challenges := bestridapb.NewChallengesClient(conn)
+
+	_, err := challenges.GetChallenge(context.Background(), &bestridapb.GetChallengeRequest{Id: "fred"})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("expected code %v without token, got: %v", codes.Unauthenticated, code)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	_, err = challenges.GetChallenge(ctx, &bestridapb.GetChallengeRequest{Id: "fred"})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("expected code %v with token, got: %v", codes.InvalidArgument, code)
+	}
+}
+
+func TestRPCAddr(t *testing.T) {
+	defer func(token string) { rpcToken = token }(rpcToken)
+
+	rpcToken = ""
+	if addr, exp := RPCAddr("9090"), "127.0.0.1:9090"; addr != exp {
+		t.Errorf("expected %s without a token, got: %s", exp, addr)
+	}
+	rpcToken = "secret"
+	if addr, exp := RPCAddr("9090"), ":9090"; addr != exp {
+		t.Errorf("expected %s with a token, got: %s", exp, addr)
+	}
+}
+
+func TestRPCErrorCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{models.NotFoundError("challenge", 1), codes.NotFound},
+		{models.InternalError("Could not get challenge", models.NotFoundError("challenge", 1)), codes.NotFound},
+		{models.ConflictError("exists", nil), codes.AlreadyExists},
+		{models.UnavailableError("down"), codes.Unavailable},
+		{errNoEffort, codes.NotFound},
+		{context.DeadlineExceeded, codes.Internal},
+	}
+	for _, tt := range tests {
+		if code := status.Code(rpcError("/test", tt.err)); code != tt.code {
+			t.Errorf("%v: expected code %v, got: %v", tt.err, tt.code, code)
+		}
+	}
+}
+
+func TestRPCWatchChallengeEvents(t *testing.T) {
+	defer func(h *realtime.Hub) { eventHub = h }(eventHub)
+	ctx, cancel := context.WithCancel(context.Background())
+	eventHub = realtime.NewHub(realtime.NewLocalBackend())
+	eventHub.Start(ctx)
+
+	conn, closeConn := dialRPC(t)
+	defer closeConn()
+	published := make(chan struct{})
+	defer func() {
+		cancel()
+		<-published
+	}()
+
+	c := &models.Challenge{ID: bson.NewObjectId(), Status: "pending", Challenger: &models.Opponent{ID: 3}, Challengee: &models.Opponent{ID: 7}}
+	n := notify.ChallengeNotification(notify.ChallengeReceived, c, 7)
+	// the subscription is made once the stream starts, publish until it is delivered
+	go func() {
+		defer close(published)
+		for ctx.Err() == nil {
+			publishEvent(7, notify.ChallengeReceived, streamEvent{Notification: n, Challenge: c})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	stream, err := bestridapb.NewChallengesClient(conn).WatchChallengeEvents(ctx, &bestridapb.WatchChallengeEventsRequest{UserId: 7})
+	if err != nil {
+		t.Fatalf("unable to watch challenge events: %v", err)
+	}
+	e, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("unable to receive challenge event: %v", err)
+	}
+	if e.Type != notify.ChallengeReceived || e.UserId != 7 || e.Challenge.GetId() != c.ID.Hex() {
+		t.Errorf("unexpected event: %v", e)
+	}
+	if e.Challenge.Challenger.GetId() != 3 {
+		t.Errorf("expected challenger 3, got: %v", e.Challenge.Challenger)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	// maxSearchQuery caps the length of the search text
+	maxSearchQuery = 100
+	// searchCandidates is the number of best matches read before hidden users are dropped
+	// and friends are moved to the front
+	searchCandidates = 100
+)
+
+// the models functions search reads from, replaced in tests
+var (
+	searchUsers    = models.SearchUsers
+	searchSegments = models.SearchSegments
+)
+
+// userResult is a user found by a search, friend is set for the viewer's friends
+type userResult struct {
+	models.User
+	Friend bool `json:"friend"`
+}
+
+// segmentResult is a segment found by a search, mine is set for segments in the viewer's list
+type segmentResult struct {
+	models.Segment
+	Mine bool `json:"mine"`
+}
+
+type searchResults struct {
+	Users    []userResult    `json:"users"`
+	Segments []segmentResult `json:"segments"`
+}
+
+// Search finds registered users by name and city and cached segments by name, city and state.
+// The viewer's friends and segments are ranked first, users who do not share their profile with
+// the viewer are left out.
+func Search(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" || len(q) > maxSearchQuery {
+		res.Error(models.InvalidError(fmt.Sprintf("q must be between 1 and %d characters", maxSearchQuery), map[string]string{"q": q}))
+		return
+	}
+	limit, err := parseLimit(r, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	viewer, err := requestViewer(r)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+
+	users, err := searchUsers(q, searchCandidates)
+	if err != nil {
+		res.Error(models.InternalError("unable to search users", err))
+		return
+	}
+	segments, err := searchSegments(q, searchCandidates)
+	if err != nil {
+		res.Error(models.InternalError("unable to search segments", err))
+		return
+	}
+	res.Render(http.StatusOK, searchResults{
+		Users:    rankUsers(users, viewer, limit),
+		Segments: rankSegments(segments, viewer, limit),
+	})
+}
+
+// rankUsers returns up to limit of the users visible to viewer, friends first and otherwise
+// in the order of their match
+func rankUsers(users []models.User, viewer *models.User, limit int) []userResult {
+	results := []userResult{}
+	for _, u := range users {
+		if viewer != nil && u.ID == viewer.ID || !u.VisibleTo(viewer) {
+			continue
+		}
+		results = append(results, userResult{
+			User:   u.ViewFor(viewer),
+			Friend: viewer != nil && viewer.HasFriend(u.ID),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Friend && !results[j].Friend })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// rankSegments returns up to limit of the segments, those in the viewer's list first and
+// otherwise in the order of their match
+func rankSegments(segments []models.Segment, viewer *models.User, limit int) []segmentResult {
+	mine := map[int64]bool{}
+	if viewer != nil {
+		for _, s := range viewer.Segments {
+			mine[s.ID] = true
+		}
+	}
+	results := []segmentResult{}
+	for _, s := range segments {
+		if s.Restricted() {
+			continue
+		}
+		results = append(results, segmentResult{Segment: s, Mine: mine[s.ID]})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Mine && !results[j].Mine })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+func TestRankUsers(t *testing.T) {
+	private := &models.UserSettings{Privacy: models.PrivacyPrivate}
+	friendsOnly := &models.UserSettings{Privacy: models.PrivacyFriends}
+	viewer := &models.User{ID: 1, Friends: []*models.Friend{{ID: 3}, {ID: 5}}}
+	users := []models.User{
+		{ID: 2, FullName: "Stranger", Email: "stranger@example.com"},
+		{ID: 1, FullName: "Viewer"},
+		{ID: 3, FullName: "Friend", Settings: friendsOnly, Friends: []*models.Friend{{ID: 1}}},
+		{ID: 4, FullName: "Private", Settings: private},
+		{ID: 5, FullName: "Other Friend"},
+		{ID: 6, FullName: "Hidden", Settings: friendsOnly},
+	}
+
+	results := rankUsers(users, viewer, 10)
+	var ids []int64
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	if exp := []int64{3, 5, 2}; len(ids) != len(exp) || ids[0] != exp[0] || ids[1] != exp[1] || ids[2] != exp[2] {
+		t.Errorf("expected users %v, got: %v", exp, ids)
+	}
+	if !results[0].Friend || results[2].Friend {
+		t.Errorf("expected only friends to be flagged, got: %+v", results)
+	}
+	if results[2].Email != "" {
+		t.Errorf("expected the email of other users to be hidden, got: %s", results[2].Email)
+	}
+
+	if results := rankUsers(users, viewer, 1); len(results) != 1 || results[0].ID != 3 {
+		t.Errorf("expected the limit to keep the first friend, got: %+v", results)
+	}
+	if results := rankUsers(users, nil, 10); len(results) != 3 {
+		t.Errorf("expected anonymous searches to find the public users, got: %+v", results)
+	}
+}
+
+func TestRankSegments(t *testing.T) {
+	viewer := &models.User{ID: 1, Segments: []*models.UserSegment{{ID: 30}}}
+	segments := []models.Segment{{ID: 10}, {ID: 20, Hazardous: true}, {ID: 30}}
+
+	results := rankSegments(segments, viewer, 10)
+	if len(results) != 2 || results[0].ID != 30 || !results[0].Mine || results[1].ID != 10 || results[1].Mine {
+		t.Errorf("expected the viewer's segment first and no restricted segments, got: %+v", results)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	defer func() { searchUsers, searchSegments = models.SearchUsers, models.SearchSegments }()
+	var limits []int
+	searchUsers = func(q string, limit int) ([]models.User, error) {
+		limits = append(limits, limit)
+		return []models.User{{ID: 2, FullName: "Hawk Rider", Token: "secret"}}, nil
+	}
+	searchSegments = func(q string, limit int) ([]models.Segment, error) {
+		limits = append(limits, limit)
+		return []models.Segment{{ID: 10, Name: "Hawk Hill"}}, nil
+	}
+
+	for _, q := range []string{"", "%20%20", strings.Repeat("a", maxSearchQuery+1)} {
+		rec := httptest.NewRecorder()
+		Search(rec, httptest.NewRequest("GET", "/search?q="+q, nil))
+		if exp := http.StatusBadRequest; rec.Code != exp {
+			t.Errorf("q %q: expected status code %v, got: %v", q, exp, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	Search(rec, httptest.NewRequest("GET", "/search?q=hawk", nil))
+	if exp := http.StatusOK; rec.Code != exp {
+		t.Fatalf("expected status code %v, got: %v", exp, rec.Code)
+	}
+	var body searchResults
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	if len(body.Users) != 1 || body.Users[0].Token != "" || len(body.Segments) != 1 {
+		t.Errorf("unexpected results %+v", body)
+	}
+	if len(limits) != 2 || limits[0] != searchCandidates || limits[1] != searchCandidates {
+		t.Errorf("expected %d candidates to be read, got: %v", searchCandidates, limits)
+	}
+}
@@ -1,26 +1,27 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"strconv"
+	"time"
 
-	"github.com/go-chi/chi"
 	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	"github.com/jrzimmerman/bestrida-server-go/segments"
 	log "github.com/sirupsen/logrus"
-	"github.com/strava/go.strava"
 )
 
+// segmentCache serves cached segments and keeps them within Strava's freshness rule
+var segmentCache = segments.New(segments.MaxAge)
+
 // GetSegmentByID returns segment by ID from the database
 func GetSegmentByID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("id", numID).Errorf("unable to convert segment ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to convert segment ID param",
-		})
+		res.Error(err)
 		return
 	}
 
@@ -28,9 +29,11 @@ func GetSegmentByID(w http.ResponseWriter, r *http.Request) {
 	segment, err := models.GetSegmentByID(numID)
 	if err != nil {
 		log.WithField("id", numID).Errorf("unable to retrieve segment by ID from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to retrieve segment by ID from database",
-		})
+		res.Error(models.InternalError("unable to retrieve segment by ID from database", err))
+		return
+	}
+	if segment.Restricted() {
+		res.Error(models.NotFoundError("segment", numID))
 		return
 	}
 
@@ -39,47 +42,125 @@ func GetSegmentByID(w http.ResponseWriter, r *http.Request) {
 
 // GetSegmentByIDWithUserID gets a segment by ID from database using the users api key
 func GetSegmentByIDWithUserID(w http.ResponseWriter, r *http.Request) {
-	return
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+	// not implemented, authenticated requests get an empty response
 }
 
 // GetSegmentByIDFromStrava returns the strava segment with the specified ID
 func GetSegmentByIDFromStrava(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert segment ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to convert segment ID param",
-		})
+		res.Error(err)
 		return
 	}
 
 	// use our access token to grab generic segment info
-	client := strava.NewClient(accessToken)
+	client := newStravaClient(accessToken, ratelimit.Interactive)
 
-	log.Infof("Fetching segment %v info from strava...", id)
-	segment, err := strava.NewSegmentsService(client).Get(numID).Do()
+	log.Infof("Fetching segment %v info from strava...", numID)
+	segment, err := segmentCache.Get(numID, stravaFetcher(client))
 	if err != nil {
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "Unable to retrieve segment info"})
+		res.Error(models.InternalError("Unable to retrieve segment info", err))
+		return
+	}
+	if segment.Restricted() {
+		res.Error(models.NotFoundError("segment", numID))
 		return
 	}
-	log.Infof("rate limit percent: %v", strava.RateLimiting.FractionReached()*100)
-	log.Infof("segment %v retrieved from strava", segment.Id)
+	log.Infof("segment %v retrieved", segment.ID)
 	res.Render(http.StatusOK, segment)
+}
 
-	// store segment in background after render
-	s, err := models.GetSegmentByID(segment.Id)
-	if err != nil {
-		models.SaveSegment(segment)
-	} else {
-		s.UpdateSegment(segment)
-	}
+// GetSegmentCacheMetrics returns hit, miss and refresh counts for the segment cache
+func GetSegmentCacheMetrics(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+	res.Render(http.StatusOK, segmentCache.Metrics())
 }
 
 // GetSegmentByIDFromStravaWithUserID gets a segment by ID from strava using the users api key
 func GetSegmentByIDFromStravaWithUserID(w http.ResponseWriter, r *http.Request) {
-	return
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+	// not implemented, authenticated requests get an empty response
+}
+
+// segmentSweepJob refreshes stale segments still used by a user or open challenge
+// and purges the rest, as required by the Strava API license agreement
+func segmentSweepJob(ctx context.Context, job *models.Job) error {
+	stale, err := models.GetStaleSegmentIDs(time.Now().Add(-segments.MaxAge))
+	if err != nil {
+		return err
+	}
+	log.Infof("%d stale segments found", len(stale))
+
+	client := newStravaClient(accessToken, ratelimit.Bulk)
+	fetch := stravaFetcher(client)
+	var refreshed, purged, failed int
+	report := func() {
+		log.WithFields(log.Fields{
+			"STALE":     len(stale),
+			"REFRESHED": refreshed,
+			"PURGED":    purged,
+			"FAILED":    failed,
+		}).Info("segment freshness compliance report")
+	}
+	defer report()
+
+	for _, id := range stale {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		referenced, err := models.IsSegmentReferenced(id)
+		if err != nil {
+			failed++
+			continue
+		}
+		if !referenced {
+			if err := models.RemoveSegment(id); err != nil {
+				failed++
+				continue
+			}
+			purged++
+			continue
+		}
+
+		segment, err := segmentCache.Refresh(id, fetch)
+		if errors.Is(err, ratelimit.ErrLimited) {
+			// out of Strava quota, the job is retried later
+			failed++
+			log.Warn("strava quota exhausted, stopping segment sweep")
+			return err
+		}
+		if err != nil {
+			failed++
+			continue
+		}
+		if err := models.UpdateOpenChallengeSegments(segment); err != nil {
+			failed++
+			continue
+		}
+		refreshed++
+	}
+	return nil
 }
@@ -61,7 +61,7 @@ func TestGetSegmentByIDFailureID(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusNotFound; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -83,7 +83,7 @@ func TestGetSegmentByIDFailureInput(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -140,7 +140,7 @@ func TestGetSegmentByIDFromStravaFailureID(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusNotFound; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -162,7 +162,7 @@ func TestGetSegmentByIDFromStravaFailureInput(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+)
+
+// GetUserSettings returns the users settings, defaults fill in anything they never changed
+func GetUserSettings(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	user, err := authenticateUser(r, numID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.Render(http.StatusOK, user.CurrentSettings())
+}
+
+// UpdateUserSettings applies a JSON Merge Patch to the users settings.
+// Setting a key to null resets it to its default, a null time zone lets Strava detect it again.
+func UpdateUserSettings(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	user, err := authenticateUser(r, numID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+
+	current := user.CurrentSettings()
+	settings := current
+	defaults := models.DefaultUserSettings()
+	defaults.TimeZone = user.TimeZone
+	if user.TimeZoneSource == models.TimeZoneFromUser {
+		// removing a zone the user chose hands it back to detection, which keeps the last zone until then
+		defaults.TimeZone = ""
+	}
+	if err := applyMergePatch(r, &settings, defaults); err != nil {
+		res.Error(err)
+		return
+	}
+	if errs := validateSettings(&settings); len(errs) > 0 {
+		res.Error(models.ValidationError("settings failed validation", errs))
+		return
+	}
+
+	if err := models.SaveUserSettings(numID, settings); err != nil {
+		res.Error(models.InternalError("unable to save settings", err))
+		return
+	}
+	switch {
+	case settings.TimeZone == "" && user.TimeZoneSource == models.TimeZoneFromUser:
+		err = models.SaveUserTimeZone(numID, user.TimeZone, "")
+	case settings.TimeZone != "" && settings.TimeZone != current.TimeZone:
+		err = models.SaveUserTimeZone(numID, settings.TimeZone, models.TimeZoneFromUser)
+	}
+	if err != nil {
+		res.Error(models.InternalError("unable to save time zone", err))
+		return
+	}
+	if settings.TimeZone == "" {
+		settings.TimeZone = user.TimeZone
+	}
+	res.Render(http.StatusOK, settings)
+}
+
+// validateSettings checks every setting has an allowed value and normalizes the time zone name
+func validateSettings(s *models.UserSettings) ValidationErrors {
+	var errs ValidationErrors
+	if s.ChallengeDays < 1 || s.ChallengeDays > maxChallengeDays {
+		errs.Add("challengeDays", "out_of_range", "challenges last between 1 and %d days", maxChallengeDays)
+	}
+	switch s.Privacy {
+	case models.PrivacyPublic, models.PrivacyFriends, models.PrivacyPrivate:
+	default:
+		errs.Add("privacy", "invalid", "privacy must be %s, %s or %s", models.PrivacyPublic, models.PrivacyFriends, models.PrivacyPrivate)
+	}
+	switch s.Units {
+	case models.UnitsMetric, models.UnitsImperial:
+	default:
+		errs.Add("units", "invalid", "units must be %s or %s", models.UnitsMetric, models.UnitsImperial)
+	}
+	if s.TimeZone != "" {
+		loc, err := time.LoadLocation(s.TimeZone)
+		if err != nil {
+			errs.Add("timeZone", "unknown", "unknown time zone, use an IANA name such as America/Los_Angeles")
+		} else {
+			s.TimeZone = loc.String()
+		}
+	}
+	return errs
+}
+
+// pushAllowed reports whether the users notification settings let a push notification through
+func pushAllowed(settings models.NotificationSettings, eventType string) bool {
+	if !settings.Push {
+		return false
+	}
+	return eventType != notify.ChallengeExpiring || settings.Reminders
+}
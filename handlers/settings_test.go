@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples from RFC 7396 appendix A
+	tests := []struct {
+		target, patch, result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		var target, patch, exp interface{}
+		json.Unmarshal([]byte(tt.target), &target)
+		json.Unmarshal([]byte(tt.patch), &patch)
+		json.Unmarshal([]byte(tt.result), &exp)
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, exp) {
+			t.Errorf("patching %s with %s: expected %v, got: %v", tt.target, tt.patch, exp, got)
+		}
+	}
+}
+
+func patchRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
+
+func TestApplySettingsPatch(t *testing.T) {
+	current := models.DefaultUserSettings()
+	current.Units = models.UnitsImperial
+	current.Notifications.Reminders = false
+	current.TimeZone = "Europe/Paris"
+	defaults := models.DefaultUserSettings()
+
+	settings := current
+	body := `{"privacy":"friends","units":null,"notifications":{"reminders":null,"push":false}}`
+	if err := applyMergePatch(patchRequest(mergePatchType, body), &settings, defaults); err != nil {
+		t.Fatalf("unable to apply patch: %v", err)
+	}
+	exp := models.UserSettings{
+		Notifications: models.NotificationSettings{Push: false, Reminders: true},
+		ChallengeDays: 7,
+		Privacy:       models.PrivacyFriends,
+		Units:         models.UnitsMetric,
+		TimeZone:      "Europe/Paris",
+	}
+	if settings != exp {
+		t.Errorf("expected %+v, got: %+v", exp, settings)
+	}
+
+	for _, tt := range []struct{ contentType, body string }{
+		{mergePatchType, `{"color":"red"}`},
+		{mergePatchType, `{"challengeDays":"week"}`},
+		{mergePatchType, `["privacy"]`},
+		{mergePatchType, `{`},
+		{"text/plain", `{"privacy":"friends"}`},
+	} {
+		settings := current
+		err := applyMergePatch(patchRequest(tt.contentType, tt.body), &settings, defaults)
+		if kind := models.ErrorKind(err); kind != models.KindInvalid {
+			t.Errorf("%s %s: expected %s error, got: %v", tt.contentType, tt.body, models.KindInvalid, err)
+		}
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	valid := models.DefaultUserSettings()
+	valid.TimeZone = "America/Los_Angeles"
+	if errs := validateSettings(&valid); len(errs) > 0 {
+		t.Errorf("expected valid settings, got: %v", errs)
+	}
+
+	invalid := models.UserSettings{ChallengeDays: maxChallengeDays + 1, Privacy: "secret", Units: "furlongs", TimeZone: "Mars/Olympus"}
+	errs := validateSettings(&invalid)
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	if exp := []string{"challengeDays", "privacy", "units", "timeZone"}; !reflect.DeepEqual(fields, exp) {
+		t.Errorf("expected errors for %v, got: %v", exp, fields)
+	}
+}
+
+func TestPushAllowed(t *testing.T) {
+	tests := []struct {
+		settings models.NotificationSettings
+		event    string
+		exp      bool
+	}{
+		{models.NotificationSettings{Push: true, Reminders: true}, notify.ChallengeExpiring, true},
+		{models.NotificationSettings{Push: true, Reminders: false}, notify.ChallengeExpiring, false},
+		{models.NotificationSettings{Push: true, Reminders: false}, notify.ChallengeReceived, true},
+		{models.NotificationSettings{Push: false, Reminders: true}, notify.ChallengeReceived, false},
+	}
+	for _, tt := range tests {
+		if got := pushAllowed(tt.settings, tt.event); got != tt.exp {
+			t.Errorf("%+v %s: expected %v, got: %v", tt.settings, tt.event, tt.exp, got)
+		}
+	}
+}
+
+func TestUserSettingsRequireAccessToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/{id}/settings", GetUserSettings)
+	r.Patch("/{id}/settings", UpdateUserSettings)
+
+	for _, method := range []string{"GET", "PATCH"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, "/1/settings", strings.NewReader(`{"privacy":"private"}`)))
+		if exp := http.StatusUnauthorized; rec.Code != exp {
+			t.Errorf("%s: expected status code %v, got: %v", method, exp, rec.Code)
+		}
+	}
+}
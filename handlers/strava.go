@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	"github.com/jrzimmerman/bestrida-server-go/segments"
 	log "github.com/sirupsen/logrus"
 	"github.com/strava/go.strava"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // AuthHandler Route to display Auth button
@@ -30,11 +34,41 @@ func oAuthSuccess(auth *strava.AuthorizationResponse, w http.ResponseWriter, r *
 		log.Errorf("error registering user %d", auth.Athlete.Id)
 	}
 
-	go GetFriendsFromStrava(auth.Athlete.Id)
+	enqueueJob(SyncFriendsJob, bson.M{"userId": auth.Athlete.Id})
+	enqueueJob(SyncSegmentsJob, bson.M{"userId": auth.Athlete.Id})
+}
+
+// newStravaClient returns a Strava client whose requests wait on the shared rate limiter
+func newStravaClient(token string, p ratelimit.Priority) *strava.Client {
+	return strava.NewClient(token, ratelimit.Strava.Client(p))
+}
+
+// stravaError types an error from the Strava API so it renders as an upstream failure,
+// rate limit errors are returned as they are
+func stravaError(err error) error {
+	var typed *models.Error
+	if err == nil || errors.Is(err, ratelimit.ErrLimited) || errors.As(err, &typed) {
+		return err
+	}
+	if e, ok := err.(strava.Error); ok && e.Message == "Record Not Found" {
+		return &models.Error{Kind: models.KindNotFound, Message: "record not found on Strava", Err: err}
+	}
+	return models.UpstreamError("Strava", err)
+}
+
+// stravaFetcher fetches segments for the cache with errors from the Strava API typed
+func stravaFetcher(client *strava.Client) segments.Fetcher {
+	fetch := segments.ClientFetcher(client)
+	return func(id int64) (*strava.SegmentDetailed, error) {
+		segment, err := fetch(id)
+		return segment, stravaError(err)
+	}
+}
 
-	// page refers to the number of activities to collect for a user
-	var page = 3
-	go GetUserSegmentsFromStrava(auth.Athlete.Id, page)
+// GetStravaQuota returns the current Strava rate limit usage
+func GetStravaQuota(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+	res.Render(http.StatusOK, ratelimit.Strava.Usage())
 }
 
 func oAuthFailure(err error, w http.ResponseWriter, r *http.Request) {
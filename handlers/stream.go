@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	"github.com/jrzimmerman/bestrida-server-go/realtime"
+	log "github.com/sirupsen/logrus"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+// eventHub pushes events to connected clients
+var eventHub *realtime.Hub
+
+// RegisterHub sets the hub events are published to and streamed from
+func RegisterHub(h *realtime.Hub) {
+	eventHub = h
+}
+
+// publishEvent sends a realtime event to a user, logging instead of failing the request
+func publishEvent(userID int64, eventType string, data interface{}) {
+	if eventHub == nil {
+		return
+	}
+	e, err := realtime.NewEvent(userID, eventType, data)
+	if err != nil {
+		log.WithError(err).Errorf("unable to encode %s event", eventType)
+		return
+	}
+	if err := eventHub.Publish(e); err != nil {
+		log.WithError(err).Errorf("unable to publish %s event to user %d", eventType, userID)
+	}
+}
+
+type streamEvent struct {
+	Notification notify.Notification `json:"notification"`
+	Challenge    *models.Challenge   `json:"challenge,omitempty"`
+}
+
+// StreamEvents pushes challenge and notification events to the user as Server-Sent Events
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if eventHub == nil {
+		res.Error(models.UnavailableError("realtime events are not available"))
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		res.Error(models.InternalError("streaming unsupported", nil))
+		return
+	}
+
+	sub := eventHub.Subscribe(numID)
+	defer eventHub.Unsubscribe(sub)
+	log.WithField("USER ID", numID).Info("event stream opened")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			log.WithField("USER ID", numID).Info("event stream closed")
+			return
+		case <-eventHub.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+			flusher.Flush()
+		}
+	}
+}
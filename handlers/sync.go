@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/jobs"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/ratelimit"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+)
+
+// usersSyncID is the checkpoint ID for the all users Strava sync
+const usersSyncID = "users"
+
+// syncBatchSize is the number of users loaded and checkpointed at a time
+const syncBatchSize = 25
+
+// failed users are retried on later runs with exponential backoff
+const (
+	syncRetryBase = time.Hour
+	syncRetryMax  = 7 * 24 * time.Hour
+)
+
+// syncAllUsersJob syncs every user from Strava in batches.
+// Progress is checkpointed after every batch so an interrupted run resumes where it stopped.
+func syncAllUsersJob(ctx context.Context, job *models.Job) error {
+	checkpoint, err := models.GetSyncCheckpoint(usersSyncID)
+	if err == mgo.ErrNotFound || (err == nil && checkpoint.FinishedAt != nil) {
+		checkpoint = &models.SyncCheckpoint{ID: usersSyncID, StartedAt: time.Now()}
+	} else if err != nil {
+		return err
+	} else {
+		log.Infof("resuming user sync after user %d", checkpoint.LastUserID)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		users, err := models.GetUsersAfter(checkpoint.LastUserID, syncBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, u := range users {
+			if err := ctx.Err(); err != nil {
+				checkpoint.SaveSyncCheckpoint()
+				return err
+			}
+			err := syncUser(u)
+			if errors.Is(err, ratelimit.ErrLimited) {
+				// out of Strava quota, stop here and let the job retry from the checkpoint
+				checkpoint.SaveSyncCheckpoint()
+				return err
+			}
+			switch err {
+			case nil:
+				checkpoint.Succeeded++
+			case errSyncSkipped:
+				checkpoint.Skipped++
+			default:
+				checkpoint.Failed++
+			}
+			checkpoint.Processed++
+			checkpoint.LastUserID = u.ID
+		}
+		if err := checkpoint.SaveSyncCheckpoint(); err != nil {
+			return err
+		}
+	}
+
+	finished := time.Now()
+	checkpoint.FinishedAt = &finished
+	log.Infof("user sync finished: %d processed, %d succeeded, %d failed, %d skipped",
+		checkpoint.Processed, checkpoint.Succeeded, checkpoint.Failed, checkpoint.Skipped)
+	return checkpoint.SaveSyncCheckpoint()
+}
+
+// errSyncSkipped marks a user still waiting to retry a previous failure
+var errSyncSkipped = errors.New("user sync waiting for retry")
+
+// syncUser updates a single user from Strava and records the result
+func syncUser(u models.User) error {
+	state, err := models.GetUserSync(u.ID)
+	if err != nil {
+		return err
+	}
+	if state.RetryAt != nil && time.Now().Before(*state.RetryAt) {
+		log.WithField("USER ID", u.ID).Infof("user %d sync failed previously, retrying after %v", u.ID, *state.RetryAt)
+		return errSyncSkipped
+	}
+
+	started := time.Now()
+	err = syncUserFromStrava(u.ID)
+	if errors.Is(err, ratelimit.ErrLimited) {
+		return err
+	}
+	if err != nil {
+		retryAt := time.Now().Add(jobs.Backoff(state.Failures+1, syncRetryBase, syncRetryMax))
+		log.WithError(err).Errorf("unable to sync user %d, retrying after %v", u.ID, retryAt)
+		models.RecordUserSyncFailure(u.ID, err.Error(), retryAt)
+		return err
+	}
+	return models.RecordUserSyncSuccess(u.ID, started)
+}
+
+// syncUserFromStrava updates the athlete, friends and segments for a user
+func syncUserFromStrava(userID int64) error {
+	if _, err := UpdateAthleteFromStrava(userID, ratelimit.Bulk); err != nil {
+		return err
+	}
+	if _, err := GetFriendsFromStrava(userID, ratelimit.Bulk); err != nil {
+		return err
+	}
+	if _, err := GetUserSegmentsFromStrava(userID, ratelimit.Bulk); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUserSyncStatus returns the progress of the all users sync and the users that failed
+func GetUserSyncStatus(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	checkpoint, err := models.GetSyncCheckpoint(usersSyncID)
+	if err != nil && err != mgo.ErrNotFound {
+		log.WithError(err).Error("unable to get user sync checkpoint from database")
+		res.Error(models.InternalError("unable to get user sync checkpoint from database", err))
+		return
+	}
+	failed, err := models.GetFailedUserSyncs()
+	if err != nil {
+		log.WithError(err).Error("unable to get failed user syncs from database")
+		res.Error(models.InternalError("unable to get failed user syncs from database", err))
+		return
+	}
+	res.Render(http.StatusOK, map[string]interface{}{
+		"checkpoint": checkpoint,
+		"failed":     failed,
+	})
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
-	"github.com/go-chi/chi"
 	"github.com/jrzimmerman/bestrida-server-go/models"
 	log "github.com/sirupsen/logrus"
 )
@@ -13,43 +14,41 @@ import (
 func GetUserByID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "unable to convert ID param"})
+		res.Error(err)
 		return
 	}
 
+	viewer, err := requestViewer(r)
+	if err != nil {
+		res.Error(err)
+		return
+	}
 	user, err := models.GetUserByID(numID)
 	if err != nil {
 		log.WithField("ID", numID).Error("unable to get user by ID from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "unable to get user by ID from database"})
+		res.Error(models.InternalError("unable to get user by ID from database", err))
 		return
 	}
 
 	log.WithField("USER ID", user.ID).Infof("user %d found", user.ID)
-	res.Render(http.StatusOK, user)
+	res.Render(http.StatusOK, user.ViewFor(viewer))
 }
 
 // GetSegmentsByUserID returns a slice of user segments from the database
 func GetSegmentsByUserID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "unable to convert ID param"})
+		res.Error(err)
 		return
 	}
 
-	user, err := models.GetUserByID(numID)
+	user, err := visibleUser(r, numID)
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to get user by ID from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "unable to get user by ID from database"})
+		res.Error(err)
 		return
 	}
 
@@ -62,21 +61,15 @@ func GetSegmentsByUserID(w http.ResponseWriter, r *http.Request) {
 func GetFriendsByUserID(w http.ResponseWriter, r *http.Request) {
 	res := New(w)
 
-	id := chi.URLParam(r, "id")
-
-	numID, err := strconv.ParseInt(id, 10, 64)
+	numID, err := int64Param(r, "id")
 	if err != nil {
-		log.WithField("ID", numID).Error("unable to convert ID param")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{"error": "unable to convert ID param"})
+		res.Error(err)
 		return
 	}
 
-	user, err := models.GetUserByID(numID)
+	user, err := visibleUser(r, numID)
 	if err != nil {
-		log.WithField("USER ID", numID).Error("unable to get user by ID from database")
-		res.Render(http.StatusInternalServerError, map[string]interface{}{
-			"error": "unable to get user by ID from database",
-		})
+		res.Error(err)
 		return
 	}
 
@@ -84,3 +77,141 @@ func GetFriendsByUserID(w http.ResponseWriter, r *http.Request) {
 	log.WithField("USER ID", user.ID).Infof("user %d has %d friends", user.ID, len(user.Friends))
 	res.Render(http.StatusOK, user.Friends)
 }
+
+// friendKeys returns the sort key of a friend for each sort option
+var friendKeys = map[string]func(*models.Friend) string{
+	"name":       func(f *models.Friend) string { return strings.ToLower(f.FullName) },
+	"challenges": func(f *models.Friend) string { return countKey(f.ChallengeCount) },
+	"wins":       func(f *models.Friend) string { return countKey(f.Wins) },
+	"losses":     func(f *models.Friend) string { return countKey(f.Losses) },
+}
+
+// ListFriendsByUserID returns a page of a users friends sorted by name or challenge record
+func ListFriendsByUserID(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	field, desc, err := parseSort(r, []string{"name", "challenges", "wins", "losses"}, "name")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+
+	user, err := visibleUser(r, numID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+
+	friends, key := user.Friends, friendKeys[field]
+	start, end, next, err := pageList(r, len(friends),
+		func(i int) listCursor { return listCursor{Key: key(friends[i]), ID: friends[i].ID} },
+		func(i, j int) { friends[i], friends[j] = friends[j], friends[i] },
+		desc, limit)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.Render(http.StatusOK, page{Items: append([]*models.Friend{}, friends[start:end]...), Total: len(friends), Next: next})
+}
+
+// segmentKeys returns the sort key of a user segment for each sort option
+var segmentKeys = map[string]func(*models.UserSegment) string{
+	"name":  func(s *models.UserSegment) string { return strings.ToLower(s.Name) },
+	"count": func(s *models.UserSegment) string { return countKey(s.Count) },
+}
+
+// ListSegmentsByUserID returns a page of a users segments filtered by activity type
+func ListSegmentsByUserID(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	field, desc, err := parseSort(r, []string{"name", "count"}, "name")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+
+	user, err := visibleUser(r, numID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+
+	activityType := r.URL.Query().Get("activityType")
+	segments := []*models.UserSegment{}
+	for _, s := range user.Segments {
+		if activityType == "" || s.ActivityType == activityType {
+			segments = append(segments, s)
+		}
+	}
+	key := segmentKeys[field]
+	start, end, next, err := pageList(r, len(segments),
+		func(i int) listCursor { return listCursor{Key: key(segments[i]), ID: segments[i].ID} },
+		func(i, j int) { segments[i], segments[j] = segments[j], segments[i] },
+		desc, limit)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	res.Render(http.StatusOK, page{Items: segments[start:end], Total: len(segments), Next: next})
+}
+
+type timeZoneRequest struct {
+	TimeZone string `json:"timeZone"`
+}
+
+// UpdateUserTimeZone sets the time zone challenge windows are computed in,
+// once set by the user it is no longer detected from Strava activities
+func UpdateUserTimeZone(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return
+	}
+
+	var req timeZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error(models.InvalidError("Could not unmarshal request to update time zone", nil))
+		return
+	}
+	if req.TimeZone == "" {
+		res.Error(models.InvalidError("time zone is required", nil))
+		return
+	}
+	loc, err := time.LoadLocation(req.TimeZone)
+	if err != nil {
+		res.Error(models.ValidationError("unknown time zone, use an IANA name such as America/Los_Angeles", map[string]string{"timeZone": req.TimeZone}))
+		return
+	}
+
+	if err := models.SaveUserTimeZone(numID, loc.String(), models.TimeZoneFromUser); err != nil {
+		res.Error(models.InternalError("unable to save time zone", err))
+		return
+	}
+	res.Render(http.StatusOK, map[string]interface{}{"timeZone": loc.String()})
+}
@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
 )
 
 // func TestGetUserByIDSuccess(t *testing.T) {
@@ -61,7 +63,7 @@ func TestGetUserByIDFailureID(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusNotFound; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -83,7 +85,7 @@ func TestGetUserByIDFailureName(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -138,7 +140,7 @@ func TestGetSegmentsByUserIDFailureID(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusNotFound; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -160,7 +162,7 @@ func TestGetSegmentsByUserIDFailureName(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -215,7 +217,7 @@ func TestGetFriendsByUserIDFailureID(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusNotFound; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
@@ -237,7 +239,40 @@ func TestGetFriendsByUserIDFailureName(t *testing.T) {
 	resp, err := http.DefaultClient.Do(req)
 
 	// Check the status code
-	if exp := http.StatusInternalServerError; resp.StatusCode != exp {
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
 		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
 	}
 }
+
+func TestCheckVisible(t *testing.T) {
+	u := &models.User{ID: 1, Friends: []*models.Friend{{ID: 2}}, Settings: &models.UserSettings{Privacy: models.PrivacyFriends}}
+	tests := []struct {
+		viewer *models.User
+		kind   string
+	}{
+		{&models.User{ID: 1}, ""},
+		{&models.User{ID: 2}, ""},
+		{&models.User{ID: 3}, models.KindForbidden},
+		{nil, models.KindUnauthorized},
+	}
+	for _, tt := range tests {
+		err := checkVisible(u, tt.viewer)
+		if tt.kind == "" && err != nil {
+			t.Errorf("%+v: expected the profile to be visible, got: %v", tt.viewer, err)
+		}
+		if tt.kind != "" && models.ErrorKind(err) != tt.kind {
+			t.Errorf("%+v: expected %s error, got: %v", tt.viewer, tt.kind, err)
+		}
+	}
+}
+
+func TestUpdateUserTimeZoneRequiresAccessToken(t *testing.T) {
+	r := chi.NewRouter()
+	r.Put("/{id}/timezone", UpdateUserTimeZone)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("PUT", "/1/timezone", strings.NewReader(`{"timeZone":"Europe/Paris"}`)))
+	if exp := http.StatusUnauthorized; rec.Code != exp {
+		t.Errorf("expected status code %v, got: %v", exp, rec.Code)
+	}
+}
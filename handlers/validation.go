@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/utils"
+)
+
+// challenge creation limits
+var (
+	maxChallengeDays         = utils.GetEnvInt("MAX_CHALLENGE_DAYS", 30)
+	maxOpenChallengesPerPair = utils.GetEnvInt("MAX_OPEN_CHALLENGES_PER_PAIR", 3)
+)
+
+// challengeActivityTypes are the segment activity types challenges can be created on
+var challengeActivityTypes = []string{"Ride", "Run"}
+
+// FieldError describes why a single request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every invalid field in a request
+type ValidationErrors []FieldError
+
+// Add records an invalid field
+func (v *ValidationErrors) Add(field, code, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Error lists the invalid fields
+func (v ValidationErrors) Error() string {
+	fields := make([]string, len(v))
+	for i, e := range v {
+		fields[i] = e.Field + ": " + e.Message
+	}
+	return "invalid request: " + strings.Join(fields, ", ")
+}
+
+// validateCreateRequest checks the required fields are present
+func validateCreateRequest(req createRequest) ValidationErrors {
+	var errs ValidationErrors
+	if req.SegmentID <= 0 {
+		errs.Add("segmentId", "required", "segment ID is required")
+	}
+	if req.ChallengerID <= 0 {
+		errs.Add("challengerId", "required", "challenger ID is required")
+	}
+	if req.ChallengeeID <= 0 {
+		errs.Add("challengeeId", "required", "challengee ID is required")
+	}
+	if req.CompletionDate.IsZero() {
+		errs.Add("completionDate", "required", "completion date is required")
+	}
+	return errs
+}
+
+// validateChallengeRules checks a challenge window and segment can be challenged on.
+// created and expires are the window boundaries and now is compared in the challengers time zone.
+func validateChallengeRules(req createRequest, created, expires time.Time, segment *models.Segment, now time.Time, loc *time.Location) ValidationErrors {
+	var errs ValidationErrors
+	if req.ChallengerID == req.ChallengeeID {
+		errs.Add("challengeeId", "self_challenge", "you cannot challenge yourself")
+	}
+
+	today := now.In(loc)
+	endOfToday := time.Date(today.Year(), today.Month(), today.Day(), 23, 59, 59, 0, loc)
+	switch {
+	case expires.Before(endOfToday):
+		errs.Add("completionDate", "in_past", "completion date cannot be in the past")
+	case expires.Before(created):
+		errs.Add("completionDate", "before_creation", "completion date cannot be before the creation date")
+	case expires.Sub(created) > time.Duration(maxChallengeDays)*24*time.Hour:
+		errs.Add("completionDate", "too_long", "challenges can last at most %d days", maxChallengeDays)
+	}
+
+	if segment != nil && segment.Restricted() {
+		errs.Add("segmentId", "restricted", "segment %d is private or hazardous on Strava", segment.ID)
+	} else if segment != nil {
+		supported := false
+		for _, t := range challengeActivityTypes {
+			if segment.ActivityType == t {
+				supported = true
+			}
+		}
+		if !supported {
+			errs.Add("segmentId", "unsupported_activity_type", "segments with activity type %q cannot be challenged", segment.ActivityType)
+		} else if req.ActivityType != "" && req.ActivityType != segment.ActivityType {
+			errs.Add("activityType", "activity_type_mismatch", "segment is a %s segment", segment.ActivityType)
+		}
+	}
+	return errs
+}
+
+// validateOpenChallenges checks the pair has no open challenge on the segment and is under the open challenge cap
+func validateOpenChallenges(req createRequest, open []models.Challenge) ValidationErrors {
+	var errs ValidationErrors
+	for _, c := range open {
+		if c.Segment != nil && c.Segment.ID == int64(req.SegmentID) {
+			errs.Add("segmentId", "duplicate", "there is already an open challenge between these users on this segment")
+			break
+		}
+	}
+	if len(open) >= maxOpenChallengesPerPair {
+		errs.Add("challengeeId", "too_many_open", "these users already have %d open challenges", len(open))
+	}
+	return errs
+}
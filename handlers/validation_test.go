@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+func hasFieldError(errs ValidationErrors, field, code string) bool {
+	for _, e := range errs {
+		if e.Field == field && e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCreateChallengeMalformed tests malformed create requests are rejected with field errors
+func TestCreateChallengeMalformed(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/", CreateChallenge)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for _, body := range []string{`{"segmentId": "abc"`, `{"segmentId": 1}`} {
+		resp, err := http.Post(fmt.Sprintf("%s/", server.URL), "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal("unable to send request", err)
+		}
+		if exp := http.StatusBadRequest; resp.StatusCode != exp {
+			t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+		}
+		var out struct {
+			Details ValidationErrors `json:"details"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Errorf("unable to decode response: %s", err)
+		}
+		resp.Body.Close()
+		if len(out.Details) == 0 {
+			t.Errorf("expected field errors for %s", body)
+		}
+	}
+}
+
+func TestValidateCreateRequest(t *testing.T) {
+	errs := validateCreateRequest(createRequest{SegmentID: 1})
+	for _, field := range []string{"challengerId", "challengeeId", "completionDate"} {
+		if !hasFieldError(errs, field, "required") {
+			t.Errorf("expected %s to be required, got %v", field, errs)
+		}
+	}
+	if hasFieldError(errs, "segmentId", "required") {
+		t.Errorf("unexpected segmentId error")
+	}
+}
+
+func TestValidateChallengeRules(t *testing.T) {
+	now := time.Date(2018, 6, 10, 12, 0, 0, 0, time.UTC)
+	ride := &models.Segment{ActivityType: "Ride"}
+	day := func(d int) time.Time { return time.Date(2018, 6, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		req      createRequest
+		creation time.Time
+		segment  *models.Segment
+		field    string
+		code     string
+	}{
+		{"self", createRequest{ChallengerID: 1, ChallengeeID: 1, CompletionDate: day(12)}, day(10), ride, "challengeeId", "self_challenge"},
+		{"past", createRequest{ChallengerID: 1, ChallengeeID: 2, CompletionDate: day(9)}, day(8), ride, "completionDate", "in_past"},
+		{"before creation", createRequest{ChallengerID: 1, ChallengeeID: 2, CompletionDate: day(12)}, day(14), ride, "completionDate", "before_creation"},
+		{"too long", createRequest{ChallengerID: 1, ChallengeeID: 2, CompletionDate: day(10).AddDate(0, 0, maxChallengeDays+1)}, day(10), ride, "completionDate", "too_long"},
+		{"activity type", createRequest{ChallengerID: 1, ChallengeeID: 2, CompletionDate: day(12)}, day(10), &models.Segment{ActivityType: "Hike"}, "segmentId", "unsupported_activity_type"},
+		{"restricted", createRequest{ChallengerID: 1, ChallengeeID: 2, CompletionDate: day(12)}, day(10), &models.Segment{ActivityType: "Ride", Hazardous: true}, "segmentId", "restricted"},
+		{"activity mismatch", createRequest{ChallengerID: 1, ChallengeeID: 2, CompletionDate: day(12), ActivityType: "Run"}, day(10), ride, "activityType", "activity_type_mismatch"},
+	}
+	for _, tc := range tests {
+		created, expires := challengeWindow(tc.creation, tc.req.CompletionDate, time.UTC)
+		errs := validateChallengeRules(tc.req, created, expires, tc.segment, now, time.UTC)
+		if !hasFieldError(errs, tc.field, tc.code) {
+			t.Errorf("%s: expected %s %s, got %v", tc.name, tc.field, tc.code, errs)
+		}
+	}
+
+	created, expires := challengeWindow(day(10), day(10), time.UTC)
+	if errs := validateChallengeRules(createRequest{ChallengerID: 1, ChallengeeID: 2}, created, expires, ride, now, time.UTC); len(errs) != 0 {
+		t.Errorf("expected a challenge ending today to be valid, got %v", errs)
+	}
+}
+
+func TestValidateOpenChallenges(t *testing.T) {
+	open := []models.Challenge{{Segment: &models.Segment{ID: 7}}}
+	if errs := validateOpenChallenges(createRequest{SegmentID: 7}, open); !hasFieldError(errs, "segmentId", "duplicate") {
+		t.Errorf("expected duplicate error, got %v", errs)
+	}
+	if errs := validateOpenChallenges(createRequest{SegmentID: 8}, open); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+
+	open = make([]models.Challenge, maxOpenChallengesPerPair)
+	if errs := validateOpenChallenges(createRequest{SegmentID: 8}, open); !hasFieldError(errs, "challengeeId", "too_many_open") {
+		t.Errorf("expected open challenge cap error, got %v", errs)
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/jobs"
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify"
+	"github.com/jrzimmerman/bestrida-server-go/webhooks"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// webhookEvents maps challenge lifecycle events to the webhook events they trigger
+var webhookEvents = map[string]string{
+	notify.ChallengeReceived: webhooks.ChallengeCreated,
+	notify.ChallengeAccepted: webhooks.ChallengeAccepted,
+	notify.OpponentCompleted: webhooks.ChallengeCompleted,
+	notify.ChallengeSettled:  webhooks.ChallengeSettled,
+}
+
+type webhookPayload struct {
+	ID        string           `json:"id"`
+	Event     string           `json:"event"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Challenge models.Challenge `json:"challenge"`
+}
+
+// emitWebhookEvent queues a delivery to every webhook either participant subscribed to the event
+func emitWebhookEvent(event string, c *models.Challenge) {
+	hookEvent, ok := webhookEvents[event]
+	if !ok {
+		return
+	}
+	hooks, err := models.GetWebhooksForEvent(hookEvent, []int64{c.Challenger.ID, c.Challengee.ID})
+	if err != nil || len(hooks) == 0 {
+		return
+	}
+
+	for _, hook := range hooks {
+		deliveryID := bson.NewObjectId().Hex()
+		body, err := json.Marshal(webhookPayload{
+			ID:        deliveryID,
+			Event:     hookEvent,
+			CreatedAt: time.Now(),
+			Challenge: *c,
+		})
+		if err != nil {
+			log.WithError(err).Errorf("unable to encode %s webhook", hookEvent)
+			return
+		}
+		enqueueJob(WebhookDeliveryJob, bson.M{
+			"ownerId":    hook.OwnerID,
+			"webhookId":  hook.ID.Hex(),
+			"deliveryId": deliveryID,
+			"event":      hookEvent,
+			"body":       string(body),
+		})
+	}
+}
+
+// webhookDeliveryJob posts a queued event to a webhook, failures are retried by the job runner with backoff
+func webhookDeliveryJob(ctx context.Context, job *models.Job) error {
+	ownerID, err := jobs.PayloadInt64(job, "ownerId")
+	if err != nil {
+		return err
+	}
+	webhookID, _ := job.Payload["webhookId"].(string)
+	deliveryID, _ := job.Payload["deliveryId"].(string)
+	event, _ := job.Payload["event"].(string)
+	body, _ := job.Payload["body"].(string)
+	if !bson.IsObjectIdHex(webhookID) {
+		return fmt.Errorf("job %s payload is missing webhookId", job.Name)
+	}
+
+	hook, err := models.GetWebhookByID(ownerID, bson.ObjectIdHex(webhookID))
+	if models.IsNotFound(err) {
+		log.WithField("WEBHOOK ID", webhookID).Info("webhook removed, dropping delivery")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := webhooks.Deliver(webhooks.Client, hook.URL, hook.Secret, event, deliveryID, []byte(body))
+	delivery := models.WebhookDelivery{
+		WebhookID:   hook.ID,
+		DeliveryID:  deliveryID,
+		Event:       event,
+		Attempt:     job.Attempts,
+		Success:     err == nil,
+		StatusCode:  res.StatusCode,
+		DurationMS:  int64(res.Duration / time.Millisecond),
+		AttemptedAt: time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+		log.WithError(err).Errorf("webhook %s delivery %s attempt %d failed", webhookID, deliveryID, job.Attempts)
+	}
+	models.SaveWebhookDelivery(delivery)
+	return err
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// webhookOwner parses and authenticates the user that owns the webhooks in the request path
+func webhookOwner(res *Response, r *http.Request) (int64, bool) {
+	numID, err := int64Param(r, "id")
+	if err != nil {
+		res.Error(err)
+		return 0, false
+	}
+	if _, err := authenticateUser(r, numID); err != nil {
+		res.Error(err)
+		return 0, false
+	}
+	return numID, true
+}
+
+// CreateWebhook registers an outbound webhook, the signing secret is only returned here
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error(models.InvalidError("Could not unmarshal request to create webhook", nil))
+		return
+	}
+	if err := webhooks.ValidateURL(req.URL); err != nil {
+		res.Error(models.InvalidError(err.Error(), map[string]string{"url": req.URL}))
+		return
+	}
+	if len(req.Events) == 0 {
+		res.Error(models.InvalidError("at least one event is required", map[string]interface{}{"events": webhooks.Events}))
+		return
+	}
+	for _, e := range req.Events {
+		if !webhooks.ValidEvent(e) {
+			res.Error(models.InvalidError(fmt.Sprintf("unknown event %s", e), map[string]interface{}{"events": webhooks.Events}))
+			return
+		}
+	}
+
+	ownerID, ok := webhookOwner(res, r)
+	if !ok {
+		return
+	}
+	if req.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			res.Error(models.InternalError("unable to generate webhook secret", err))
+			return
+		}
+		req.Secret = secret
+	}
+
+	hook, err := models.CreateWebhook(models.Webhook{
+		OwnerID: ownerID,
+		URL:     req.URL,
+		Secret:  req.Secret,
+		Events:  req.Events,
+	})
+	if err != nil {
+		res.Error(models.InternalError("unable to create webhook", err))
+		return
+	}
+	res.Render(http.StatusCreated, map[string]interface{}{
+		"webhook": hook,
+		"secret":  hook.Secret,
+	})
+}
+
+// GetWebhooksByUserID returns the webhooks a user registered
+func GetWebhooksByUserID(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	ownerID, ok := webhookOwner(res, r)
+	if !ok {
+		return
+	}
+	hooks, err := models.GetWebhooksByOwner(ownerID)
+	if err != nil {
+		res.Error(models.InternalError("unable to get webhooks from database", err))
+		return
+	}
+	res.Render(http.StatusOK, hooks)
+}
+
+// RemoveWebhook stops deliveries to a webhook
+func RemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	webhookID, err := objectIDParam(r, "webhookID")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	ownerID, ok := webhookOwner(res, r)
+	if !ok {
+		return
+	}
+	if err := models.RemoveWebhook(ownerID, webhookID); err != nil {
+		res.Error(models.InternalError("unable to remove webhook", err))
+		return
+	}
+	res.Render(http.StatusOK, "webhook removed")
+}
+
+// GetWebhookDeliveries returns the delivery log for a webhook
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	res := New(w)
+
+	webhookID, err := objectIDParam(r, "webhookID")
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	ownerID, ok := webhookOwner(res, r)
+	if !ok {
+		return
+	}
+	hook, err := models.GetWebhookByID(ownerID, webhookID)
+	if err != nil {
+		res.Error(err)
+		return
+	}
+	deliveries, err := models.GetWebhookDeliveries(hook.ID, 50)
+	if err != nil {
+		res.Error(models.InternalError("unable to get webhook deliveries from database", err))
+		return
+	}
+	res.Render(http.StatusOK, deliveries)
+}
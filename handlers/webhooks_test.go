@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestCreateWebhookInvalidURL(t *testing.T) {
+	r := chi.NewRouter()
+	r.Post("/{id}/webhooks", CreateWebhook)
+	server := httptest.NewServer(r)
+
+	// Create the http request
+	body := bytes.NewBufferString(`{"url":"ftp://example.com","events":["challenge.settled"]}`)
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/1/webhooks", server.URL), body)
+	if err != nil {
+		t.Error("unable to generate request", err)
+	}
+
+	// Send the request to the API
+	resp, err := http.DefaultClient.Do(req)
+
+	// Check the status code
+	if exp := http.StatusBadRequest; resp.StatusCode != exp {
+		t.Errorf("expected status code %v, got: %v", exp, resp.StatusCode)
+	}
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrLeaseHeld is returned when another instance holds the lease
+var ErrLeaseHeld = errors.New("lease held by another instance")
+
+// Lease is a MongoDB lease held by this instance and renewed in the background
+type Lease struct {
+	name  string
+	owner string
+	ttl   time.Duration
+	stop  chan struct{}
+	lost  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// AcquireLease takes the named lease for owner and keeps renewing it
+// every third of the ttl until Release is called
+func AcquireLease(name, owner string, ttl time.Duration) (*Lease, error) {
+	_, ok, err := models.AcquireLease(name, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLeaseHeld
+	}
+
+	l := &Lease{
+		name:  name,
+		owner: owner,
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}
+	l.wg.Add(1)
+	go l.renew()
+	return l, nil
+}
+
+// Lost is closed when the lease could not be renewed and may now be held by someone else
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release stops renewing and gives up the lease
+func (l *Lease) Release() error {
+	l.once.Do(func() { close(l.stop) })
+	l.wg.Wait()
+	return models.ReleaseLease(l.name, l.owner)
+}
+
+func (l *Lease) renew() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := models.RenewLease(l.name, l.owner, l.ttl); err != nil {
+				log.WithError(err).Errorf("lease %s lost by %s", l.name, l.owner)
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/robfig/cron"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Handler processes a single job, the context is cancelled when the runner is draining
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Options configures a Runner
+type Options struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	StaleAfter   time.Duration
+	LeaseTTL     time.Duration
+}
+
+// DefaultOptions are used for any zero value in Options
+var DefaultOptions = Options{
+	Workers:      4,
+	PollInterval: 2 * time.Second,
+	MaxAttempts:  5,
+	BaseBackoff:  30 * time.Second,
+	MaxBackoff:   time.Hour,
+	StaleAfter:   30 * time.Minute,
+	LeaseTTL:     2 * time.Minute,
+}
+
+// scheduleLeaseTTL keeps other instances from enqueueing the same cron tick,
+// it must be shorter than the most frequent schedule
+const scheduleLeaseTTL = 5 * time.Minute
+
+// errSkipped marks a run that was skipped because another instance holds the job lease
+var errSkipped = errors.New("job lease held by another instance")
+
+// errLockLost marks a run that was cancelled because another worker reclaimed the job
+var errLockLost = errors.New("job reclaimed by another worker")
+
+// Runner polls the MongoDB job queue and executes jobs on a pool of workers
+type Runner struct {
+	opts      Options
+	worker    string
+	mu        sync.RWMutex
+	handlers  map[string]Handler
+	exclusive map[string]bool
+	cron      *cron.Cron
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New instantiates a Runner with the given options
+func New(opts Options) *Runner {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultOptions.Workers
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultOptions.PollInterval
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultOptions.MaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultOptions.BaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultOptions.MaxBackoff
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = DefaultOptions.StaleAfter
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = DefaultOptions.LeaseTTL
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		opts:      opts,
+		worker:    WorkerID(),
+		handlers:  make(map[string]Handler),
+		exclusive: make(map[string]bool),
+		cron:      cron.New(),
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+	}
+}
+
+// WorkerID identifies this process to other instances sharing the queue
+func WorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Worker returns the ID this runner claims jobs with
+func (r *Runner) Worker() string {
+	return r.worker
+}
+
+// Register adds a handler for jobs with the given name
+func (r *Runner) Register(name string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = h
+}
+
+// Enqueue adds a job to the queue to run as soon as a worker is free
+func (r *Runner) Enqueue(name string, payload bson.M) (*models.Job, error) {
+	return r.EnqueueAt(name, payload, time.Now())
+}
+
+// EnqueueAt adds a job to the queue to run no earlier than runAt
+func (r *Runner) EnqueueAt(name string, payload bson.M, runAt time.Time) (*models.Job, error) {
+	if _, ok := r.handler(name); !ok {
+		return nil, fmt.Errorf("no handler registered for job %s", name)
+	}
+	return models.EnqueueJob(models.Job{
+		Name:        name,
+		Payload:     payload,
+		MaxAttempts: r.opts.MaxAttempts,
+		RunAt:       runAt,
+	})
+}
+
+// Schedule enqueues the named job on a cron spec.
+// Only one instance enqueues each tick and scheduled jobs never run concurrently across instances.
+func (r *Runner) Schedule(spec, name string) error {
+	r.mu.Lock()
+	r.exclusive[name] = true
+	r.mu.Unlock()
+
+	return r.cron.AddFunc(spec, func() {
+		_, ok, err := models.AcquireLease("schedule:"+name, r.worker, scheduleLeaseTTL)
+		if err != nil {
+			log.WithError(err).Errorf("unable to acquire schedule lease for job %s", name)
+			return
+		}
+		if !ok {
+			log.Infof("job %s already scheduled by another instance", name)
+			return
+		}
+		log.Infof("scheduling job %s", name)
+		if _, err := r.Enqueue(name, nil); err != nil {
+			log.WithError(err).Errorf("unable to schedule job %s", name)
+		}
+	})
+}
+
+// Start begins the scheduler and the worker pool
+func (r *Runner) Start() {
+	r.cron.Start()
+	for i := 0; i < r.opts.Workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	log.Infof("job runner %s started with %d workers", r.worker, r.opts.Workers)
+}
+
+// Stop stops claiming new jobs and waits for in-flight jobs to finish.
+// When ctx expires first the remaining jobs are cancelled and Stop returns without waiting for them.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.cron.Stop()
+	close(r.stop)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.cancel()
+		log.Infof("job runner %s drained", r.worker)
+		return nil
+	case <-ctx.Done():
+		// in-flight jobs are cancelled and released as they return, stale locks cover the rest
+		r.cancel()
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) handler(name string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[name]
+	return h, ok
+}
+
+func (r *Runner) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (r *Runner) work() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		// keep working while the queue has runnable jobs
+		for {
+			select {
+			case <-r.stop:
+				return
+			default:
+			}
+			job, err := models.ClaimJob(r.worker, r.names(), r.opts.StaleAfter)
+			if err == mgo.ErrNotFound {
+				break
+			}
+			if err != nil {
+				log.WithError(err).Error("unable to claim job")
+				break
+			}
+			r.run(job)
+		}
+	}
+}
+
+func (r *Runner) run(job *models.Job) {
+	run := models.JobRun{
+		JobID:     job.ID,
+		Name:      job.Name,
+		Attempt:   job.Attempts,
+		Worker:    r.worker,
+		StartedAt: time.Now(),
+	}
+
+	err := r.execute(job)
+
+	run.FinishedAt = time.Now()
+	run.Duration = int64(run.FinishedAt.Sub(run.StartedAt) / time.Millisecond)
+	switch {
+	case err == nil:
+		run.Status = models.JobSucceeded
+		job.CompleteJob()
+	case err == errLockLost:
+		// the job belongs to the worker that reclaimed it now
+		run.Status = models.JobFailed
+		run.Error = err.Error()
+		log.Warnf("job %s %v was reclaimed by another worker", job.Name, job.ID)
+	case err == errSkipped:
+		run.Status = models.JobSkipped
+		log.Infof("job %s %v skipped, another instance is running it", job.Name, job.ID)
+		job.CompleteJob()
+	case errors.Is(err, context.Canceled) && r.ctx.Err() != nil:
+		// runner is shutting down, let another worker pick it up
+		run.Status = models.JobQueued
+		run.Error = err.Error()
+		job.ReleaseJob()
+	case job.Attempts >= job.MaxAttempts:
+		run.Status = models.JobDead
+		run.Error = err.Error()
+		log.WithError(err).Errorf("job %s %v exhausted %d attempts", job.Name, job.ID, job.Attempts)
+		job.DeadLetterJob(err.Error())
+	default:
+		run.Status = models.JobFailed
+		run.Error = err.Error()
+		wait := Backoff(job.Attempts, r.opts.BaseBackoff, r.opts.MaxBackoff)
+		log.WithError(err).Warnf("job %s %v failed, retrying in %v", job.Name, job.ID, wait)
+		job.RetryJob(time.Now().Add(wait), err.Error())
+	}
+	models.SaveJobRun(run)
+}
+
+// execute calls the job handler, recovering from panics so one bad job cannot stop a worker.
+// Exclusive jobs hold a lease for the whole run and are cancelled if it is lost.
+func (r *Runner) execute(job *models.Job) (err error) {
+	h, ok := r.handler(job.Name)
+	if !ok {
+		return fmt.Errorf("no handler registered for job %s", job.Name)
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	lost := make(chan struct{})
+	go r.heartbeat(ctx, job, func() {
+		close(lost)
+		cancel()
+	})
+	if r.isExclusive(job.Name) {
+		lease, err := AcquireLease("job:"+job.Name, r.leaseOwner(job), r.opts.LeaseTTL)
+		if err == ErrLeaseHeld {
+			return errSkipped
+		}
+		if err != nil {
+			return err
+		}
+		defer lease.Release()
+		go func() {
+			select {
+			case <-lease.Lost():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.Name, p)
+		}
+	}()
+	log.WithField("JOB ID", job.ID).Infof("running job %s attempt %d", job.Name, job.Attempts)
+	err = h(ctx, job)
+	select {
+	case <-lost:
+		return errLockLost
+	default:
+	}
+	return err
+}
+
+// heartbeat refreshes the lock of a running job every third of StaleAfter until ctx is done,
+// a job running longer than StaleAfter would otherwise be claimed again by another worker.
+// onLost is called when the job was reclaimed anyway.
+func (r *Runner) heartbeat(ctx context.Context, job *models.Job, onLost func()) {
+	ticker := time.NewTicker(r.opts.StaleAfter / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := job.TouchJob()
+			if err == mgo.ErrNotFound {
+				onLost()
+				return
+			}
+			if err != nil {
+				log.WithError(err).Warnf("unable to refresh lock of job %s %v", job.Name, job.ID)
+			}
+		}
+	}
+}
+
+// leaseOwner identifies a single run of a job, workers of one process share the worker ID
+// and would otherwise re-acquire and release each other's leases
+func (r *Runner) leaseOwner(job *models.Job) string {
+	return r.worker + "/" + job.ID.Hex()
+}
+
+func (r *Runner) isExclusive(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.exclusive[name]
+}
+
+// Backoff returns the exponential delay before the given attempt is retried
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	wait := base
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= max {
+			return max
+		}
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
+
+// PayloadInt64 reads an integer value from a job payload
+func PayloadInt64(job *models.Job, key string) (int64, error) {
+	switch v := job.Payload[key].(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("job %s payload is missing %s", job.Name, key)
+}
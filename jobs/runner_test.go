@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 30 * time.Second
+	max := 10 * time.Minute
+	cases := map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		5:  8 * time.Minute,
+		6:  max,
+		20: max,
+	}
+	for attempt, exp := range cases {
+		if got := Backoff(attempt, base, max); got != exp {
+			t.Errorf("attempt %d: expected backoff %v, got %v", attempt, exp, got)
+		}
+	}
+}
+
+func TestPayloadInt64(t *testing.T) {
+	job := &models.Job{Name: "test", Payload: bson.M{"a": int64(1), "b": 2, "c": 3.0}}
+	for key, exp := range map[string]int64{"a": 1, "b": 2, "c": 3} {
+		got, err := PayloadInt64(job, key)
+		if err != nil || got != exp {
+			t.Errorf("expected %s to be %d, got %d: %v", key, exp, got, err)
+		}
+	}
+	if _, err := PayloadInt64(job, "missing"); err == nil {
+		t.Error("expected error for missing payload key")
+	}
+}
+
+func TestEnqueueUnregisteredJob(t *testing.T) {
+	r := New(Options{})
+	if _, err := r.Enqueue("unregistered", nil); err == nil {
+		t.Error("expected error enqueueing a job without a handler")
+	}
+}
+
+func TestLeaseOwnerPerRun(t *testing.T) {
+	r := New(Options{})
+	a := &models.Job{ID: bson.NewObjectId(), Name: "test"}
+	b := &models.Job{ID: bson.NewObjectId(), Name: "test"}
+	if r.leaseOwner(a) == r.leaseOwner(b) {
+		t.Errorf("expected runs of the same job in one process to own different leases, got %s", r.leaseOwner(a))
+	}
+	if r.leaseOwner(a) != r.leaseOwner(a) {
+		t.Error("expected a run to keep its lease owner")
+	}
+}
+
+func TestStopDoesNotWaitPastDeadline(t *testing.T) {
+	r := New(Options{})
+	// a job that never returns
+	r.wg.Add(1)
+	defer r.wg.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- r.Stop(ctx) }()
+
+	select {
+	case err := <-stopped:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for the job after its context expired")
+	}
+	if r.ctx.Err() == nil {
+		t.Error("expected in-flight jobs to be cancelled")
+	}
+}
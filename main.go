@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,34 +10,69 @@ import (
 	"time"
 
 	"github.com/jrzimmerman/bestrida-server-go/handlers"
+	"github.com/jrzimmerman/bestrida-server-go/jobs"
 	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/realtime"
 	"github.com/jrzimmerman/bestrida-server-go/utils"
-	"github.com/robfig/cron"
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
 	port := utils.GetEnvString("PORT")
+	grpcPort := utils.GetEnvStringDefault("GRPC_PORT", "9090")
 	mux := handlers.API()
 	// close DB connection
 	defer models.Close()
-	c := cron.New()
-	c.AddFunc("0 0 * * * *", func() {
-		log.Print("Starting cron complete")
-		handlers.CronComplete()
-		log.Print("Ending cron complete")
-	})
-	c.Start()
-	defer c.Stop()
+
+	// search needs the text indexes, it fails until they exist
+	if err := models.EnsureSearchIndexes(); err != nil {
+		log.WithError(err).Error("Unable to create search indexes")
+	}
+
+	// background jobs are queued in MongoDB and run by a pool of workers
+	runner := jobs.New(jobs.Options{Workers: utils.GetEnvInt("JOB_WORKERS", 4)})
+	handlers.RegisterJobs(runner)
+	if err := runner.Schedule("0 0 * * * *", handlers.CompleteChallengesJob); err != nil {
+		log.WithError(err).Fatal("Unable to schedule challenge completion")
+	}
+	if err := runner.Schedule("0 0 4 * * *", handlers.SyncAllUsersJob); err != nil {
+		log.WithError(err).Fatal("Unable to schedule user sync")
+	}
+	if err := runner.Schedule("0 30 2 * * *", handlers.SegmentSweepJob); err != nil {
+		log.WithError(err).Fatal("Unable to schedule segment sweep")
+	}
+	if err := runner.Schedule("0 */15 * * * *", handlers.RemindChallengesJob); err != nil {
+		log.WithError(err).Fatal("Unable to schedule expiration reminders")
+	}
+	if err := runner.Schedule("0 0 15 * * 1", handlers.EmailDigestJob); err != nil {
+		log.WithError(err).Fatal("Unable to schedule weekly email digest")
+	}
+	runner.Start()
+
+	// realtime events are pushed to clients connected to any instance
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	hub := realtime.NewHub(realtime.BackendFromEnv())
+	hub.Start(hubCtx)
+	handlers.RegisterHub(hub)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
 	}
+	// close event streams on shutdown, they would otherwise hold it open
+	srv.RegisterOnShutdown(stopHub)
+
+	// internal consumers call the gRPC service on its own port
+	rpcSrv := handlers.RPCServer()
+	lis, err := net.Listen("tcp", handlers.RPCAddr(grpcPort))
+	if err != nil {
+		log.WithError(err).Fatalf("Unable to listen on gRPC port %s", grpcPort)
+	}
 
-	// We want to report the listener is closed.
+	// We want to report the listeners are closed.
 	var wg sync.WaitGroup
-	wg.Add(1)
+	wg.Add(2)
 
 	go func() {
 		log.Infof("Listening on port %s", port)
@@ -45,6 +81,13 @@ func main() {
 		wg.Done()
 	}()
 
+	go func() {
+		log.Infof("Serving gRPC on port %s", grpcPort)
+
+		rpcSrv.Serve(lis)
+		wg.Done()
+	}()
+
 	// Listen for an interrupt signal from the OS. Use a buffered
 	// channel because of how the signal package is implemented.
 	osSignals := make(chan os.Signal, 1)
@@ -71,7 +114,26 @@ func main() {
 		}
 	}
 
-	// Wait for the listener to report it is closed.
+	// Streams end when the hub stops, stop the gRPC server hard if calls are still running.
+	stopped := make(chan struct{})
+	go func() {
+		rpcSrv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		rpcSrv.Stop()
+	}
+
+	// Wait for the listeners to report they are closed.
 	wg.Wait()
+
+	// Let in-flight jobs finish on their own deadline, the servers may have used up ctx.
+	runnerCtx, cancelRunner := context.WithTimeout(context.Background(), timeout)
+	defer cancelRunner()
+	if err := runner.Stop(runnerCtx); err != nil {
+		log.WithError(err).Errorf("shutdown : Job runner did not drain in %v", timeout)
+	}
 	log.Println("Server gracefully stopped")
 }
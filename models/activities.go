@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ProcessedActivity struct handles the MongoDB schema for an activity whose segment efforts were already read
+type ProcessedActivity struct {
+	ID          int64     `bson:"_id" json:"id"`
+	UserID      int64     `bson:"userId" json:"userId"`
+	StartDate   time.Time `bson:"startDate" json:"startDate"`
+	ProcessedAt time.Time `bson:"processedAt" json:"processedAt"`
+}
+
+// GetProcessedActivityIDs returns which of the given activity IDs were already processed
+func GetProcessedActivityIDs(ids []int64) (map[int64]bool, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var activities []ProcessedActivity
+	if err := s.DB(name).C("activities").Find(bson.M{"_id": bson.M{"$in": ids}}).Select(bson.M{"_id": 1}).All(&activities); err != nil {
+		log.WithError(err).Error("Unable to find processed activities")
+		return nil, err
+	}
+
+	processed := make(map[int64]bool, len(activities))
+	for _, a := range activities {
+		processed[a.ID] = true
+	}
+	return processed, nil
+}
+
+// SaveProcessedActivities records activities so they are never fetched again
+func SaveProcessedActivities(activities []ProcessedActivity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+	s := session.Copy()
+	defer s.Close()
+
+	bulk := s.DB(name).C("activities").Bulk()
+	bulk.Unordered()
+	for _, a := range activities {
+		bulk.Upsert(bson.M{"_id": a.ID}, a)
+	}
+	if _, err := bulk.Run(); err != nil {
+		log.WithError(err).Errorf("Unable to save %d processed activities", len(activities))
+		return err
+	}
+	log.Infof("stored %d processed activities", len(activities))
+	return nil
+}
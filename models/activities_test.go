@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveProcessedActivitiesSuccess(t *testing.T) {
+	activities := []ProcessedActivity{
+		{ID: -1, UserID: -1, StartDate: time.Now(), ProcessedAt: time.Now()},
+		{ID: -2, UserID: -1, StartDate: time.Now(), ProcessedAt: time.Now()},
+	}
+	if err := SaveProcessedActivities(activities); err != nil {
+		t.Fatalf("Unable to save processed activities:\n %v", err)
+	}
+
+	processed, err := GetProcessedActivityIDs([]int64{-1, -2, -3})
+	if err != nil {
+		t.Fatalf("Unable to get processed activities:\n %v", err)
+	}
+	if !processed[-1] || !processed[-2] || processed[-3] {
+		t.Errorf("Unexpected processed activities %v", processed)
+	}
+}
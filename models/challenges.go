@@ -1,9 +1,12 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -38,6 +41,44 @@ type Challenge struct {
 	CreatedAt  time.Time     `bson:"createdAt" json:"createdAt"`
 	UpdatedAt  time.Time     `bson:"updatedAt" json:"updatedAt"`
 	DeletedAt  *time.Time    `bson:"deletedAt" json:"deletedAt,omitempty"`
+	TimeZone   string        `bson:"timeZone,omitempty" json:"timeZone,omitempty"`
+}
+
+// Location returns the time zone the challenge window was computed in
+func (c Challenge) Location() *time.Location {
+	if c.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// MarshalJSON returns the challenge window in UTC along with its local boundaries in the challengers time zone
+func (c Challenge) MarshalJSON() ([]byte, error) {
+	type challenge Challenge
+	out := struct {
+		challenge
+		Created      *time.Time `json:"created,omitempty"`
+		Expires      *time.Time `json:"expires,omitempty"`
+		CreatedLocal string     `json:"createdLocal,omitempty"`
+		ExpiresLocal string     `json:"expiresLocal,omitempty"`
+	}{challenge: challenge(c)}
+
+	loc := c.Location()
+	if c.Created != nil {
+		created := c.Created.UTC()
+		out.Created = &created
+		out.CreatedLocal = c.Created.In(loc).Format(time.RFC3339)
+	}
+	if c.Expires != nil {
+		expires := c.Expires.UTC()
+		out.Expires = &expires
+		out.ExpiresLocal = c.Expires.In(loc).Format(time.RFC3339)
+	}
+	return json.Marshal(out)
 }
 
 // GetChallengeByID gets a single stored challenge from database
@@ -49,7 +90,7 @@ func GetChallengeByID(id bson.ObjectId) (*Challenge, error) {
 
 	if err := s.DB(name).C("challenges").Find(bson.M{"_id": id}).One(&c); err != nil {
 		log.WithField("ID", id).Error("Unable to find challenge with id in database")
-		return nil, err
+		return nil, dbError(err, "challenge", id.Hex())
 	}
 
 	return &c, nil
@@ -62,7 +103,7 @@ func CreateChallenge(c Challenge) error {
 
 	if err := s.DB(name).C("challenges").Insert(c); err != nil {
 		log.WithField("CHALLENGE ID", c.ID).Errorf("Unable to create a new challenge:\n %v", err)
-		return err
+		return dbError(err, "challenge", c.ID.Hex())
 	}
 	log.WithField("CHALLENGE ID", c.ID).Infof("Challenge %v successfully created", c.ID)
 	return nil
@@ -75,7 +116,7 @@ func RemoveChallenge(id bson.ObjectId) error {
 
 	if err := s.DB(name).C("challenges").RemoveId(id); err != nil {
 		log.WithField("CHALLENGE ID", id).Error("Unable to find challenge with id in database")
-		return err
+		return dbError(err, "challenge", id.Hex())
 	}
 	log.Infof("Challenge successfully removed: %v", id)
 	return nil
@@ -88,7 +129,7 @@ func (c Challenge) UpdateChallenge() error {
 
 	if err := s.DB(name).C("challenges").UpdateId(c.ID, c); err != nil {
 		log.WithField("CHALLENGE ID", c.ID).Errorf("Unable to update challenge %v in database", c.ID)
-		return err
+		return dbError(err, "challenge", c.ID.Hex())
 	}
 	log.Infof("Challenge successfully updated: %v", c.ID)
 	return nil
@@ -101,12 +142,56 @@ func UpdateChallengeStatus(id bson.ObjectId, status string, updateTime time.Time
 
 	if err := s.DB(name).C("challenges").Update(bson.M{"_id": id}, bson.M{"$set": bson.M{"status": status, "updatedAt": updateTime}}); err != nil {
 		log.WithField("ID", id).Errorf("Unable to update challenge with id: %v in database", id)
-		return err
+		return dbError(err, "challenge", id.Hex())
 	}
 	log.Infof("Challenge successfully updated: %v", id)
 	return nil
 }
 
+// UpdatePendingChallengeStatus moves a pending challenge to status and returns the updated challenge.
+// Challenges that are no longer pending are left alone and a conflict error is returned.
+func UpdatePendingChallengeStatus(id bson.ObjectId, status string, updateTime time.Time) (*Challenge, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var c Challenge
+	change := mgo.Change{Update: bson.M{"$set": bson.M{"status": status, "updatedAt": updateTime}}, ReturnNew: true}
+	if _, err := s.DB(name).C("challenges").Find(bson.M{"_id": id, "status": "pending"}).Apply(change, &c); err != nil {
+		log.WithField("ID", id).Errorf("Unable to update pending challenge with id: %v in database", id)
+		return nil, pendingError(s, err, id)
+	}
+	log.Infof("Challenge successfully updated: %v", id)
+	return &c, nil
+}
+
+// RemovePendingChallenge removes a pending challenge and returns it as it was stored.
+// Challenges that are no longer pending are left alone and a conflict error is returned.
+func RemovePendingChallenge(id bson.ObjectId) (*Challenge, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var c Challenge
+	if _, err := s.DB(name).C("challenges").Find(bson.M{"_id": id, "status": "pending"}).Apply(mgo.Change{Remove: true}, &c); err != nil {
+		log.WithField("CHALLENGE ID", id).Error("Unable to remove pending challenge with id in database")
+		return nil, pendingError(s, err, id)
+	}
+	log.Infof("Challenge successfully removed: %v", id)
+	return &c, nil
+}
+
+// pendingError types the error of a write to a pending challenge, telling a challenge
+// that does not exist apart from one that has left the pending status
+func pendingError(s *mgo.Session, err error, id bson.ObjectId) error {
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	var c Challenge
+	if findErr := s.DB(name).C("challenges").FindId(id).Select(bson.M{"status": 1}).One(&c); findErr != nil {
+		return dbError(findErr, "challenge", id.Hex())
+	}
+	return ConflictError(fmt.Sprintf("challenge %s is %s, not pending", id.Hex(), c.Status), err)
+}
+
 // GetAllChallenges get all challenges for a user from database
 func GetAllChallenges(userID int64) (*[]Challenge, error) {
 	s := session.Copy()
@@ -170,6 +255,27 @@ func GetActiveChallenges(userID int64) (*[]Challenge, error) {
 	return &challenges, nil
 }
 
+// GetOpenChallengesBetween get pending and active challenges between two users from database
+func GetOpenChallengesBetween(userID, opponentID int64) ([]Challenge, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var challenges []Challenge
+	err := s.DB(name).C("challenges").Find(bson.M{
+		"status":  bson.M{"$in": []string{"pending", "active"}},
+		"expired": bson.M{"$ne": true},
+		"$or": []bson.M{
+			bson.M{"challenger.id": userID, "challengee.id": opponentID},
+			bson.M{"challenger.id": opponentID, "challengee.id": userID},
+		},
+	}).All(&challenges)
+	if err != nil {
+		log.WithField("ID", userID).Errorf("Unable to find open challenges between users %d and %d in database", userID, opponentID)
+		return nil, err
+	}
+	return challenges, nil
+}
+
 // GetCompletedChallenges get completed challenges by user ID from database
 func GetCompletedChallenges(userID int64) (*[]Challenge, error) {
 	s := session.Copy()
@@ -191,6 +297,140 @@ func GetCompletedChallenges(userID int64) (*[]Challenge, error) {
 	return &challenges, nil
 }
 
+// ChallengeSorts maps the sort options for a users challenge list to their fields
+var ChallengeSorts = map[string]string{
+	"expires": "expires",
+	"created": "created",
+	"updated": "updatedAt",
+}
+
+// ChallengeCursor marks the last challenge of a page, the next page starts after it
+type ChallengeCursor struct {
+	Value time.Time     `json:"v"`
+	ID    bson.ObjectId `json:"id"`
+}
+
+// ChallengeQuery filters, sorts and pages the challenges of a user
+type ChallengeQuery struct {
+	UserID       int64
+	Status       string
+	OpponentID   int64
+	SegmentID    int64
+	ActivityType string
+	From         *time.Time
+	To           *time.Time
+	Sort         string
+	Desc         bool
+	After        *ChallengeCursor
+	Limit        int
+}
+
+// side returns the filter for the user being on one side of the challenge and their opponent on the other
+func (q ChallengeQuery) side(user, opponent string) bson.M {
+	filter := bson.M{user + ".id": q.UserID}
+	switch q.Status {
+	case "pending":
+		filter["status"] = "pending"
+	case "active":
+		filter["status"] = "active"
+		filter[user+".completed"] = false
+	case "completed":
+		filter[user+".completed"] = true
+	}
+	if q.OpponentID != 0 {
+		filter[opponent+".id"] = q.OpponentID
+	}
+	return filter
+}
+
+// filter returns the query for every challenge matching q, ignoring the cursor
+func (q ChallengeQuery) filter() bson.M {
+	filter := bson.M{
+		"$or": []bson.M{
+			q.side("challenger", "challengee"),
+			q.side("challengee", "challenger"),
+		},
+	}
+	if q.SegmentID != 0 {
+		filter["segment._id"] = q.SegmentID
+	}
+	if q.ActivityType != "" {
+		filter["segment.activityType"] = q.ActivityType
+	}
+	created := bson.M{}
+	if q.From != nil {
+		created["$gte"] = *q.From
+	}
+	if q.To != nil {
+		created["$lt"] = *q.To
+	}
+	if len(created) > 0 {
+		filter["created"] = created
+	}
+	return filter
+}
+
+// field returns the document field the challenges are sorted by
+func (q ChallengeQuery) field() string {
+	if field, ok := ChallengeSorts[q.Sort]; ok {
+		return field
+	}
+	return ChallengeSorts["expires"]
+}
+
+// Cursor returns the cursor for the page starting after c
+func (q ChallengeQuery) Cursor(c Challenge) ChallengeCursor {
+	var value time.Time
+	switch q.field() {
+	case "created":
+		if c.Created != nil {
+			value = *c.Created
+		}
+	case "updatedAt":
+		value = c.UpdatedAt
+	default:
+		if c.Expires != nil {
+			value = *c.Expires
+		}
+	}
+	return ChallengeCursor{Value: value, ID: c.ID}
+}
+
+// GetChallenges get a page of challenges matching the query and the total number of matches from database
+func GetChallenges(q ChallengeQuery) ([]Challenge, int, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	c := s.DB(name).C("challenges")
+	filter := q.filter()
+	total, err := c.Find(filter).Count()
+	if err != nil {
+		log.WithField("ID", q.UserID).Errorf("Unable to count challenges:\n %v", err)
+		return nil, 0, err
+	}
+
+	field, sort, op := q.field(), []string{q.field(), "_id"}, "$gt"
+	if q.Desc {
+		sort, op = []string{"-" + field, "-_id"}, "$lt"
+	}
+	if q.After != nil {
+		filter = bson.M{"$and": []bson.M{filter, {
+			"$or": []bson.M{
+				{field: bson.M{op: q.After.Value}},
+				{field: q.After.Value, "_id": bson.M{op: q.After.ID}},
+			},
+		}}}
+	}
+	var challenges []Challenge
+	if err := c.Find(filter).Sort(sort...).Limit(q.Limit).All(&challenges); err != nil {
+		log.WithField("ID", q.UserID).Errorf("Unable to find challenges:\n %v", err)
+		return nil, 0, err
+	}
+	log.Infof("found %d of %d challenges for user %v", len(challenges), total, q.UserID)
+
+	return challenges, total, nil
+}
+
 // GetExpiredChallenges get expired challenges from database
 func GetExpiredChallenges() (*[]Challenge, error) {
 	s := session.Copy()
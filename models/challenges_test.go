@@ -1,7 +1,9 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"gopkg.in/mgo.v2/bson"
 )
@@ -41,6 +43,29 @@ func TestCreateChallengeSuccess(t *testing.T) {
 	defer RemoveChallenge(id)
 }
 
+func TestUpdatePendingChallengeStatus(t *testing.T) {
+	id := bson.NewObjectId()
+	if err := CreateChallenge(Challenge{ID: id, Status: "pending"}); err != nil {
+		t.Fatalf("Error creating a new test challenge:\n %v", err)
+	}
+	defer RemoveChallenge(id)
+
+	c, err := UpdatePendingChallengeStatus(id, "active", time.Now())
+	if err != nil || c.Status != "active" {
+		t.Fatalf("Unable to accept pending challenge: %v", err)
+	}
+	// replaying the accept or declining afterwards leaves the active challenge alone
+	if _, err := UpdatePendingChallengeStatus(id, "active", time.Now()); ErrorKind(err) != KindConflict {
+		t.Errorf("Expected a conflict accepting an active challenge, got: %v", err)
+	}
+	if _, err := RemovePendingChallenge(id); ErrorKind(err) != KindConflict {
+		t.Errorf("Expected a conflict declining an active challenge, got: %v", err)
+	}
+	if _, err := RemovePendingChallenge(bson.NewObjectId()); !IsNotFound(err) {
+		t.Errorf("Expected not found declining a missing challenge, got: %v", err)
+	}
+}
+
 func TestCreateChallengeFailure(t *testing.T) {
 	c := Challenge{
 		ID: "fred",
@@ -101,3 +126,43 @@ func TestCreateChallengeFailure(t *testing.T) {
 // 		t.Errorf("No challenges found for user %d", id)
 // 	}
 // }
+
+func TestChallengeMarshalJSONLocalTimes(t *testing.T) {
+	expires := time.Date(2018, 6, 5, 6, 59, 59, 0, time.UTC)
+	c := Challenge{ID: bson.NewObjectId(), Expires: &expires, TimeZone: "America/Los_Angeles"}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Unable to marshal challenge:\n %v", err)
+	}
+	var out map[string]interface{}
+	json.Unmarshal(b, &out)
+	if out["expires"] != "2018-06-05T06:59:59Z" {
+		t.Errorf("Expected UTC expires, got %v", out["expires"])
+	}
+	if out["expiresLocal"] != "2018-06-04T23:59:59-07:00" {
+		t.Errorf("Expected local expires, got %v", out["expiresLocal"])
+	}
+}
+
+func TestChallengeQueryFilter(t *testing.T) {
+	from := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	q := ChallengeQuery{UserID: 1, Status: "completed", OpponentID: 2, ActivityType: "Ride", From: &from}
+	filter := q.filter()
+
+	sides := filter["$or"].([]bson.M)
+	if sides[0]["challenger.id"] != int64(1) || sides[0]["challengee.id"] != int64(2) || sides[0]["challenger.completed"] != true {
+		t.Errorf("unexpected challenger filter %v", sides[0])
+	}
+	if sides[1]["challengee.id"] != int64(1) || sides[1]["challenger.id"] != int64(2) || sides[1]["challengee.completed"] != true {
+		t.Errorf("unexpected challengee filter %v", sides[1])
+	}
+	if filter["segment.activityType"] != "Ride" || filter["created"].(bson.M)["$gte"] != from {
+		t.Errorf("unexpected filter %v", filter)
+	}
+	if _, ok := filter["segment._id"]; ok {
+		t.Errorf("expected no segment filter %v", filter)
+	}
+	if q.field() != "expires" {
+		t.Errorf("expected default sort by expires, got %s", q.field())
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// device platforms that can receive push notifications
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// Device struct handles the MongoDB schema for a device registered for push notifications
+type Device struct {
+	Token     string    `bson:"_id" json:"token"`
+	UserID    int64     `bson:"userId" json:"userId"`
+	Platform  string    `bson:"platform" json:"platform"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// RegisterDevice stores a device token for a user.
+// A token registered by another user is not moved, a conflict error is returned instead.
+func RegisterDevice(userID int64, token, platform string) (*Device, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	// the upsert collides with the existing _id when another user owns the token
+	if _, err := s.DB(name).C("devices").Upsert(bson.M{"_id": token, "userId": userID}, bson.M{
+		"$set":         bson.M{"platform": platform, "updatedAt": now},
+		"$setOnInsert": bson.M{"createdAt": now},
+	}); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to register device:\n %v", err)
+		if mgo.IsDup(err) {
+			return nil, ConflictError("device is registered to another user", err)
+		}
+		return nil, err
+	}
+
+	var d Device
+	if err := s.DB(name).C("devices").FindId(token).One(&d); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to find registered device:\n %v", err)
+		return nil, err
+	}
+	log.WithField("USER ID", userID).Infof("%s device registered for user %d", platform, userID)
+	return &d, nil
+}
+
+// RemoveDevice deletes a device token for a user
+func RemoveDevice(userID int64, token string) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("devices").Remove(bson.M{"_id": token, "userId": userID}); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to remove device:\n %v", err)
+		return dbError(err, "device", token)
+	}
+	return nil
+}
+
+// GetDevicesByUserID returns every device registered by a user
+func GetDevicesByUserID(userID int64) ([]Device, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var devices []Device
+	if err := s.DB(name).C("devices").Find(bson.M{"userId": userID}).All(&devices); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to find devices:\n %v", err)
+		return nil, err
+	}
+	return devices, nil
+}
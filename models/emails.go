@@ -0,0 +1,139 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// EmailPreferences struct handles the MongoDB schema for the emails a user opted in to
+type EmailPreferences struct {
+	UserID           int64      `bson:"_id" json:"userId"`
+	Notifications    bool       `bson:"notifications" json:"notifications"`
+	Digest           bool       `bson:"digest" json:"digest"`
+	UnsubscribeToken string     `bson:"unsubscribeToken" json:"-"`
+	LastDigestAt     *time.Time `bson:"lastDigestAt,omitempty" json:"lastDigestAt,omitempty"`
+	UpdatedAt        time.Time  `bson:"updatedAt" json:"updatedAt"`
+}
+
+// GetEmailPreferences gets the email preferences for a user, users are opted out until they save preferences
+func GetEmailPreferences(userID int64) (*EmailPreferences, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var p EmailPreferences
+	if err := s.DB(name).C("emailPreferences").FindId(userID).One(&p); err != nil {
+		if err == mgo.ErrNotFound {
+			return &EmailPreferences{UserID: userID}, nil
+		}
+		log.WithField("USER ID", userID).Errorf("Unable to find email preferences:\n %v", err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveEmailPreferences stores which emails a user receives, generating their unsubscribe token on first save
+func SaveEmailPreferences(userID int64, notifications, digest bool) (*EmailPreferences, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	token, err := newUnsubscribeToken()
+	if err != nil {
+		return nil, err
+	}
+	var p EmailPreferences
+	if _, err := s.DB(name).C("emailPreferences").FindId(userID).Apply(mgo.Change{
+		Update: bson.M{
+			"$set":         bson.M{"notifications": notifications, "digest": digest, "updatedAt": time.Now()},
+			"$setOnInsert": bson.M{"unsubscribeToken": token},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &p); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to save email preferences:\n %v", err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UnsubscribeEmail opts the user owning an unsubscribe token out of all emails
+func UnsubscribeEmail(token string) (*EmailPreferences, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var p EmailPreferences
+	if _, err := s.DB(name).C("emailPreferences").Find(bson.M{"unsubscribeToken": token}).Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"notifications": false, "digest": false, "updatedAt": time.Now()}},
+		ReturnNew: true,
+	}, &p); err != nil {
+		if err != mgo.ErrNotFound {
+			log.Errorf("Unable to unsubscribe email:\n %v", err)
+		}
+		return nil, dbError(err, "unsubscribe token", token)
+	}
+	log.WithField("USER ID", p.UserID).Infof("user %d unsubscribed from emails", p.UserID)
+	return &p, nil
+}
+
+// GetDigestSubscribers returns users opted in to the weekly digest who have not received one since a time
+func GetDigestSubscribers(sentBefore time.Time) ([]EmailPreferences, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var prefs []EmailPreferences
+	if err := s.DB(name).C("emailPreferences").Find(bson.M{
+		"digest": true,
+		"$or": []bson.M{
+			bson.M{"lastDigestAt": bson.M{"$exists": false}},
+			bson.M{"lastDigestAt": bson.M{"$lt": sentBefore}},
+		},
+	}).All(&prefs); err != nil {
+		log.WithError(err).Error("Unable to return digest subscribers")
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// MarkDigestSent records when a user last received the weekly digest
+func MarkDigestSent(userID int64, sentAt time.Time) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("emailPreferences").UpdateId(userID, bson.M{"$set": bson.M{"lastDigestAt": sentAt}}); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to mark digest sent:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetChallengesSettledSince returns challenges for a user that completed after a time
+func GetChallengesSettledSince(userID int64, since time.Time) ([]Challenge, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var challenges []Challenge
+	if err := s.DB(name).C("challenges").Find(bson.M{
+		"$or": []bson.M{
+			bson.M{"challengee.id": userID},
+			bson.M{"challenger.id": userID},
+		},
+		"status":    "complete",
+		"completed": bson.M{"$gte": since},
+	}).Sort("-completed").All(&challenges); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to find settled challenges:\n %v", err)
+		return nil, err
+	}
+	return challenges, nil
+}
+
+func newUnsubscribeToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/mgo.v2"
+)
+
+// error kinds handlers map to response status codes
+const (
+	KindInvalid      = "invalid_request"
+	KindValidation   = "validation_failed"
+	KindUnauthorized = "unauthorized"
+	KindForbidden    = "forbidden"
+	KindNotFound     = "not_found"
+	KindConflict     = "conflict"
+	KindUpstream     = "upstream_error"
+	KindUnavailable  = "service_unavailable"
+	KindInternal     = "internal_error"
+)
+
+// Error is an error with a kind clients can act on.
+// Err is the underlying cause and is never shown to clients.
+type Error struct {
+	Kind    string
+	Message string
+	Details interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause so errors.Is can match mgo errors
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// InvalidError is returned for requests that cannot be parsed
+func InvalidError(message string, details interface{}) *Error {
+	return &Error{Kind: KindInvalid, Message: message, Details: details}
+}
+
+// ValidationError is returned for well formed requests that break a rule
+func ValidationError(message string, details interface{}) *Error {
+	return &Error{Kind: KindValidation, Message: message, Details: details}
+}
+
+// UnauthorizedError is returned when a request does not carry the users token
+func UnauthorizedError(message string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+// ForbiddenError is returned when the user may not see or change a resource
+func ForbiddenError(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+// NotFoundError is returned when a resource does not exist
+func NotFoundError(resource string, id interface{}) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf("%s %v not found", resource, id), Err: mgo.ErrNotFound}
+}
+
+// ConflictError is returned when a write clashes with an existing document
+func ConflictError(message string, err error) *Error {
+	return &Error{Kind: KindConflict, Message: message, Err: err}
+}
+
+// UpstreamError is returned when a request to another service such as Strava fails
+func UpstreamError(service string, err error) *Error {
+	return &Error{Kind: KindUpstream, Message: fmt.Sprintf("request to %s failed", service), Err: err}
+}
+
+// InternalError wraps an unexpected failure with a message that is safe to show clients
+func InternalError(message string, err error) *Error {
+	return &Error{Kind: KindInternal, Message: message, Err: err}
+}
+
+// UnavailableError is returned when a dependency of the request is not running
+func UnavailableError(message string) *Error {
+	return &Error{Kind: KindUnavailable, Message: message}
+}
+
+// ErrorKind returns the kind of err, mgo not found and duplicate key errors are classified
+// and anything else is internal
+func ErrorKind(err error) string {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e.Kind
+	case errors.Is(err, mgo.ErrNotFound):
+		return KindNotFound
+	case mgo.IsDup(err):
+		return KindConflict
+	}
+	return KindInternal
+}
+
+// IsNotFound reports whether err means a document does not exist
+func IsNotFound(err error) bool {
+	return err != nil && ErrorKind(err) == KindNotFound
+}
+
+// dbError types errors from a lookup of a single document
+func dbError(err error, resource string, id interface{}) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == mgo.ErrNotFound:
+		return NotFoundError(resource, id)
+	case mgo.IsDup(err):
+		return ConflictError(fmt.Sprintf("%s %v already exists", resource, id), err)
+	}
+	return err
+}
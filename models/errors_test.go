@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopkg.in/mgo.v2"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{NotFoundError("user", 1), KindNotFound},
+		{dbError(mgo.ErrNotFound, "user", 1), KindNotFound},
+		{fmt.Errorf("load: %w", mgo.ErrNotFound), KindNotFound},
+		{dbError(&mgo.LastError{Code: 11000}, "challenge", "abc"), KindConflict},
+		{ValidationError("invalid", nil), KindValidation},
+		{UpstreamError("Strava", errors.New("server error")), KindUpstream},
+		{errors.New("connection reset"), KindInternal},
+	}
+	for _, tc := range tests {
+		if kind := ErrorKind(tc.err); kind != tc.kind {
+			t.Errorf("%v: expected %s, got %s", tc.err, tc.kind, kind)
+		}
+	}
+
+	if !errors.Is(NotFoundError("user", 1), mgo.ErrNotFound) {
+		t.Errorf("expected not found errors to match mgo.ErrNotFound")
+	}
+	if IsNotFound(nil) {
+		t.Errorf("expected nil not to be not found")
+	}
+	if err := dbError(nil, "user", 1); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
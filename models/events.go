@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+)
+
+// eventsCollectionSize caps the events collection, old events are overwritten as new ones arrive
+const eventsCollectionSize = 16 * 1024 * 1024
+
+// RealtimeEvent struct handles the MongoDB schema for an event shared between instances
+type RealtimeEvent struct {
+	ID        string    `bson:"_id"`
+	UserID    int64     `bson:"userId"`
+	Type      string    `bson:"type"`
+	Data      []byte    `bson:"data"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// EnsureEventsCollection creates the capped collection realtime events are tailed from
+func EnsureEventsCollection() error {
+	s := session.Copy()
+	defer s.Close()
+
+	err := s.DB(name).C("events").Create(&mgo.CollectionInfo{Capped: true, MaxBytes: eventsCollectionSize})
+	if err != nil {
+		if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == 48 {
+			// collection already exists
+			return nil
+		}
+		log.WithError(err).Error("Unable to create events collection")
+		return err
+	}
+	return nil
+}
+
+// InsertRealtimeEvent stores an event for every instance to deliver
+func InsertRealtimeEvent(e RealtimeEvent) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("events").Insert(&e); err != nil {
+		log.WithField("USER ID", e.UserID).Errorf("Unable to insert realtime event:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// TailEvents calls fn with each event inserted after the event with ID after until ctx is done,
+// with an empty after it starts from the events inserted after it was called.
+// It returns the ID of the last event delivered so a restart can resume from there.
+//
+// Events are read in the capped collection's natural order, which is insertion order. Their IDs
+// are not, IDs created by different instances in the same second may sort either way, so resuming
+// replays the collection from the start and skips up to the last event delivered.
+func TailEvents(ctx context.Context, after string, fn func(RealtimeEvent)) (string, error) {
+	s := session.Copy()
+	defer s.Close()
+	c := s.DB(name).C("events")
+
+	if after == "" {
+		var last RealtimeEvent
+		if err := c.Find(nil).Sort("-$natural").One(&last); err == nil {
+			after = last.ID
+		} else if err != mgo.ErrNotFound {
+			return after, err
+		}
+	}
+
+	// skipping is set while a new cursor replays events delivered before it was opened
+	var skipping bool
+	tail := func() (*mgo.Iter, error) {
+		skipping = after != ""
+		if skipping {
+			n, err := c.FindId(after).Count()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				// the collection wrapped past the last event delivered, everything left is newer
+				log.Warnf("realtime event %s was overwritten, events may have been missed", after)
+				skipping = false
+			}
+		}
+		return c.Find(nil).Sort("$natural").Tail(time.Second), nil
+	}
+	iter, err := tail()
+	if err != nil {
+		return after, err
+	}
+	defer func() { iter.Close() }()
+
+	var e RealtimeEvent
+	for {
+		for iter.Next(&e) {
+			if skipping {
+				skipping = e.ID != after
+				continue
+			}
+			after = e.ID
+			fn(e)
+		}
+		if err := ctx.Err(); err != nil {
+			return after, err
+		}
+		if iter.Timeout() {
+			continue
+		}
+		if err := iter.Err(); err != nil {
+			return after, err
+		}
+		// the cursor closed, usually because the collection was empty, so start a new one
+		iter.Close()
+		time.Sleep(time.Second)
+		if iter, err = tail(); err != nil {
+			return after, err
+		}
+	}
+}
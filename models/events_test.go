@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestTailEventsResumesInInsertOrder(t *testing.T) {
+	if err := EnsureEventsCollection(); err != nil {
+		t.Fatalf("Unable to create events collection:\n %v", err)
+	}
+	// the second instance's ID sorts before the event inserted ahead of it
+	now := time.Now()
+	first := RealtimeEvent{ID: bson.NewObjectIdWithTime(now).Hex(), UserID: 1, Type: "test", CreatedAt: now}
+	second := RealtimeEvent{ID: bson.NewObjectIdWithTime(now.Add(-time.Second)).Hex(), UserID: 1, Type: "test", CreatedAt: now}
+	third := RealtimeEvent{ID: bson.NewObjectId().Hex(), UserID: 1, Type: "test", CreatedAt: now}
+	for _, e := range []RealtimeEvent{first, second, third} {
+		if err := InsertRealtimeEvent(e); err != nil {
+			t.Fatalf("Unable to insert event:\n %v", err)
+		}
+	}
+
+	// a tail that delivered first and then reconnected
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []string
+	last, _ := TailEvents(ctx, first.ID, func(e RealtimeEvent) {
+		got = append(got, e.ID)
+		if e.ID == third.ID {
+			cancel()
+		}
+	})
+	if len(got) != 2 || got[0] != second.ID || got[1] != third.ID {
+		t.Errorf("Expected events %s and %s after a reconnect, got: %v", second.ID, third.ID, got)
+	}
+	if last != third.ID {
+		t.Errorf("Expected to resume after %s next time, got: %s", third.ID, last)
+	}
+}
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// job statuses stored in the jobs collection
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobSkipped   = "skipped"
+	JobDead      = "dead"
+)
+
+// Job struct handles the MongoDB schema for a queued background job
+type Job struct {
+	ID          bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name        string        `bson:"name" json:"name"`
+	Payload     bson.M        `bson:"payload" json:"payload,omitempty"`
+	Status      string        `bson:"status" json:"status"`
+	Attempts    int           `bson:"attempts" json:"attempts"`
+	MaxAttempts int           `bson:"maxAttempts" json:"maxAttempts"`
+	RunAt       time.Time     `bson:"runAt" json:"runAt"`
+	LockedBy    string        `bson:"lockedBy,omitempty" json:"lockedBy,omitempty"`
+	LockedAt    *time.Time    `bson:"lockedAt,omitempty" json:"lockedAt,omitempty"`
+	LastError   string        `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt   time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// JobRun struct handles the MongoDB schema for a single attempt of a job
+type JobRun struct {
+	ID         bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	JobID      bson.ObjectId `bson:"jobId" json:"jobId"`
+	Name       string        `bson:"name" json:"name"`
+	Attempt    int           `bson:"attempt" json:"attempt"`
+	Worker     string        `bson:"worker" json:"worker"`
+	Status     string        `bson:"status" json:"status"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt  time.Time     `bson:"startedAt" json:"startedAt"`
+	FinishedAt time.Time     `bson:"finishedAt" json:"finishedAt"`
+	Duration   int64         `bson:"duration" json:"duration"`
+}
+
+// EnqueueJob stores a new job in the queue
+func EnqueueJob(j Job) (*Job, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	if j.ID == "" {
+		j.ID = bson.NewObjectId()
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = now
+	}
+	j.Status = JobQueued
+	j.CreatedAt = now
+	j.UpdatedAt = now
+
+	if err := s.DB(name).C("jobs").Insert(&j); err != nil {
+		log.WithField("JOB NAME", j.Name).Errorf("Unable to enqueue job:\n %v", err)
+		return nil, err
+	}
+	log.WithField("JOB ID", j.ID).Infof("job %s enqueued", j.Name)
+	return &j, nil
+}
+
+// ClaimJob atomically locks the next runnable job for a worker
+// jobs left running by a worker that died are reclaimed after staleAfter
+func ClaimJob(worker string, names []string, staleAfter time.Duration) (*Job, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	query := bson.M{
+		"name": bson.M{"$in": names},
+		"$or": []bson.M{
+			bson.M{"status": JobQueued, "runAt": bson.M{"$lte": now}},
+			bson.M{"status": JobRunning, "lockedAt": bson.M{"$lt": now.Add(-staleAfter)}},
+		},
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{"status": JobRunning, "lockedBy": worker, "lockedAt": now, "updatedAt": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+		ReturnNew: true,
+	}
+
+	var j Job
+	if _, err := s.DB(name).C("jobs").Find(query).Sort("runAt").Apply(change, &j); err != nil {
+		return nil, err
+	}
+	log.WithField("JOB ID", j.ID).Infof("job %s claimed by %s", j.Name, worker)
+	return &j, nil
+}
+
+// TouchJob refreshes the lock of a running job so it is not reclaimed as stale.
+// The lock is matched on the claiming worker and attempt, mgo.ErrNotFound means
+// another worker has reclaimed the job.
+func (j Job) TouchJob() error {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	err := s.DB(name).C("jobs").Update(
+		bson.M{"_id": j.ID, "status": JobRunning, "lockedBy": j.LockedBy, "attempts": j.Attempts},
+		bson.M{"$set": bson.M{"lockedAt": now, "updatedAt": now}},
+	)
+	if err != nil {
+		log.WithField("JOB ID", j.ID).Errorf("Unable to refresh job lock:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// CompleteJob marks a job as succeeded
+func (j Job) CompleteJob() error {
+	return j.setJobStatus(bson.M{"status": JobSucceeded, "lastError": ""})
+}
+
+// RetryJob puts a failed job back in the queue to run again at runAt
+func (j Job) RetryJob(runAt time.Time, reason string) error {
+	return j.setJobStatus(bson.M{"status": JobQueued, "runAt": runAt, "lastError": reason})
+}
+
+// DeadLetterJob parks a job that has exhausted its attempts
+func (j Job) DeadLetterJob(reason string) error {
+	return j.setJobStatus(bson.M{"status": JobDead, "lastError": reason})
+}
+
+// ReleaseJob returns an interrupted job to the queue without counting the attempt
+func (j Job) ReleaseJob() error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("jobs").UpdateId(j.ID, bson.M{
+		"$set":   bson.M{"status": JobQueued, "runAt": time.Now(), "updatedAt": time.Now()},
+		"$unset": bson.M{"lockedBy": "", "lockedAt": ""},
+		"$inc":   bson.M{"attempts": -1},
+	}); err != nil {
+		log.WithField("JOB ID", j.ID).Errorf("Unable to release job:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+func (j Job) setJobStatus(set bson.M) error {
+	s := session.Copy()
+	defer s.Close()
+
+	set["updatedAt"] = time.Now()
+	if err := s.DB(name).C("jobs").UpdateId(j.ID, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"lockedBy": "", "lockedAt": ""},
+	}); err != nil {
+		log.WithField("JOB ID", j.ID).Errorf("Unable to update job status to %v:\n %v", set["status"], err)
+		return err
+	}
+	log.WithField("JOB ID", j.ID).Infof("job %s %v", j.Name, set["status"])
+	return nil
+}
+
+// GetJobsByStatus returns jobs with a given status, most recently updated first
+func GetJobsByStatus(status string, limit int) ([]Job, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var jobs []Job
+	if err := s.DB(name).C("jobs").Find(bson.M{"status": status}).Sort("-updatedAt").Limit(limit).All(&jobs); err != nil {
+		log.WithError(err).Errorf("Unable to find %s jobs", status)
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// SaveJobRun stores the history of a single job attempt
+func SaveJobRun(r JobRun) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if r.ID == "" {
+		r.ID = bson.NewObjectId()
+	}
+	if err := s.DB(name).C("jobRuns").Insert(&r); err != nil {
+		log.WithField("JOB ID", r.JobID).Errorf("Unable to save job run:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetJobRuns returns the most recent job runs, optionally filtered by job name
+func GetJobRuns(jobName string, limit int) ([]JobRun, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	query := bson.M{}
+	if jobName != "" {
+		query["name"] = jobName
+	}
+
+	var runs []JobRun
+	if err := s.DB(name).C("jobRuns").Find(query).Sort("-startedAt").Limit(limit).All(&runs); err != nil {
+		log.WithError(err).Error("Unable to return job runs")
+		return nil, err
+	}
+	return runs, nil
+}
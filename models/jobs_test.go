@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnqueueAndClaimJobSuccess(t *testing.T) {
+	name := "test.enqueue"
+	j, err := EnqueueJob(Job{Name: name, MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Error enqueueing a new test job:\n %v", err)
+	}
+	defer j.CompleteJob()
+
+	claimed, err := ClaimJob("test-worker", []string{name}, time.Minute)
+	if err != nil {
+		t.Fatalf("Unable to claim test job:\n %v", err)
+	}
+	if claimed.Status != JobRunning || claimed.Attempts != 1 {
+		t.Errorf("Claimed job has status %s and %d attempts", claimed.Status, claimed.Attempts)
+	}
+}
+
+func TestClaimJobFailure(t *testing.T) {
+	_, err := ClaimJob("test-worker", []string{"test.none"}, time.Minute)
+	if err == nil || err.Error() != "not found" {
+		t.Errorf("Unable to throw error for empty queue:\n %v", err)
+	}
+}
+
+func TestTouchJob(t *testing.T) {
+	name := "test.touch"
+	j, err := EnqueueJob(Job{Name: name, MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("Error enqueueing a new test job:\n %v", err)
+	}
+	defer j.CompleteJob()
+
+	claimed, err := ClaimJob("test-worker", []string{name}, time.Minute)
+	if err != nil {
+		t.Fatalf("Unable to claim test job:\n %v", err)
+	}
+	if err := claimed.TouchJob(); err != nil {
+		t.Errorf("Unable to refresh lock of claimed job:\n %v", err)
+	}
+
+	// a reclaim counts another attempt, the earlier claim no longer holds the lock
+	stale := *claimed
+	stale.Attempts--
+	if err := stale.TouchJob(); err == nil || err.Error() != "not found" {
+		t.Errorf("Refreshed the lock of a reclaimed job: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Lease struct handles the MongoDB schema for a lock held by a single instance
+type Lease struct {
+	Name       string    `bson:"_id" json:"name"`
+	Owner      string    `bson:"owner" json:"owner"`
+	AcquiredAt time.Time `bson:"acquiredAt" json:"acquiredAt"`
+	RenewedAt  time.Time `bson:"renewedAt" json:"renewedAt"`
+	ExpiresAt  time.Time `bson:"expiresAt" json:"expiresAt"`
+	Held       bool      `bson:"-" json:"held"`
+}
+
+// AcquireLease takes the named lease for owner when it is free, expired or already owned.
+// It returns false when another owner holds an unexpired lease.
+func AcquireLease(leaseName, owner string, ttl time.Duration) (*Lease, bool, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	query := bson.M{
+		"_id": leaseName,
+		"$or": []bson.M{
+			bson.M{"owner": owner},
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{"owner": owner, "acquiredAt": now, "renewedAt": now, "expiresAt": now.Add(ttl)},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+
+	var l Lease
+	if _, err := s.DB(name).C("leases").Find(query).Apply(change, &l); err != nil {
+		// the upsert collides with the existing _id when someone else holds the lease
+		if mgo.IsDup(err) {
+			log.WithField("LEASE", leaseName).Debugf("lease held by another owner, %s not acquired", owner)
+			return nil, false, nil
+		}
+		log.WithField("LEASE", leaseName).Errorf("Unable to acquire lease:\n %v", err)
+		return nil, false, err
+	}
+	l.Held = true
+	log.WithField("LEASE", leaseName).Infof("lease acquired by %s until %v", owner, l.ExpiresAt)
+	return &l, true, nil
+}
+
+// RenewLease extends a lease still held by owner, mgo.ErrNotFound means the lease was lost
+func RenewLease(leaseName, owner string, ttl time.Duration) error {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	if err := s.DB(name).C("leases").Update(
+		bson.M{"_id": leaseName, "owner": owner, "expiresAt": bson.M{"$gte": now}},
+		bson.M{"$set": bson.M{"renewedAt": now, "expiresAt": now.Add(ttl)}},
+	); err != nil {
+		log.WithField("LEASE", leaseName).Errorf("Unable to renew lease for %s:\n %v", owner, err)
+		return err
+	}
+	return nil
+}
+
+// ReleaseLease gives up a lease held by owner
+func ReleaseLease(leaseName, owner string) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("leases").Remove(bson.M{"_id": leaseName, "owner": owner}); err != nil && err != mgo.ErrNotFound {
+		log.WithField("LEASE", leaseName).Errorf("Unable to release lease for %s:\n %v", owner, err)
+		return err
+	}
+	log.WithField("LEASE", leaseName).Infof("lease released by %s", owner)
+	return nil
+}
+
+// GetLeases returns all leases and whether each one is currently held
+func GetLeases() ([]Lease, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var leases []Lease
+	if err := s.DB(name).C("leases").Find(nil).Sort("_id").All(&leases); err != nil {
+		log.WithError(err).Error("Unable to return leases")
+		return nil, err
+	}
+	now := time.Now()
+	for i := range leases {
+		leases[i].Held = leases[i].ExpiresAt.After(now)
+	}
+	return leases, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcquireLeaseSuccess(t *testing.T) {
+	name := "test.lease"
+	_, ok, err := AcquireLease(name, "owner-a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("Unable to acquire free lease:\n %v", err)
+	}
+	defer ReleaseLease(name, "owner-a")
+
+	// the current owner can re-acquire and renew its own lease
+	if _, ok, err := AcquireLease(name, "owner-a", time.Minute); err != nil || !ok {
+		t.Errorf("Unable to re-acquire owned lease:\n %v", err)
+	}
+	if err := RenewLease(name, "owner-a", time.Minute); err != nil {
+		t.Errorf("Unable to renew owned lease:\n %v", err)
+	}
+}
+
+func TestAcquireLeaseHeld(t *testing.T) {
+	name := "test.lease.held"
+	if _, ok, err := AcquireLease(name, "owner-a", time.Minute); err != nil || !ok {
+		t.Fatalf("Unable to acquire free lease:\n %v", err)
+	}
+	defer ReleaseLease(name, "owner-a")
+
+	if _, ok, err := AcquireLease(name, "owner-b", time.Minute); err != nil || ok {
+		t.Errorf("Lease held by owner-a was acquired by owner-b: %v", err)
+	}
+	if err := RenewLease(name, "owner-b", time.Minute); err == nil {
+		t.Error("Lease held by owner-a was renewed by owner-b")
+	}
+}
+
+func TestAcquireLeaseSameProcess(t *testing.T) {
+	// two runs in one process share the worker ID and differ by job ID
+	name := "test.lease.process"
+	a, b := "host-1/job-a", "host-1/job-b"
+	if _, ok, err := AcquireLease(name, a, time.Minute); err != nil || !ok {
+		t.Fatalf("Unable to acquire free lease:\n %v", err)
+	}
+	defer ReleaseLease(name, a)
+
+	if _, ok, err := AcquireLease(name, b, time.Minute); err != nil || ok {
+		t.Errorf("Lease held by %s was acquired by %s: %v", a, b, err)
+	}
+	// releasing as the other run leaves the lease held
+	if err := ReleaseLease(name, b); err != nil {
+		t.Errorf("Unable to release lease not held:\n %v", err)
+	}
+	if err := RenewLease(name, a, time.Minute); err != nil {
+		t.Errorf("Lease of %s was dropped by %s:\n %v", a, b, err)
+	}
+}
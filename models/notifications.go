@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Notification struct handles the MongoDB schema for an entry in a users notification inbox
+type Notification struct {
+	ID          bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	UserID      int64         `bson:"userId" json:"userId"`
+	Type        string        `bson:"type" json:"type"`
+	Title       string        `bson:"title" json:"title"`
+	Body        string        `bson:"body" json:"body"`
+	ChallengeID bson.ObjectId `bson:"challengeId,omitempty" json:"challengeId,omitempty"`
+	Read        bool          `bson:"read" json:"read"`
+	ReadAt      *time.Time    `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt   time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// CreateNotification adds an entry to a users inbox
+func CreateNotification(n Notification) (*Notification, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	if n.ID == "" {
+		n.ID = bson.NewObjectId()
+	}
+	n.CreatedAt = time.Now()
+	if err := s.DB(name).C("notifications").Insert(&n); err != nil {
+		log.WithField("USER ID", n.UserID).Errorf("Unable to create notification:\n %v", err)
+		return nil, err
+	}
+	return &n, nil
+}
+
+// GetNotifications returns a page of a users notifications, newest first.
+// Pass the ID of the last notification on the previous page as before to get the next page.
+func GetNotifications(userID int64, before bson.ObjectId, unreadOnly bool, limit int) ([]Notification, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	query := bson.M{"userId": userID}
+	if before != "" {
+		query["_id"] = bson.M{"$lt": before}
+	}
+	if unreadOnly {
+		query["read"] = false
+	}
+	var notifications []Notification
+	if err := s.DB(name).C("notifications").Find(query).Sort("-_id").Limit(limit).All(&notifications); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to find notifications:\n %v", err)
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// CountUnreadNotifications returns how many notifications a user has not read
+func CountUnreadNotifications(userID int64) (int, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	n, err := s.DB(name).C("notifications").Find(bson.M{"userId": userID, "read": false}).Count()
+	if err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to count unread notifications:\n %v", err)
+		return 0, err
+	}
+	return n, nil
+}
+
+// MarkNotificationRead marks a single notification owned by the user as read
+func MarkNotificationRead(userID int64, id bson.ObjectId) error {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	if err := s.DB(name).C("notifications").Update(
+		bson.M{"_id": id, "userId": userID},
+		bson.M{"$set": bson.M{"read": true, "readAt": now}},
+	); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to mark notification %v read:\n %v", id.Hex(), err)
+		return dbError(err, "notification", id.Hex())
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification for a user as read
+func MarkAllNotificationsRead(userID int64) (int, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	info, err := s.DB(name).C("notifications").UpdateAll(
+		bson.M{"userId": userID, "read": false},
+		bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}},
+	)
+	if err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to mark notifications read:\n %v", err)
+		return 0, err
+	}
+	return info.Updated, nil
+}
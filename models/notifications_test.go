@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestNotificationInboxSuccess(t *testing.T) {
+	userID := int64(-35)
+	n, err := CreateNotification(Notification{UserID: userID, Type: "challenge.received", Title: "New challenge"})
+	if err != nil {
+		t.Fatalf("Error creating a test notification:\n %v", err)
+	}
+	defer func() {
+		s := session.Copy()
+		defer s.Close()
+		s.DB(name).C("notifications").RemoveId(n.ID)
+	}()
+
+	unread, err := CountUnreadNotifications(userID)
+	if err != nil || unread != 1 {
+		t.Errorf("Expected 1 unread notification, got %d:\n %v", unread, err)
+	}
+
+	if err := MarkNotificationRead(userID, n.ID); err != nil {
+		t.Errorf("Unable to mark notification read:\n %v", err)
+	}
+	notifications, err := GetNotifications(userID, "", true, 10)
+	if err != nil || len(notifications) != 0 {
+		t.Errorf("Expected no unread notifications, got %d:\n %v", len(notifications), err)
+	}
+}
+
+func TestMarkNotificationReadFailure(t *testing.T) {
+	if err := MarkNotificationRead(-35, bson.NewObjectId()); err == nil || err.Error() != "not found" {
+		t.Errorf("Unable to throw error for missing notification:\n %v", err)
+	}
+}
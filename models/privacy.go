@@ -0,0 +1,64 @@
+package models
+
+// HasFriend reports whether the user with id is among the users Strava friends
+func (u User) HasFriend(id int64) bool {
+	for _, f := range u.Friends {
+		if f.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// VisibleTo reports whether viewer may see the profile and challenge history of u
+// under their privacy setting, viewer is nil for anonymous requests
+func (u User) VisibleTo(viewer *User) bool {
+	if viewer != nil && viewer.ID == u.ID {
+		return true
+	}
+	switch u.CurrentSettings().Privacy {
+	case PrivacyPrivate:
+		return false
+	case PrivacyFriends:
+		return viewer != nil && u.HasFriend(viewer.ID)
+	}
+	return true
+}
+
+// OnLeaderboardFor reports whether u is ranked on the leaderboards viewer sees, users who hide
+// from leaderboards are left off and otherwise the privacy setting of their profile applies
+func (u User) OnLeaderboardFor(viewer *User) bool {
+	if u.CurrentSettings().HideFromLeaderboards {
+		return false
+	}
+	return u.VisibleTo(viewer)
+}
+
+// ViewFor returns the copy of u that viewer may see.
+// Only the user sees their token, email and account details, others see the profile their
+// privacy setting allows and everyone sees the name and photo needed to challenge them.
+func (u User) ViewFor(viewer *User) User {
+	if viewer != nil && viewer.ID == u.ID {
+		return u
+	}
+	view := User{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		FullName:  u.FullName,
+		Photo:     u.Photo,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+	if u.VisibleTo(viewer) {
+		view.City = u.City
+		view.State = u.State
+		view.Country = u.Country
+		view.Friends = u.Friends
+		view.Segments = u.Segments
+		view.Wins = u.Wins
+		view.Losses = u.Losses
+		view.ChallengeCount = u.ChallengeCount
+	}
+	return view
+}
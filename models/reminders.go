@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Reminder struct handles the MongoDB schema for an expiration reminder that was sent
+type Reminder struct {
+	ID          string        `bson:"_id" json:"id"`
+	ChallengeID bson.ObjectId `bson:"challengeId" json:"challengeId"`
+	UserID      int64         `bson:"userId" json:"userId"`
+	Window      string        `bson:"window" json:"window"`
+	SentAt      time.Time     `bson:"sentAt" json:"sentAt"`
+}
+
+// ClaimReminder records that a user is being reminded about a challenge for a window.
+// It returns false when the reminder was already claimed, so each reminder is only sent once.
+func ClaimReminder(challengeID bson.ObjectId, userID int64, window time.Duration) (bool, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	r := Reminder{
+		ID:          fmt.Sprintf("%s:%d:%s", challengeID.Hex(), userID, window),
+		ChallengeID: challengeID,
+		UserID:      userID,
+		Window:      window.String(),
+		SentAt:      time.Now(),
+	}
+	if err := s.DB(name).C("reminders").Insert(&r); err != nil {
+		if mgo.IsDup(err) {
+			return false, nil
+		}
+		log.WithField("CHALLENGE ID", challengeID).Errorf("Unable to claim reminder:\n %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetChallengesExpiringBetween returns active challenges that expire within a time range
+func GetChallengesExpiringBetween(from, to time.Time) ([]Challenge, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var challenges []Challenge
+	if err := s.DB(name).C("challenges").Find(bson.M{
+		"status":  "active",
+		"expired": false,
+		"expires": bson.M{"$gt": from, "$lte": to},
+	}).Sort("expires").All(&challenges); err != nil {
+		log.WithError(err).Error("Unable to find expiring challenges")
+		return nil, err
+	}
+	return challenges, nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestClaimReminderOnce(t *testing.T) {
+	id := bson.NewObjectId()
+	defer func() {
+		s := session.Copy()
+		defer s.Close()
+		s.DB(name).C("reminders").RemoveAll(bson.M{"challengeId": id})
+	}()
+
+	claimed, err := ClaimReminder(id, -38, 2*time.Hour)
+	if err != nil || !claimed {
+		t.Fatalf("Unable to claim reminder:\n %v", err)
+	}
+	claimed, err = ClaimReminder(id, -38, 2*time.Hour)
+	if err != nil || claimed {
+		t.Errorf("Expected reminder to only be claimed once:\n %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// searchIndex is the name of the text index on the users and segments collections
+const searchIndex = "search"
+
+// EnsureSearchIndexes creates the text indexes users and segments are searched with.
+// Names weigh more than locations and words are not stemmed, they are mostly proper nouns.
+func EnsureSearchIndexes() error {
+	s := session.Copy()
+	defer s.Close()
+
+	indexes := map[string]mgo.Index{
+		"users": {
+			Key:             []string{"$text:fullname", "$text:city"},
+			Name:            searchIndex,
+			Weights:         map[string]int{"fullname": 10, "city": 2},
+			DefaultLanguage: "none",
+			Background:      true,
+		},
+		"segments": {
+			Key:             []string{"$text:name", "$text:city", "$text:state"},
+			Name:            searchIndex,
+			Weights:         map[string]int{"name": 10, "city": 2, "state": 1},
+			DefaultLanguage: "none",
+			Background:      true,
+		},
+	}
+	for collection, index := range indexes {
+		if err := s.DB(name).C(collection).EnsureIndex(index); err != nil {
+			log.WithField("COLLECTION", collection).Errorf("Unable to create search index:\n %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// textSearch returns the query matching q against the text index of a collection, best matches first
+func textSearch(c *mgo.Collection, q string, filter bson.M, limit int) *mgo.Query {
+	selector := bson.M{"$text": bson.M{"$search": q}}
+	for k, v := range filter {
+		selector[k] = v
+	}
+	return c.Find(selector).
+		Select(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		Sort("$textScore:score").
+		Limit(limit)
+}
+
+// SearchUsers returns up to limit registered users whose name or city matches q
+func SearchUsers(q string, limit int) ([]User, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var users []User
+	if err := textSearch(s.DB(name).C("users"), q, bson.M{"deletedAt": nil}, limit).All(&users); err != nil {
+		log.WithField("QUERY", q).Errorf("Unable to search users:\n %v", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// SearchSegments returns up to limit cached segments whose name, city or state matches q,
+// segments Strava flags private or hazardous are never returned
+func SearchSegments(q string, limit int) ([]Segment, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	filter := bson.M{"private": bson.M{"$ne": true}, "hazardous": bson.M{"$ne": true}}
+	var segments []Segment
+	if err := textSearch(s.DB(name).C("segments"), q, filter, limit).All(&segments); err != nil {
+		log.WithField("QUERY", q).Errorf("Unable to search segments:\n %v", err)
+		return nil, err
+	}
+	return segments, nil
+}
@@ -29,6 +29,8 @@ type Segment struct {
 	State              string     `bson:"state" json:"state"`
 	Country            string     `bson:"country" json:"country"`
 	TotalElevationGain float64    `bson:"totalElevationGain" json:"totalElevationGain"`
+	Private            bool       `bson:"private" json:"private"`
+	Hazardous          bool       `bson:"hazardous" json:"hazardous"`
 	StartLocation      [2]float64 `bson:"startLocation" json:"startLocation"`
 	EndLocation        [2]float64 `bson:"endLocation" json:"endLocation"`
 	Map                Map        `bson:"map" json:"map"`
@@ -36,6 +38,12 @@ type Segment struct {
 	UpdatedAt          time.Time  `bson:"updatedAt" json:"updatedAt"`
 }
 
+// Restricted reports whether Strava flags the segment private or hazardous,
+// restricted segments are never listed or challenged on
+func (s Segment) Restricted() bool {
+	return s.Private || s.Hazardous
+}
+
 // GetSegmentByID gets a single stored segment from MongoDB
 func GetSegmentByID(id int64) (*Segment, error) {
 	sess := session.Copy()
@@ -45,7 +53,7 @@ func GetSegmentByID(id int64) (*Segment, error) {
 
 	if err := sess.DB(name).C("segments").Find(bson.M{"_id": id}).One(&s); err != nil {
 		log.WithField("ID", id).Error("Unable to find segment with id")
-		return nil, err
+		return nil, dbError(err, "segment", id)
 	}
 
 	log.WithFields(map[string]interface{}{
@@ -55,6 +63,19 @@ func GetSegmentByID(id int64) (*Segment, error) {
 	return &s, nil
 }
 
+// GetSegmentsByIDs gets the stored segments with the given IDs from MongoDB, missing segments are skipped
+func GetSegmentsByIDs(ids []int64) ([]Segment, error) {
+	sess := session.Copy()
+	defer sess.Close()
+
+	var segments []Segment
+	if err := sess.DB(name).C("segments").Find(bson.M{"_id": bson.M{"$in": ids}}).All(&segments); err != nil {
+		log.WithField("IDS", ids).Errorf("Unable to find segments:\n %v", err)
+		return nil, err
+	}
+	return segments, nil
+}
+
 // SaveSegment stores a cached segment
 // this prevents strava api rate limiting
 func SaveSegment(s *strava.SegmentDetailed) (*Segment, error) {
@@ -75,6 +96,8 @@ func SaveSegment(s *strava.SegmentDetailed) (*Segment, error) {
 		City:               s.City,
 		State:              s.State,
 		Country:            s.Country,
+		Private:            s.Private,
+		Hazardous:          s.Hazardous,
 		StartLocation:      s.StartLocation,
 		EndLocation:        s.EndLocation,
 		Map: Map{
@@ -125,6 +148,8 @@ func (segment Segment) UpdateSegment(s *strava.SegmentDetailed) (*Segment, error
 	segment.City = s.City
 	segment.State = s.State
 	segment.Country = s.Country
+	segment.Private = s.Private
+	segment.Hazardous = s.Hazardous
 	segment.StartLocation = s.StartLocation
 	segment.EndLocation = s.EndLocation
 	segment.Map = Map{
@@ -140,3 +165,63 @@ func (segment Segment) UpdateSegment(s *strava.SegmentDetailed) (*Segment, error
 
 	return &segment, nil
 }
+
+// GetStaleSegmentIDs returns the IDs of cached segments last updated before the cutoff
+func GetStaleSegmentIDs(cutoff time.Time) ([]int64, error) {
+	sess := session.Copy()
+	defer sess.Close()
+
+	var stale []Segment
+	if err := sess.DB(name).C("segments").Find(bson.M{"updatedAt": bson.M{"$lt": cutoff}}).Select(bson.M{"_id": 1}).Sort("updatedAt").All(&stale); err != nil {
+		log.WithError(err).Error("Unable to find stale segments")
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(stale))
+	for _, s := range stale {
+		ids = append(ids, s.ID)
+	}
+	return ids, nil
+}
+
+// IsSegmentReferenced reports whether any user or open challenge still uses the segment
+func IsSegmentReferenced(id int64) (bool, error) {
+	sess := session.Copy()
+	defer sess.Close()
+
+	users, err := sess.DB(name).C("users").Find(bson.M{"segments._id": id}).Count()
+	if err != nil {
+		log.WithField("SEGMENT ID", id).Errorf("Unable to count users with segment:\n %v", err)
+		return false, err
+	}
+	if users > 0 {
+		return true, nil
+	}
+
+	challenges, err := sess.DB(name).C("challenges").Find(bson.M{
+		"segment._id": id,
+		"status":      bson.M{"$in": []string{"pending", "active"}},
+	}).Count()
+	if err != nil {
+		log.WithField("SEGMENT ID", id).Errorf("Unable to count open challenges with segment:\n %v", err)
+		return false, err
+	}
+	return challenges > 0, nil
+}
+
+// UpdateOpenChallengeSegments refreshes the segment copy stored on pending and active challenges
+func UpdateOpenChallengeSegments(segment *Segment) error {
+	sess := session.Copy()
+	defer sess.Close()
+
+	info, err := sess.DB(name).C("challenges").UpdateAll(bson.M{
+		"segment._id": segment.ID,
+		"status":      bson.M{"$in": []string{"pending", "active"}},
+	}, bson.M{"$set": bson.M{"segment": segment}})
+	if err != nil {
+		log.WithField("SEGMENT ID", segment.ID).Errorf("Unable to update open challenge segments:\n %v", err)
+		return err
+	}
+	log.WithField("SEGMENT ID", segment.ID).Infof("updated segment on %d open challenges", info.Updated)
+	return nil
+}
@@ -97,3 +97,28 @@ func TestGetSegmentByIDFailure(t *testing.T) {
 // 	}
 // 	t.Logf("segment %d successfully updated", updated.ID)
 // }
+
+func TestIsSegmentReferencedFailure(t *testing.T) {
+	referenced, err := IsSegmentReferenced(0)
+	if err != nil {
+		t.Errorf("Unable to check segment references:\n %v", err)
+	}
+	if referenced {
+		t.Error("Segment 0 should not be referenced")
+	}
+}
+
+func TestSegmentRestricted(t *testing.T) {
+	for _, tt := range []struct {
+		s   Segment
+		exp bool
+	}{
+		{Segment{}, false},
+		{Segment{Private: true}, true},
+		{Segment{Hazardous: true}, true},
+	} {
+		if got := tt.s.Restricted(); got != tt.exp {
+			t.Errorf("%+v: expected %v, got: %v", tt.s, tt.exp, got)
+		}
+	}
+}
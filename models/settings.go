@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// privacy levels of a users profile and challenges
+const (
+	PrivacyPublic  = "public"
+	PrivacyFriends = "friends"
+	PrivacyPrivate = "private"
+)
+
+// units distances and elevations are shown in
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// NotificationSettings chooses which push notifications a user receives
+type NotificationSettings struct {
+	Push      bool `bson:"push" json:"push"`
+	Reminders bool `bson:"reminders" json:"reminders"`
+}
+
+// UserSettings struct handles the MongoDB schema for the preferences a user edits in the app,
+// they are only changed by the user and never by a Strava sync
+type UserSettings struct {
+	Notifications NotificationSettings `bson:"notifications" json:"notifications"`
+	// ChallengeDays is the duration new challenges default to
+	ChallengeDays int    `bson:"challengeDays" json:"challengeDays"`
+	Privacy       string `bson:"privacy" json:"privacy"`
+	Units         string `bson:"units" json:"units"`
+	// HideFromLeaderboards leaves the user off leaderboards, it is false for settings
+	// stored before it existed so they keep the user listed
+	HideFromLeaderboards bool `bson:"hideFromLeaderboards" json:"hideFromLeaderboards"`
+	// TimeZone is stored on the user, where challenge windows read it from
+	TimeZone string `bson:"-" json:"timeZone"`
+}
+
+// DefaultUserSettings returns the settings of a user who never changed them
+func DefaultUserSettings() UserSettings {
+	return UserSettings{
+		Notifications: NotificationSettings{Push: true, Reminders: true},
+		ChallengeDays: 7,
+		Privacy:       PrivacyPublic,
+		Units:         UnitsMetric,
+	}
+}
+
+// CurrentSettings returns the users settings, defaults are used until they save their own
+func (u User) CurrentSettings() UserSettings {
+	settings := DefaultUserSettings()
+	if u.Settings != nil {
+		settings = *u.Settings
+	}
+	settings.TimeZone = u.TimeZone
+	return settings
+}
+
+// SaveUserSettings stores the settings a user edited
+func SaveUserSettings(id int64, settings UserSettings) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("users").UpdateId(id, bson.M{
+		"$set": bson.M{"settings": settings, "updatedAt": time.Now()},
+	}); err != nil {
+		log.WithField("USER ID", id).Errorf("Unable to save user settings:\n %v", err)
+		return dbError(err, "user", id)
+	}
+	log.WithField("USER ID", id).Infof("user %d settings saved", id)
+	return nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SyncCheckpoint struct handles the MongoDB schema for the progress of a batched sync
+type SyncCheckpoint struct {
+	ID         string     `bson:"_id" json:"id"`
+	LastUserID int64      `bson:"lastUserId" json:"lastUserId"`
+	Processed  int        `bson:"processed" json:"processed"`
+	Succeeded  int        `bson:"succeeded" json:"succeeded"`
+	Failed     int        `bson:"failed" json:"failed"`
+	Skipped    int        `bson:"skipped" json:"skipped"`
+	StartedAt  time.Time  `bson:"startedAt" json:"startedAt"`
+	UpdatedAt  time.Time  `bson:"updatedAt" json:"updatedAt"`
+	FinishedAt *time.Time `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+}
+
+// UserSync struct handles the MongoDB schema for the Strava sync state of a single user
+type UserSync struct {
+	UserID         int64      `bson:"_id" json:"userId"`
+	LastSyncedAt   *time.Time `bson:"lastSyncedAt,omitempty" json:"lastSyncedAt,omitempty"`
+	LastAttemptAt  time.Time  `bson:"lastAttemptAt" json:"lastAttemptAt"`
+	LastError      string     `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	Failures       int        `bson:"failures" json:"failures"`
+	RetryAt        *time.Time `bson:"retryAt,omitempty" json:"retryAt,omitempty"`
+	ActivityCursor *time.Time `bson:"activityCursor,omitempty" json:"activityCursor,omitempty"`
+}
+
+// GetSyncCheckpoint gets the checkpoint for a sync, mgo.ErrNotFound means it never ran
+func GetSyncCheckpoint(id string) (*SyncCheckpoint, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var c SyncCheckpoint
+	if err := s.DB(name).C("syncs").FindId(id).One(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SaveSyncCheckpoint stores the progress of a sync
+func (c SyncCheckpoint) SaveSyncCheckpoint() error {
+	s := session.Copy()
+	defer s.Close()
+
+	c.UpdatedAt = time.Now()
+	if _, err := s.DB(name).C("syncs").UpsertId(c.ID, &c); err != nil {
+		log.WithField("SYNC", c.ID).Errorf("Unable to save sync checkpoint:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetUsersAfter returns the next batch of users ordered by ID
+func GetUsersAfter(id int64, limit int) ([]User, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var users []User
+	if err := s.DB(name).C("users").Find(bson.M{"_id": bson.M{"$gt": id}}).Sort("_id").Limit(limit).All(&users); err != nil {
+		log.WithError(err).Errorf("Unable to return users after %d", id)
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserSync gets the sync state for a user, a user that never synced has an empty state
+func GetUserSync(userID int64) (*UserSync, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var us UserSync
+	if err := s.DB(name).C("userSyncs").FindId(userID).One(&us); err != nil {
+		if err == mgo.ErrNotFound {
+			return &UserSync{UserID: userID}, nil
+		}
+		log.WithField("USER ID", userID).Errorf("Unable to find user sync:\n %v", err)
+		return nil, err
+	}
+	return &us, nil
+}
+
+// RecordUserSyncSuccess marks a user as synced at syncedAt
+func RecordUserSyncSuccess(userID int64, syncedAt time.Time) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if _, err := s.DB(name).C("userSyncs").UpsertId(userID, bson.M{
+		"$set":   bson.M{"lastSyncedAt": syncedAt, "lastAttemptAt": syncedAt, "failures": 0},
+		"$unset": bson.M{"lastError": "", "retryAt": ""},
+	}); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to record user sync success:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// RecordUserSyncFailure stores why a user failed to sync and when to try again
+func RecordUserSyncFailure(userID int64, reason string, retryAt time.Time) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if _, err := s.DB(name).C("userSyncs").UpsertId(userID, bson.M{
+		"$set": bson.M{"lastAttemptAt": time.Now(), "lastError": reason, "retryAt": retryAt},
+		"$inc": bson.M{"failures": 1},
+	}); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to record user sync failure:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetFailedUserSyncs returns users whose last sync failed
+func GetFailedUserSyncs() ([]UserSync, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var syncs []UserSync
+	if err := s.DB(name).C("userSyncs").Find(bson.M{"failures": bson.M{"$gt": 0}}).Sort("retryAt").All(&syncs); err != nil {
+		log.WithError(err).Error("Unable to return failed user syncs")
+		return nil, err
+	}
+	return syncs, nil
+}
+
+// SaveActivityCursor moves the latest activity time seen for a user forward
+func SaveActivityCursor(userID int64, cursor time.Time) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if _, err := s.DB(name).C("userSyncs").UpsertId(userID, bson.M{
+		"$max": bson.M{"activityCursor": cursor},
+	}); err != nil {
+		log.WithField("USER ID", userID).Errorf("Unable to save activity cursor:\n %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserSyncFailureAndSuccess(t *testing.T) {
+	id := int64(-1)
+
+	state, err := GetUserSync(id)
+	if err != nil || state.Failures != 0 {
+		t.Fatalf("Unable to get empty user sync:\n %v", err)
+	}
+
+	retryAt := time.Now().Add(time.Hour)
+	if err := RecordUserSyncFailure(id, "strava unavailable", retryAt); err != nil {
+		t.Fatalf("Unable to record user sync failure:\n %v", err)
+	}
+	state, err = GetUserSync(id)
+	if err != nil || state.Failures != 1 || state.RetryAt == nil {
+		t.Errorf("User sync failure was not recorded: %+v %v", state, err)
+	}
+
+	if err := RecordUserSyncSuccess(id, time.Now()); err != nil {
+		t.Fatalf("Unable to record user sync success:\n %v", err)
+	}
+	state, err = GetUserSync(id)
+	if err != nil || state.Failures != 0 || state.RetryAt != nil || state.LastSyncedAt == nil {
+		t.Errorf("User sync success was not recorded: %+v %v", state, err)
+	}
+}
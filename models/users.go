@@ -5,6 +5,8 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/strava/go.strava"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // Friend struct handles the MongoDB schema for each users friends
@@ -33,23 +35,70 @@ type User struct {
 	FirstName      string         `bson:"firstname" json:"firstName"`
 	LastName       string         `bson:"lastname" json:"lastName"`
 	FullName       string         `bson:"fullname" json:"fullName"`
-	City           string         `bson:"city" json:"city"`
-	State          string         `bson:"state" json:"state"`
-	Country        string         `bson:"country" json:"country"`
-	Gender         string         `bson:"gender" json:"gender"`
-	Token          string         `bson:"token" json:"token"`
+	City           string         `bson:"city" json:"city,omitempty"`
+	State          string         `bson:"state" json:"state,omitempty"`
+	Country        string         `bson:"country" json:"country,omitempty"`
+	Gender         string         `bson:"gender" json:"gender,omitempty"`
+	Token          string         `bson:"token" json:"token,omitempty"`
 	Photo          string         `bson:"photo" json:"photo"`
-	Email          string         `bson:"email" json:"email"`
-	Friends        []*Friend      `bson:"friends" json:"friends"`
-	Segments       []*UserSegment `bson:"segments" json:"segments"`
+	Email          string         `bson:"email" json:"email,omitempty"`
+	Friends        []*Friend      `bson:"friends" json:"friends,omitempty"`
+	Segments       []*UserSegment `bson:"segments" json:"segments,omitempty"`
 	Wins           int            `bson:"wins" json:"wins"`
 	Losses         int            `bson:"losses" json:"losses"`
 	ChallengeCount int            `bson:"challengeCount" json:"challengeCount"`
+	TimeZone       string         `bson:"timeZone,omitempty" json:"timeZone,omitempty"`
+	TimeZoneSource string         `bson:"timeZoneSource,omitempty" json:"timeZoneSource,omitempty"`
+	Settings       *UserSettings  `bson:"settings,omitempty" json:"-"`
 	CreatedAt      time.Time      `bson:"createdAt" json:"createdAt,omitempty"`
 	UpdatedAt      time.Time      `bson:"updatedAt" json:"updatedAt,omitempty"`
 	DeletedAt      *time.Time     `bson:"deletedAt" json:"deletedAt,omitempty"`
 }
 
+// where a users time zone came from, a zone the user set is never replaced by Strava data
+const (
+	TimeZoneFromUser   = "user"
+	TimeZoneFromStrava = "strava"
+)
+
+// Location returns the users time zone, UTC when it is unknown or invalid
+func (u User) Location() *time.Location {
+	if u.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		log.WithField("USER ID", u.ID).Warnf("invalid time zone %q, using UTC", u.TimeZone)
+		return time.UTC
+	}
+	return loc
+}
+
+// SaveUserTimeZone stores the IANA time zone challenge windows are computed in for a user,
+// a zone detected from Strava never replaces one the user chose
+func SaveUserTimeZone(id int64, zone, source string) error {
+	s := session.Copy()
+	defer s.Close()
+
+	selector := bson.M{"_id": id}
+	if source == TimeZoneFromStrava {
+		selector["timeZoneSource"] = bson.M{"$ne": TimeZoneFromUser}
+	}
+	err := s.DB(name).C("users").Update(selector, bson.M{
+		"$set": bson.M{"timeZone": zone, "timeZoneSource": source, "updatedAt": time.Now()},
+	})
+	if err == mgo.ErrNotFound && source == TimeZoneFromStrava {
+		log.WithField("USER ID", id).Infof("user %d chose their time zone, ignoring %s", id, zone)
+		return nil
+	}
+	if err != nil {
+		log.WithField("USER ID", id).Errorf("Unable to save user time zone:\n %v", err)
+		return err
+	}
+	log.WithField("USER ID", id).Infof("user %d time zone set to %s from %s", id, zone, source)
+	return nil
+}
+
 // GetUserByID gets a single stored user from MongoDB
 func GetUserByID(id int64) (*User, error) {
 	s := session.Copy()
@@ -59,7 +108,7 @@ func GetUserByID(id int64) (*User, error) {
 
 	if err := s.DB(name).C("users").FindId(id).One(&u); err != nil {
 		log.WithField("USER ID", id).Errorf("Unable to find user with id:\n%v", err)
-		return nil, err
+		return nil, dbError(err, "user", id)
 	}
 
 	log.WithField("USER ID", u.ID).Infof("user found %d", u.ID)
@@ -67,6 +116,32 @@ func GetUserByID(id int64) (*User, error) {
 	return &u, nil
 }
 
+// GetUsersByIDs gets the stored users with the given IDs from MongoDB, missing users are skipped
+func GetUsersByIDs(ids []int64) ([]User, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var users []User
+	if err := s.DB(name).C("users").Find(bson.M{"_id": bson.M{"$in": ids}}).All(&users); err != nil {
+		log.WithField("USER IDS", ids).Errorf("Unable to find users:\n %v", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserByToken gets the user the access token was issued to
+func GetUserByToken(token string) (*User, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var u User
+	if err := s.DB(name).C("users").Find(bson.M{"token": token}).One(&u); err != nil {
+		log.Errorf("Unable to find user by token:\n %v", err)
+		return nil, dbError(err, "user", "token")
+	}
+	return &u, nil
+}
+
 // CreateUser creates user in MongoDB
 func CreateUser(auth *strava.AuthorizationResponse) (*User, error) {
 	s := session.Copy()
@@ -96,26 +171,7 @@ func CreateUser(auth *strava.AuthorizationResponse) (*User, error) {
 	return &user, nil
 }
 
-// RemoveFriendsSegmentsFromUser modifies user in MongoDB
-func (u User) RemoveFriendsSegmentsFromUser() error {
-	s := session.Copy()
-	defer s.Close()
-
-	u.Friends = u.Friends[:0]
-	log.Infof("%d friends", len(u.Friends))
-	u.Segments = u.Segments[:0]
-	log.Infof("%d segments", len(u.Segments))
-	u.UpdatedAt = time.Now()
-
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
-		log.WithField("USER ID", u.ID).Errorf("Unable to remove segments and friends from user:\n %v", err)
-		return err
-	}
-	log.Infof("segments and friends removed from user %d", u.ID)
-	return nil
-}
-
-// UpdateUser updates user in MongoDB
+// UpdateUser updates user in MongoDB, only the Strava fields are written so the users settings are kept
 func (u User) UpdateUser(auth *strava.AuthorizationResponse) (*User, error) {
 	s := session.Copy()
 	defer s.Close()
@@ -133,7 +189,19 @@ func (u User) UpdateUser(auth *strava.AuthorizationResponse) (*User, error) {
 	u.Email = auth.Athlete.Email
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{"$set": bson.M{
+		"firstname": u.FirstName,
+		"lastname":  u.LastName,
+		"fullname":  u.FullName,
+		"city":      u.City,
+		"state":     u.State,
+		"country":   u.Country,
+		"gender":    u.Gender,
+		"token":     u.Token,
+		"photo":     u.Photo,
+		"email":     u.Email,
+		"updatedAt": u.UpdatedAt,
+	}}); err != nil {
 		log.WithField("USER ID", u.ID).Errorf("Unable to update user:\n %v", err)
 		return nil, err
 	}
@@ -160,7 +228,7 @@ func RegisterUser(auth *strava.AuthorizationResponse) (*User, error) {
 	return user, nil
 }
 
-// UpdateAthlete updates user in MongoDB
+// UpdateAthlete updates user in MongoDB, only the Strava fields are written so the users settings are kept
 func (u User) UpdateAthlete(athlete *strava.AthleteDetailed) (*User, error) {
 	s := session.Copy()
 	defer s.Close()
@@ -177,7 +245,18 @@ func (u User) UpdateAthlete(athlete *strava.AthleteDetailed) (*User, error) {
 	u.Email = athlete.Email
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{"$set": bson.M{
+		"firstname": u.FirstName,
+		"lastname":  u.LastName,
+		"fullname":  u.FullName,
+		"city":      u.City,
+		"state":     u.State,
+		"country":   u.Country,
+		"gender":    u.Gender,
+		"photo":     u.Photo,
+		"email":     u.Email,
+		"updatedAt": u.UpdatedAt,
+	}}); err != nil {
 		log.WithField("USER ID", u.ID).Errorf("Unable to update user %v:\n %v", u.ID, err)
 		return nil, err
 	}
@@ -192,7 +271,9 @@ func (u User) SaveUserFriends(friends []*Friend) error {
 	u.Friends = friends
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{
+		"$set": bson.M{"friends": u.Friends, "updatedAt": u.UpdatedAt},
+	}); err != nil {
 		log.Error("unable to save user friends")
 		return err
 	}
@@ -207,7 +288,9 @@ func (u User) SaveUserSegments(segments []*UserSegment) error {
 	u.Segments = segments
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{
+		"$set": bson.M{"segments": u.Segments, "updatedAt": u.UpdatedAt},
+	}); err != nil {
 		log.WithField("USER ID", u.ID).Error("unable to save user segments")
 		return err
 	}
@@ -235,7 +318,13 @@ func (u *User) IncrementWins(id int64) error {
 
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{"$set": bson.M{
+		"wins":           u.Wins,
+		"losses":         u.Losses,
+		"challengeCount": u.ChallengeCount,
+		"friends":        u.Friends,
+		"updatedAt":      u.UpdatedAt,
+	}}); err != nil {
 		log.Error("unable to save user increment wins")
 		return err
 	}
@@ -263,7 +352,13 @@ func (u *User) IncrementLosses(id int64) error {
 
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{"$set": bson.M{
+		"wins":           u.Wins,
+		"losses":         u.Losses,
+		"challengeCount": u.ChallengeCount,
+		"friends":        u.Friends,
+		"updatedAt":      u.UpdatedAt,
+	}}); err != nil {
 		log.Error("unable to save user increment losses")
 		return err
 	}
@@ -288,7 +383,9 @@ func (u *User) IncrementSegments(id int64) error {
 
 	u.UpdatedAt = time.Now()
 
-	if err := s.DB(name).C("users").UpdateId(u.ID, &u); err != nil {
+	if err := s.DB(name).C("users").UpdateId(u.ID, bson.M{
+		"$set": bson.M{"segments": u.Segments, "updatedAt": u.UpdatedAt},
+	}); err != nil {
 		log.Error("unable to save user segments increment")
 		return err
 	}
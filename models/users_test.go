@@ -23,3 +23,91 @@ func TestGetUserByIDFailure(t *testing.T) {
 		t.Errorf("Unable to throw error for ID:\n %v", err)
 	}
 }
+
+func TestCurrentSettings(t *testing.T) {
+	u := User{TimeZone: "Europe/Paris"}
+	exp := DefaultUserSettings()
+	exp.TimeZone = "Europe/Paris"
+	if got := u.CurrentSettings(); got != exp {
+		t.Errorf("expected defaults %+v, got: %+v", exp, got)
+	}
+
+	u.Settings = &UserSettings{ChallengeDays: 3, Privacy: PrivacyPrivate, Units: UnitsImperial, TimeZone: "ignored"}
+	exp = UserSettings{ChallengeDays: 3, Privacy: PrivacyPrivate, Units: UnitsImperial, TimeZone: "Europe/Paris"}
+	if got := u.CurrentSettings(); got != exp {
+		t.Errorf("expected saved settings %+v, got: %+v", exp, got)
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	friend, stranger := &User{ID: 2}, &User{ID: 3}
+	u := User{ID: 1, Friends: []*Friend{{ID: 2}}}
+	tests := []struct {
+		privacy string
+		viewer  *User
+		exp     bool
+	}{
+		{PrivacyPublic, nil, true},
+		{PrivacyPublic, stranger, true},
+		{PrivacyFriends, nil, false},
+		{PrivacyFriends, stranger, false},
+		{PrivacyFriends, friend, true},
+		{PrivacyPrivate, friend, false},
+		{PrivacyPrivate, &User{ID: 1}, true},
+	}
+	for _, tt := range tests {
+		u.Settings = &UserSettings{Privacy: tt.privacy}
+		if got := u.VisibleTo(tt.viewer); got != tt.exp {
+			t.Errorf("%s viewed by %+v: expected %v, got: %v", tt.privacy, tt.viewer, tt.exp, got)
+		}
+	}
+}
+
+func TestOnLeaderboardFor(t *testing.T) {
+	friend, stranger := &User{ID: 2}, &User{ID: 3}
+	u := User{ID: 1, Friends: []*Friend{{ID: 2}}}
+	if !u.OnLeaderboardFor(stranger) {
+		t.Error("expected users with default settings to be ranked")
+	}
+	tests := []struct {
+		settings UserSettings
+		viewer   *User
+		exp      bool
+	}{
+		{UserSettings{Privacy: PrivacyPublic}, nil, true},
+		{UserSettings{Privacy: PrivacyPublic, HideFromLeaderboards: true}, friend, false},
+		{UserSettings{Privacy: PrivacyFriends}, friend, true},
+		{UserSettings{Privacy: PrivacyFriends}, stranger, false},
+		{UserSettings{Privacy: PrivacyPrivate}, friend, false},
+	}
+	for _, tt := range tests {
+		u.Settings = &tt.settings
+		if got := u.OnLeaderboardFor(tt.viewer); got != tt.exp {
+			t.Errorf("%+v viewed by %+v: expected %v, got: %v", tt.settings, tt.viewer, tt.exp, got)
+		}
+	}
+}
+
+func TestViewFor(t *testing.T) {
+	u := User{
+		ID: 1, FullName: "Alex Rider", City: "Oakland", Email: "alex@example.com", Token: "secret",
+		Friends: []*Friend{{ID: 2}}, Wins: 3, TimeZone: "America/Los_Angeles",
+	}
+	if got := u.ViewFor(&User{ID: 1}); got.Token != "secret" || got.Email == "" {
+		t.Errorf("expected the user to see their own account, got: %+v", got)
+	}
+
+	view := u.ViewFor(&User{ID: 2})
+	if view.Token != "" || view.Email != "" || view.TimeZone != "" {
+		t.Errorf("expected account details to be hidden, got: %+v", view)
+	}
+	if view.FullName != "Alex Rider" || view.City != "Oakland" || view.Wins != 3 || len(view.Friends) != 1 {
+		t.Errorf("expected the public profile, got: %+v", view)
+	}
+
+	u.Settings = &UserSettings{Privacy: PrivacyPrivate}
+	view = u.ViewFor(nil)
+	if view.FullName != "Alex Rider" || view.City != "" || view.Wins != 0 || view.Friends != nil {
+		t.Errorf("expected only the name of a private user, got: %+v", view)
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Webhook struct handles the MongoDB schema for an outbound webhook registered by a user
+type Webhook struct {
+	ID        bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	OwnerID   int64         `bson:"ownerId" json:"ownerId"`
+	URL       string        `bson:"url" json:"url"`
+	Secret    string        `bson:"secret" json:"-"`
+	Events    []string      `bson:"events" json:"events"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// WebhookDelivery struct handles the MongoDB schema for a single attempt to deliver a webhook event
+type WebhookDelivery struct {
+	ID          bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	WebhookID   bson.ObjectId `bson:"webhookId" json:"webhookId"`
+	DeliveryID  string        `bson:"deliveryId" json:"deliveryId"`
+	Event       string        `bson:"event" json:"event"`
+	Attempt     int           `bson:"attempt" json:"attempt"`
+	Success     bool          `bson:"success" json:"success"`
+	StatusCode  int           `bson:"statusCode,omitempty" json:"statusCode,omitempty"`
+	Error       string        `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMS  int64         `bson:"durationMs" json:"durationMs"`
+	AttemptedAt time.Time     `bson:"attemptedAt" json:"attemptedAt"`
+}
+
+// CreateWebhook stores a new webhook
+func CreateWebhook(w Webhook) (*Webhook, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	now := time.Now()
+	w.ID = bson.NewObjectId()
+	w.CreatedAt = now
+	w.UpdatedAt = now
+	if err := s.DB(name).C("webhooks").Insert(&w); err != nil {
+		log.WithField("USER ID", w.OwnerID).Errorf("Unable to create webhook:\n %v", err)
+		return nil, err
+	}
+	log.WithField("USER ID", w.OwnerID).Infof("webhook %s created for user %d", w.ID.Hex(), w.OwnerID)
+	return &w, nil
+}
+
+// GetWebhookByID gets a webhook owned by a user
+func GetWebhookByID(ownerID int64, id bson.ObjectId) (*Webhook, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var w Webhook
+	if err := s.DB(name).C("webhooks").Find(bson.M{"_id": id, "ownerId": ownerID}).One(&w); err != nil {
+		return nil, dbError(err, "webhook", id.Hex())
+	}
+	return &w, nil
+}
+
+// GetWebhooksByOwner returns every webhook registered by a user
+func GetWebhooksByOwner(ownerID int64) ([]Webhook, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var hooks []Webhook
+	if err := s.DB(name).C("webhooks").Find(bson.M{"ownerId": ownerID}).Sort("createdAt").All(&hooks); err != nil {
+		log.WithField("USER ID", ownerID).Errorf("Unable to find webhooks:\n %v", err)
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// GetWebhooksForEvent returns webhooks owned by any of the users that subscribe to an event
+func GetWebhooksForEvent(event string, ownerIDs []int64) ([]Webhook, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var hooks []Webhook
+	if err := s.DB(name).C("webhooks").Find(bson.M{
+		"ownerId": bson.M{"$in": ownerIDs},
+		"events":  event,
+	}).All(&hooks); err != nil {
+		log.WithError(err).Errorf("Unable to find webhooks for %s", event)
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// RemoveWebhook deletes a webhook owned by a user
+func RemoveWebhook(ownerID int64, id bson.ObjectId) error {
+	s := session.Copy()
+	defer s.Close()
+
+	if err := s.DB(name).C("webhooks").Remove(bson.M{"_id": id, "ownerId": ownerID}); err != nil {
+		log.WithField("USER ID", ownerID).Errorf("Unable to remove webhook %s:\n %v", id.Hex(), err)
+		return dbError(err, "webhook", id.Hex())
+	}
+	return nil
+}
+
+// SaveWebhookDelivery records a delivery attempt in the delivery log
+func SaveWebhookDelivery(d WebhookDelivery) error {
+	s := session.Copy()
+	defer s.Close()
+
+	d.ID = bson.NewObjectId()
+	if err := s.DB(name).C("webhookDeliveries").Insert(&d); err != nil {
+		log.WithField("WEBHOOK ID", d.WebhookID.Hex()).Errorf("Unable to save webhook delivery:\n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the most recent delivery attempts for a webhook
+func GetWebhookDeliveries(webhookID bson.ObjectId, limit int) ([]WebhookDelivery, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	var deliveries []WebhookDelivery
+	if err := s.DB(name).C("webhookDeliveries").Find(bson.M{"webhookId": webhookID}).Sort("-attemptedAt").Limit(limit).All(&deliveries); err != nil {
+		log.WithField("WEBHOOK ID", webhookID.Hex()).Errorf("Unable to find webhook deliveries:\n %v", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
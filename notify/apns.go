@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// APNs endpoints, requests are made over HTTP/2
+const (
+	APNSProductionHost  = "https://api.push.apple.com"
+	APNSDevelopmentHost = "https://api.sandbox.push.apple.com"
+)
+
+// apnsTokenTTL is how long a provider token is reused, Apple rejects tokens older than an hour
+const apnsTokenTTL = 50 * time.Minute
+
+// APNSSender sends notifications to iOS devices with token based authentication
+type APNSSender struct {
+	// Host is the APNs endpoint, tests point it at a local server
+	Host   string
+	keyID  string
+	teamID string
+	topic  string
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+}
+
+// NewAPNSSender instantiates an APNSSender from a .p8 signing key
+func NewAPNSSender(keyPEM []byte, keyID, teamID, topic string, production bool) (*APNSSender, error) {
+	signer, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := signer.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("APNs key must be an ECDSA private key")
+	}
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, errors.New("APNs key ID, team ID and topic are required")
+	}
+	host := APNSDevelopmentHost
+	if production {
+		host = APNSProductionHost
+	}
+	return &APNSSender{
+		Host:   host,
+		keyID:  keyID,
+		teamID: teamID,
+		topic:  topic,
+		key:    key,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Send delivers a notification to a single device
+func (s *APNSSender) Send(token string, n Notification) error {
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"title": n.Title, "body": n.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.Host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+auth)
+	req.Header.Set("apns-topic", s.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apnsErr)
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		apnsErr.Reason == "BadDeviceToken",
+		apnsErr.Reason == "Unregistered":
+		return ErrInvalidToken
+	case apnsErr.Reason == "ExpiredProviderToken":
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("APNs returned %d: %s", resp.StatusCode, apnsErr.Reason)
+}
+
+// providerToken returns the signed JWT used to authenticate with APNs
+func (s *APNSSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Since(s.issued) < apnsTokenTTL {
+		return s.token, nil
+	}
+	now := time.Now()
+	token, err := signJWT(
+		map[string]interface{}{"alg": "ES256", "kid": s.keyID},
+		map[string]interface{}{"iss": s.teamID, "iat": now.Unix()},
+		s.key,
+	)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.issued = token, now
+	return token, nil
+}
@@ -0,0 +1,290 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Email is a rendered message with text and HTML alternatives
+type Email struct {
+	To             string
+	Subject        string
+	Text           string
+	HTML           string
+	UnsubscribeURL string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(e Email) error
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// Send delivers the email
+func (m SMTPMailer) Send(e Email) error {
+	msg, err := e.message(m.From, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{e.To}, msg)
+}
+
+// LogMailer logs emails instead of sending them, used for local development
+type LogMailer struct{}
+
+// Send logs the email
+func (LogMailer) Send(e Email) error {
+	log.Infof("email to %s: %s", e.To, e.Subject)
+	return nil
+}
+
+// MailerFromEnv configures an SMTP mailer from the environment,
+// emails are logged when SMTP_HOST is not set
+func MailerFromEnv() Mailer {
+	host, ok := os.LookupEnv("SMTP_HOST")
+	if !ok {
+		return LogMailer{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		from = "Bestrida <no-reply@bestridaapp.com>"
+	}
+	m := SMTPMailer{Addr: host + ":" + port, From: from}
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		m.Auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return m
+}
+
+// message encodes the email as a multipart/alternative MIME message
+func (e Email) message(from string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := []string{
+		"From: " + from,
+		"To: " + e.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", e.Subject),
+		"Date: " + date.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+	}
+	if e.UnsubscribeURL != "" {
+		headers = append(headers,
+			"List-Unsubscribe: <"+e.UnsubscribeURL+">",
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		)
+	}
+	headers = append(headers, "Content-Type: multipart/alternative; boundary="+mw.Boundary())
+	for _, h := range headers {
+		buf.WriteString(h + "\r\n")
+	}
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", e.Text},
+		{"text/html; charset=utf-8", e.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DigestItem is a single challenge listed in the weekly digest
+type DigestItem struct {
+	Opponent string
+	Segment  string
+	Result   string
+	Date     time.Time
+}
+
+// Digest summarizes a users challenges for the weekly email
+type Digest struct {
+	Name     string
+	Pending  []DigestItem
+	Settled  []DigestItem
+	Expiring []DigestItem
+}
+
+// Empty reports whether there is nothing to tell the user about
+func (d Digest) Empty() bool {
+	return len(d.Pending) == 0 && len(d.Settled) == 0 && len(d.Expiring) == 0
+}
+
+// NewDigest builds the weekly digest for a user from their challenges
+func NewDigest(u *models.User, pending, settled, expiring []models.Challenge) Digest {
+	d := Digest{Name: u.FirstName}
+	for _, c := range pending {
+		d.Pending = append(d.Pending, digestItem(c, u.ID))
+	}
+	for _, c := range settled {
+		d.Settled = append(d.Settled, digestItem(c, u.ID))
+	}
+	for _, c := range expiring {
+		d.Expiring = append(d.Expiring, digestItem(c, u.ID))
+	}
+	return d
+}
+
+func digestItem(c models.Challenge, userID int64) DigestItem {
+	item := DigestItem{}
+	if c.Segment != nil {
+		item.Segment = c.Segment.Name
+	}
+	if c.Challenger != nil && c.Challengee != nil {
+		item.Opponent = c.Challenger.Name
+		if c.Challenger.ID == userID {
+			item.Opponent = c.Challengee.Name
+		}
+	}
+	switch {
+	case c.Status != "complete":
+		if c.Expires != nil {
+			item.Date = c.Expires.In(c.Location())
+		}
+	case c.WinnerID == nil:
+		item.Result = "tied"
+	case *c.WinnerID == userID:
+		item.Result = "won"
+	default:
+		item.Result = "lost"
+	}
+	if c.Completed != nil && c.Status == "complete" {
+		item.Date = c.Completed.In(c.Location())
+	}
+	return item
+}
+
+var emailFuncs = map[string]interface{}{
+	"date": func(t time.Time) string { return t.Format("Mon Jan 2") },
+}
+
+var (
+	notificationText = texttemplate.Must(texttemplate.New("notification").Parse(notificationTextTemplate))
+	notificationHTML = htmltemplate.Must(htmltemplate.New("notification").Parse(notificationHTMLTemplate))
+	digestText       = texttemplate.Must(texttemplate.New("digest").Funcs(emailFuncs).Parse(digestTextTemplate))
+	digestHTML       = htmltemplate.Must(htmltemplate.New("digest").Funcs(emailFuncs).Parse(digestHTMLTemplate))
+)
+
+// RenderNotificationEmail renders a challenge notification as an email
+func RenderNotificationEmail(to string, n Notification, unsubscribeURL string) (Email, error) {
+	data := struct {
+		Notification
+		UnsubscribeURL string
+	}{n, unsubscribeURL}
+	return render(to, n.Title, unsubscribeURL, data, notificationText, notificationHTML)
+}
+
+// RenderDigestEmail renders the weekly digest as an email
+func RenderDigestEmail(to string, d Digest, unsubscribeURL string) (Email, error) {
+	data := struct {
+		Digest
+		UnsubscribeURL string
+	}{d, unsubscribeURL}
+	return render(to, "Your week on Bestrida", unsubscribeURL, data, digestText, digestHTML)
+}
+
+func render(to, subject, unsubscribeURL string, data interface{}, text *texttemplate.Template, html *htmltemplate.Template) (Email, error) {
+	var t, h bytes.Buffer
+	if err := text.Execute(&t, data); err != nil {
+		return Email{}, fmt.Errorf("unable to render %s text email: %v", text.Name(), err)
+	}
+	if err := html.Execute(&h, data); err != nil {
+		return Email{}, fmt.Errorf("unable to render %s html email: %v", html.Name(), err)
+	}
+	return Email{To: to, Subject: subject, Text: t.String(), HTML: h.String(), UnsubscribeURL: unsubscribeURL}, nil
+}
+
+const notificationTextTemplate = `{{.Title}}
+
+{{.Body}}
+
+Open Bestrida to see the challenge.
+
+--
+You are receiving this because you turned on Bestrida email notifications.
+Unsubscribe: {{.UnsubscribeURL}}
+`
+
+const notificationHTMLTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: Helvetica, Arial, sans-serif; color: #333;">
+<h2>{{.Title}}</h2>
+<p>{{.Body}}</p>
+<p>Open Bestrida to see the challenge.</p>
+<hr>
+<p style="font-size: 12px; color: #888;">You are receiving this because you turned on Bestrida email notifications.
+<a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>
+</body>
+</html>
+`
+
+const digestTextTemplate = `Hi {{.Name}}, here is your week on Bestrida.
+{{if .Pending}}
+Waiting on a response
+{{range .Pending}}- {{.Opponent}} on {{.Segment}}, ends {{date .Date}}
+{{end}}{{end}}{{if .Expiring}}
+Ending this week
+{{range .Expiring}}- {{.Opponent}} on {{.Segment}}, ends {{date .Date}}
+{{end}}{{end}}{{if .Settled}}
+Results
+{{range .Settled}}- You {{.Result}} against {{.Opponent}} on {{.Segment}}
+{{end}}{{end}}
+--
+You are receiving this because you subscribed to the Bestrida weekly digest.
+Unsubscribe: {{.UnsubscribeURL}}
+`
+
+const digestHTMLTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: Helvetica, Arial, sans-serif; color: #333;">
+<p>Hi {{.Name}}, here is your week on Bestrida.</p>
+{{if .Pending}}<h3>Waiting on a response</h3>
+<ul>{{range .Pending}}<li>{{.Opponent}} on {{.Segment}}, ends {{date .Date}}</li>{{end}}</ul>
+{{end}}{{if .Expiring}}<h3>Ending this week</h3>
+<ul>{{range .Expiring}}<li>{{.Opponent}} on {{.Segment}}, ends {{date .Date}}</li>{{end}}</ul>
+{{end}}{{if .Settled}}<h3>Results</h3>
+<ul>{{range .Settled}}<li>You {{.Result}} against {{.Opponent}} on {{.Segment}}</li>{{end}}</ul>
+{{end}}<hr>
+<p style="font-size: 12px; color: #888;">You are receiving this because you subscribed to the Bestrida weekly digest.
+<a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>
+</body>
+</html>
+`
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"github.com/jrzimmerman/bestrida-server-go/notify/smtptest"
+)
+
+func TestSMTPMailerSend(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("unable to start SMTP capture server: %v", err)
+	}
+	defer server.Close()
+
+	n := ChallengeNotification(ChallengeReceived, testChallenge(), 2)
+	e, err := RenderNotificationEmail("bob@example.com", n, "http://localhost/api/email/unsubscribe?token=abc")
+	if err != nil {
+		t.Fatalf("unable to render email: %v", err)
+	}
+	m := SMTPMailer{Addr: server.Addr(), From: "no-reply@bestridaapp.com"}
+	if err := m.Send(e); err != nil {
+		t.Fatalf("unable to send email: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	msg := messages[0]
+	if len(msg.To) != 1 || msg.To[0] != "bob@example.com" {
+		t.Errorf("unexpected recipients %v", msg.To)
+	}
+	for _, exp := range []string{
+		"Subject: New challenge",
+		"List-Unsubscribe: <http://localhost/api/email/unsubscribe?token=abc>",
+		"text/plain",
+		"text/html",
+		"Ann challenged you on Hawk Hill",
+	} {
+		if !strings.Contains(msg.Data, exp) {
+			t.Errorf("expected message to contain %q", exp)
+		}
+	}
+}
+
+func TestRenderDigestEmail(t *testing.T) {
+	expires := time.Date(2018, 6, 4, 23, 59, 59, 0, time.UTC)
+	c := testChallenge()
+	c.Status = "complete"
+	pending := *testChallenge()
+	pending.Status = "pending"
+	pending.Expires = &expires
+
+	u := &models.User{ID: 2, FirstName: "Bob"}
+	d := NewDigest(u, []models.Challenge{pending}, []models.Challenge{*c}, nil)
+	if d.Empty() {
+		t.Fatal("expected digest to have challenges")
+	}
+	e, err := RenderDigestEmail("bob@example.com", d, "http://localhost/unsubscribe")
+	if err != nil {
+		t.Fatalf("unable to render digest: %v", err)
+	}
+	for _, exp := range []string{"Hi Bob", "Ann on Hawk Hill, ends Mon Jun 4", "You won against Ann on Hawk Hill", "http://localhost/unsubscribe"} {
+		if !strings.Contains(e.Text, exp) {
+			t.Errorf("expected text to contain %q, got:\n%s", exp, e.Text)
+		}
+		if !strings.Contains(e.HTML, exp) {
+			t.Errorf("expected html to contain %q, got:\n%s", exp, e.HTML)
+		}
+	}
+
+	if !NewDigest(u, nil, nil, nil).Empty() {
+		t.Error("expected digest without challenges to be empty")
+	}
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FCMHost is the Firebase Cloud Messaging HTTP v1 endpoint
+const FCMHost = "https://fcm.googleapis.com"
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCMSender sends notifications to Android devices with a Firebase service account
+type FCMSender struct {
+	// Host is the FCM endpoint, tests point it at a local server
+	Host        string
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// NewFCMSender instantiates an FCMSender from service account credentials JSON
+func NewFCMSender(credentials []byte) (*FCMSender, error) {
+	var creds struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(credentials, &creds); err != nil {
+		return nil, err
+	}
+	if creds.ProjectID == "" || creds.ClientEmail == "" {
+		return nil, errors.New("FCM credentials require project_id and client_email")
+	}
+	signer, err := parsePrivateKey([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	key, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("FCM key must be an RSA private key")
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &FCMSender{
+		Host:        FCMHost,
+		projectID:   creds.ProjectID,
+		clientEmail: creds.ClientEmail,
+		tokenURI:    creds.TokenURI,
+		key:         key,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Send delivers a notification to a single device
+func (s *FCMSender) Send(token string, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        token,
+			"notification": map[string]string{"title": n.Title, "body": n.Body},
+			"data":         n.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.token()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/v1/projects/%s/messages:send", s.Host, s.projectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+auth)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var fcmErr struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&fcmErr)
+	switch {
+	case resp.StatusCode == http.StatusNotFound, fcmErr.Error.Status == "UNREGISTERED":
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusBadRequest && fcmErr.Error.Status == "INVALID_ARGUMENT":
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusUnauthorized:
+		s.mu.Lock()
+		s.accessToken = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("FCM returned %d: %s", resp.StatusCode, fcmErr.Error.Message)
+}
+
+// token exchanges a signed service account assertion for an OAuth2 access token
+func (s *FCMSender) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accessToken != "" && time.Now().Before(s.expires) {
+		return s.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(
+		map[string]interface{}{"alg": "RS256", "typ": "JWT"},
+		map[string]interface{}{
+			"iss":   s.clientEmail,
+			"scope": fcmScope,
+			"aud":   s.tokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		},
+		s.key,
+	)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	resp, err := s.client.Post(s.tokenURI, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token exchange returned %d", resp.StatusCode)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	// refresh a minute early so a token never expires mid request
+	s.accessToken = tok.AccessToken
+	s.expires = now.Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return s.accessToken, nil
+}
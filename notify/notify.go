@@ -0,0 +1,219 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// challenge lifecycle events users are notified about
+const (
+	ChallengeReceived = "challenge.received"
+	ChallengeAccepted = "challenge.accepted"
+	ChallengeDeclined = "challenge.declined"
+	OpponentCompleted = "challenge.opponentCompleted"
+	ChallengeSettled  = "challenge.settled"
+	ChallengeExpiring = "challenge.expiring"
+)
+
+// Notification is a push message for a single user
+type Notification struct {
+	Type  string            `json:"type"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// ErrInvalidToken is returned by a Sender when the device token is no longer valid
+var ErrInvalidToken = errors.New("invalid device token")
+
+// Sender delivers push notifications to devices on a single platform
+type Sender interface {
+	Send(token string, n Notification) error
+}
+
+// Notifier sends notifications to every device registered by a user
+type Notifier struct {
+	senders map[string]Sender
+}
+
+// NewNotifier instantiates a Notifier with a Sender for each platform
+func NewNotifier(senders map[string]Sender) *Notifier {
+	return &Notifier{senders: senders}
+}
+
+// Push sends a notification to each of the users devices.
+// Devices with invalid tokens are removed, an error is only returned when no device received it.
+func (n *Notifier) Push(userID int64, msg Notification) error {
+	devices, err := models.GetDevicesByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	var sent int
+	var lastErr error
+	for _, d := range devices {
+		sender, ok := n.senders[d.Platform]
+		if !ok {
+			log.WithField("USER ID", userID).Warnf("no push sender for platform %s", d.Platform)
+			continue
+		}
+		err := sender.Send(d.Token, msg)
+		if err == ErrInvalidToken {
+			log.WithField("USER ID", userID).Infof("removing invalid %s device token", d.Platform)
+			models.RemoveDevice(userID, d.Token)
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Errorf("unable to push %s to user %d", msg.Type, userID)
+			lastErr = err
+			continue
+		}
+		sent++
+	}
+	if sent == 0 && lastErr != nil {
+		return lastErr
+	}
+	log.WithField("USER ID", userID).Infof("pushed %s to %d devices", msg.Type, sent)
+	return nil
+}
+
+// FromEnv configures APNs and FCM senders from the environment,
+// platforms that are not configured log notifications instead of sending them
+func FromEnv() *Notifier {
+	senders := map[string]Sender{
+		models.PlatformIOS:     LogSender{Platform: models.PlatformIOS},
+		models.PlatformAndroid: LogSender{Platform: models.PlatformAndroid},
+	}
+
+	if path, ok := os.LookupEnv("APNS_KEY_PATH"); ok {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.WithError(err).Error("unable to read APNs key")
+		} else {
+			s, err := NewAPNSSender(key, os.Getenv("APNS_KEY_ID"), os.Getenv("APNS_TEAM_ID"), os.Getenv("APNS_TOPIC"), os.Getenv("APNS_PRODUCTION") == "true")
+			if err != nil {
+				log.WithError(err).Error("unable to configure APNs sender")
+			} else {
+				senders[models.PlatformIOS] = s
+			}
+		}
+	}
+
+	if path, ok := os.LookupEnv("FCM_CREDENTIALS_PATH"); ok {
+		creds, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.WithError(err).Error("unable to read FCM credentials")
+		} else {
+			s, err := NewFCMSender(creds)
+			if err != nil {
+				log.WithError(err).Error("unable to configure FCM sender")
+			} else {
+				senders[models.PlatformAndroid] = s
+			}
+		}
+	}
+
+	return NewNotifier(senders)
+}
+
+// LogSender logs notifications instead of sending them, used for local development
+type LogSender struct {
+	Platform string
+}
+
+// Send logs the notification
+func (s LogSender) Send(token string, n Notification) error {
+	log.WithField("PLATFORM", s.Platform).Infof("push %s: %s - %s", n.Type, n.Title, n.Body)
+	return nil
+}
+
+// Sent is a notification recorded by a StubSender
+type Sent struct {
+	Token        string
+	Notification Notification
+}
+
+// StubSender records notifications in memory for tests
+type StubSender struct {
+	mu   sync.Mutex
+	sent []Sent
+	// Err is returned from Send when set
+	Err error
+}
+
+// Send records the notification
+func (s *StubSender) Send(token string, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	s.sent = append(s.sent, Sent{Token: token, Notification: n})
+	return nil
+}
+
+// Sent returns the notifications recorded so far
+func (s *StubSender) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// ChallengeNotification builds the notification a user receives for a challenge event
+func ChallengeNotification(event string, c *models.Challenge, recipientID int64) Notification {
+	opponent := c.Challenger
+	if c.Challenger != nil && c.Challenger.ID == recipientID {
+		opponent = c.Challengee
+	}
+	opponentName := "Your opponent"
+	if opponent != nil && opponent.Name != "" {
+		opponentName = opponent.Name
+	}
+	segmentName := "a segment"
+	if c.Segment != nil && c.Segment.Name != "" {
+		segmentName = c.Segment.Name
+	}
+
+	n := Notification{
+		Type: event,
+		Data: map[string]string{"challengeId": c.ID.Hex(), "type": event},
+	}
+	switch event {
+	case ChallengeReceived:
+		n.Title = "New challenge"
+		n.Body = fmt.Sprintf("%s challenged you on %s", opponentName, segmentName)
+	case ChallengeAccepted:
+		n.Title = "Challenge accepted"
+		n.Body = fmt.Sprintf("%s accepted your challenge on %s", opponentName, segmentName)
+	case ChallengeDeclined:
+		n.Title = "Challenge declined"
+		n.Body = fmt.Sprintf("%s declined your challenge on %s", opponentName, segmentName)
+	case OpponentCompleted:
+		n.Title = "Opponent finished"
+		n.Body = fmt.Sprintf("%s completed %s, your turn", opponentName, segmentName)
+	case ChallengeSettled:
+		n.Title = "Challenge complete"
+		switch {
+		case c.WinnerID == nil:
+			n.Body = fmt.Sprintf("You tied %s on %s", opponentName, segmentName)
+		case *c.WinnerID == recipientID:
+			n.Body = fmt.Sprintf("You beat %s on %s", opponentName, segmentName)
+		default:
+			n.Body = fmt.Sprintf("%s beat you on %s", opponentName, segmentName)
+		}
+	case ChallengeExpiring:
+		n.Title = "Challenge ending soon"
+		if c.Expires != nil {
+			n.Body = fmt.Sprintf("Your challenge against %s on %s ends %s", opponentName, segmentName, c.Expires.In(c.Location()).Format("Jan 2 15:04 MST"))
+		} else {
+			n.Body = fmt.Sprintf("Your challenge against %s on %s ends soon", opponentName, segmentName)
+		}
+	}
+	return n
+}
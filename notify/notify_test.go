@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func testChallenge() *models.Challenge {
+	winner := int64(2)
+	return &models.Challenge{
+		ID:         bson.NewObjectId(),
+		Segment:    &models.Segment{Name: "Hawk Hill"},
+		Challenger: &models.Opponent{ID: 1, Name: "Ann"},
+		Challengee: &models.Opponent{ID: 2, Name: "Bob"},
+		WinnerID:   &winner,
+	}
+}
+
+func TestChallengeNotification(t *testing.T) {
+	c := testChallenge()
+
+	n := ChallengeNotification(ChallengeReceived, c, 2)
+	if exp := "Ann challenged you on Hawk Hill"; n.Body != exp {
+		t.Errorf("expected body %q, got %q", exp, n.Body)
+	}
+	if n.Data["challengeId"] != c.ID.Hex() {
+		t.Errorf("expected challenge ID %s in data, got %s", c.ID.Hex(), n.Data["challengeId"])
+	}
+
+	if exp := "You beat Ann on Hawk Hill"; ChallengeNotification(ChallengeSettled, c, 2).Body != exp {
+		t.Errorf("expected winner body %q", exp)
+	}
+	if exp := "Bob beat you on Hawk Hill"; ChallengeNotification(ChallengeSettled, c, 1).Body != exp {
+		t.Errorf("expected loser body %q", exp)
+	}
+}
+
+func TestStubSender(t *testing.T) {
+	s := &StubSender{}
+	n := Notification{Type: ChallengeAccepted, Title: "Challenge accepted"}
+	if err := s.Send("token", n); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent := s.Sent()
+	if len(sent) != 1 || sent[0].Token != "token" || sent[0].Notification.Type != ChallengeAccepted {
+		t.Errorf("unexpected sent notifications: %+v", sent)
+	}
+}
+
+func TestAPNSSenderSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "bearer ") {
+			t.Errorf("expected bearer provider token, got %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("apns-topic") != "com.bestrida.app" {
+			t.Errorf("unexpected topic %q", r.Header.Get("apns-topic"))
+		}
+		if r.URL.Path == "/3/device/expired" {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+			return
+		}
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if _, ok := payload["aps"]; !ok {
+			t.Error("expected aps payload")
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewAPNSSender(keyPEM, "KEYID", "TEAMID", "com.bestrida.app", false)
+	if err != nil {
+		t.Fatalf("unable to create sender: %v", err)
+	}
+	s.Host = server.URL
+	s.client = server.Client()
+
+	n := ChallengeNotification(ChallengeReceived, testChallenge(), 2)
+	if err := s.Send("valid", n); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.Send("expired", n); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestFCMSenderSend(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var tokenRequests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		w.Write([]byte(`{"access_token":"access","expires_in":3600}`))
+	})
+	mux.HandleFunc("/v1/projects/bestrida/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Message struct {
+				Token string `json:"token"`
+			} `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Message.Token == "expired" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"status":"UNREGISTERED"}}`))
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	creds, _ := json.Marshal(map[string]string{
+		"project_id":   "bestrida",
+		"client_email": "push@bestrida.iam.gserviceaccount.com",
+		"private_key":  string(keyPEM),
+		"token_uri":    server.URL + "/token",
+	})
+	s, err := NewFCMSender(creds)
+	if err != nil {
+		t.Fatalf("unable to create sender: %v", err)
+	}
+	s.Host = server.URL
+
+	n := ChallengeNotification(ChallengeAccepted, testChallenge(), 1)
+	if err := s.Send("valid", n); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.Send("expired", n); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected access token to be reused, got %d token requests", tokenRequests)
+	}
+}
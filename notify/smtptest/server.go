@@ -0,0 +1,121 @@
+// Package smtptest provides a local SMTP server that captures messages for tests
+package smtptest
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is an email received by the Server
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server accepts SMTP connections on localhost and records every message
+type Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []Message
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a capture server on a random local port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the host:port to send mail to
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Messages returns the messages captured so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	c := textproto.NewConn(conn)
+	defer c.Close()
+
+	c.PrintfLine("220 localhost ESMTP capture")
+	var msg Message
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			c.PrintfLine("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = Message{From: trimAddress(line[len("MAIL FROM:"):])}
+			c.PrintfLine("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, trimAddress(line[len("RCPT TO:"):]))
+			c.PrintfLine("250 OK")
+		case cmd == "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case cmd == "RSET", cmd == "NOOP":
+			c.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func trimAddress(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, " "); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "<>")
+}
@@ -0,0 +1,253 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Priority decides how much of the remaining quota a caller may use
+type Priority int
+
+// request priorities, interactive requests may use the quota reserved from bulk syncs
+const (
+	Interactive Priority = iota
+	Bulk
+)
+
+func (p Priority) String() string {
+	if p == Bulk {
+		return "bulk"
+	}
+	return "interactive"
+}
+
+// ErrLimited is returned when a request would have to wait longer than allowed for quota
+var ErrLimited = errors.New("strava rate limit reached")
+
+// Strava documents 600 requests every 15 minutes and 30000 per day,
+// the real limits are read from response headers as soon as a request completes
+const (
+	defaultShortLimit = 600
+	defaultLongLimit  = 30000
+	shortWindow       = 15 * time.Minute
+)
+
+// Options configures a Limiter
+type Options struct {
+	// BulkFraction is the share of each window bulk requests may use
+	BulkFraction float64
+	// MaxWait is how long each priority may block waiting for quota before ErrLimited
+	MaxWait map[Priority]time.Duration
+}
+
+// Usage is a snapshot of the quota used in both Strava windows
+type Usage struct {
+	ShortLimit   int       `json:"shortLimit"`
+	ShortUsage   int       `json:"shortUsage"`
+	ShortResetAt time.Time `json:"shortResetAt"`
+	LongLimit    int       `json:"longLimit"`
+	LongUsage    int       `json:"longUsage"`
+	LongResetAt  time.Time `json:"longResetAt"`
+	BulkFraction float64   `json:"bulkFraction"`
+	Waiting      int       `json:"waiting"`
+	Rejected     int       `json:"rejected"`
+	UpdatedAt    time.Time `json:"updatedAt,omitempty"`
+}
+
+// Limiter tracks Strava's 15 minute and daily windows and holds back requests close to the limit
+type Limiter struct {
+	mu         sync.Mutex
+	opts       Options
+	shortLimit int
+	longLimit  int
+	shortUsage int
+	longUsage  int
+	shortStart time.Time
+	longStart  time.Time
+	waiting    map[Priority]int
+	rejected   int
+	updatedAt  time.Time
+	now        func() time.Time
+	sleep      func(time.Duration)
+}
+
+// New instantiates a Limiter
+func New(opts Options) *Limiter {
+	if opts.BulkFraction <= 0 || opts.BulkFraction > 1 {
+		opts.BulkFraction = 0.8
+	}
+	if opts.MaxWait == nil {
+		opts.MaxWait = map[Priority]time.Duration{
+			Interactive: 10 * time.Second,
+			Bulk:        shortWindow,
+		}
+	}
+	l := &Limiter{
+		opts:       opts,
+		shortLimit: defaultShortLimit,
+		longLimit:  defaultLongLimit,
+		waiting:    make(map[Priority]int),
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}
+	l.roll(l.now())
+	return l
+}
+
+// Strava is the limiter shared by every Strava client in the process
+var Strava = New(Options{})
+
+// Client returns a http.Client for strava.NewClient that waits on the limiter
+func (l *Limiter) Client(p Priority) *http.Client {
+	return &http.Client{Transport: &transport{limiter: l, priority: p, next: http.DefaultTransport}}
+}
+
+// Wait blocks until a request with the given priority may be sent.
+// It returns ErrLimited when the wait would exceed the priority's MaxWait.
+func (l *Limiter) Wait(p Priority) error {
+	deadline := l.now().Add(l.opts.MaxWait[p])
+	l.mu.Lock()
+	l.waiting[p]++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting[p]--
+		l.mu.Unlock()
+	}()
+
+	for {
+		l.mu.Lock()
+		now := l.now()
+		l.roll(now)
+		wait := l.delay(p, now)
+		if wait == 0 {
+			// count the request now so concurrent callers see it before headers arrive
+			l.shortUsage++
+			l.longUsage++
+			l.mu.Unlock()
+			return nil
+		}
+		if now.Add(wait).After(deadline) {
+			l.rejected++
+			l.mu.Unlock()
+			log.Warnf("strava %s request rejected, quota frees up in %v", p, wait)
+			return ErrLimited
+		}
+		l.mu.Unlock()
+
+		log.Infof("strava %s request waiting %v for rate limit", p, wait)
+		l.sleep(wait)
+	}
+}
+
+// Update records the limits and usage Strava returned in response headers
+func (l *Limiter) Update(h http.Header) {
+	limits := parsePair(h.Get("X-Ratelimit-Limit"))
+	usage := parsePair(h.Get("X-Ratelimit-Usage"))
+	if limits == nil || usage == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.roll(now)
+	l.shortLimit, l.longLimit = limits[0], limits[1]
+	l.shortUsage, l.longUsage = usage[0], usage[1]
+	l.updatedAt = now
+	log.Debugf("strava rate limit usage %d/%d short, %d/%d long", l.shortUsage, l.shortLimit, l.longUsage, l.longLimit)
+}
+
+// Usage returns the current quota usage
+func (l *Limiter) Usage() Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll(l.now())
+	return Usage{
+		ShortLimit:   l.shortLimit,
+		ShortUsage:   l.shortUsage,
+		ShortResetAt: l.shortStart.Add(shortWindow),
+		LongLimit:    l.longLimit,
+		LongUsage:    l.longUsage,
+		LongResetAt:  l.longStart.AddDate(0, 0, 1),
+		BulkFraction: l.opts.BulkFraction,
+		Waiting:      l.waiting[Interactive] + l.waiting[Bulk],
+		Rejected:     l.rejected,
+		UpdatedAt:    l.updatedAt,
+	}
+}
+
+// roll resets usage when a window boundary has passed,
+// Strava's short window starts every quarter hour and the long window at midnight UTC
+func (l *Limiter) roll(now time.Time) {
+	now = now.UTC()
+	short := now.Truncate(shortWindow)
+	if !short.Equal(l.shortStart) {
+		l.shortStart = short
+		l.shortUsage = 0
+	}
+	long := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !long.Equal(l.longStart) {
+		l.longStart = long
+		l.longUsage = 0
+	}
+}
+
+// delay returns how long a request with priority p must wait, zero when it may go now
+func (l *Limiter) delay(p Priority, now time.Time) time.Duration {
+	fraction := 1.0
+	if p == Bulk {
+		fraction = l.opts.BulkFraction
+		// bulk requests always yield to waiting interactive requests
+		if l.waiting[Interactive] > 0 {
+			return time.Second
+		}
+	}
+	if float64(l.longUsage) >= float64(l.longLimit)*fraction {
+		return l.longStart.AddDate(0, 0, 1).Sub(now)
+	}
+	if float64(l.shortUsage) >= float64(l.shortLimit)*fraction {
+		return l.shortStart.Add(shortWindow).Sub(now)
+	}
+	return 0
+}
+
+func parsePair(v string) []int {
+	parts := strings.Split(v, ",")
+	if len(parts) != 2 {
+		return nil
+	}
+	pair := make([]int, 2)
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil
+		}
+		pair[i] = n
+	}
+	return pair
+}
+
+type transport struct {
+	limiter  *Limiter
+	priority Priority
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(t.priority); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.Update(resp.Header)
+	return resp, nil
+}
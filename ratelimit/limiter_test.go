@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(now time.Time) *Limiter {
+	l := New(Options{
+		BulkFraction: 0.5,
+		MaxWait:      map[Priority]time.Duration{Interactive: time.Second, Bulk: time.Second},
+	})
+	l.now = func() time.Time { return now }
+	l.sleep = func(time.Duration) {}
+	l.roll(now)
+	return l
+}
+
+func TestUpdateFromHeaders(t *testing.T) {
+	now := time.Date(2017, 9, 1, 10, 5, 0, 0, time.UTC)
+	l := newTestLimiter(now)
+
+	h := http.Header{}
+	h.Set("X-Ratelimit-Limit", "600,30000")
+	h.Set("X-Ratelimit-Usage", "12,340")
+	l.Update(h)
+
+	u := l.Usage()
+	if u.ShortUsage != 12 || u.LongUsage != 340 || u.ShortLimit != 600 || u.LongLimit != 30000 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+	if exp := time.Date(2017, 9, 1, 10, 15, 0, 0, time.UTC); !u.ShortResetAt.Equal(exp) {
+		t.Errorf("expected short window to reset at %v, got %v", exp, u.ShortResetAt)
+	}
+	if exp := time.Date(2017, 9, 2, 0, 0, 0, 0, time.UTC); !u.LongResetAt.Equal(exp) {
+		t.Errorf("expected long window to reset at %v, got %v", exp, u.LongResetAt)
+	}
+}
+
+func TestBulkReservesQuotaForInteractive(t *testing.T) {
+	now := time.Date(2017, 9, 1, 10, 5, 0, 0, time.UTC)
+	l := newTestLimiter(now)
+
+	h := http.Header{}
+	h.Set("X-Ratelimit-Limit", "10,1000")
+	h.Set("X-Ratelimit-Usage", "5,100")
+	l.Update(h)
+
+	if err := l.Wait(Bulk); err != ErrLimited {
+		t.Errorf("expected bulk request to be limited at half the short window, got %v", err)
+	}
+	if err := l.Wait(Interactive); err != nil {
+		t.Errorf("expected interactive request to use the reserved quota, got %v", err)
+	}
+	if u := l.Usage(); u.ShortUsage != 6 || u.Rejected != 1 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+}
+
+func TestWindowResets(t *testing.T) {
+	now := time.Date(2017, 9, 1, 10, 14, 0, 0, time.UTC)
+	l := newTestLimiter(now)
+
+	h := http.Header{}
+	h.Set("X-Ratelimit-Limit", "10,1000")
+	h.Set("X-Ratelimit-Usage", "10,100")
+	l.Update(h)
+
+	if err := l.Wait(Interactive); err != ErrLimited {
+		t.Errorf("expected interactive request to be limited, got %v", err)
+	}
+
+	// the next quarter hour starts a new short window
+	l.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if err := l.Wait(Interactive); err != nil {
+		t.Errorf("expected request in new window to pass, got %v", err)
+	}
+	if u := l.Usage(); u.ShortUsage != 1 || u.LongUsage != 101 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+}
+
+func TestIgnoresMissingHeaders(t *testing.T) {
+	l := newTestLimiter(time.Now())
+	l.Update(http.Header{})
+	if u := l.Usage(); u.ShortLimit != defaultShortLimit || u.LongLimit != defaultLongLimit {
+		t.Errorf("expected default limits, got %+v", u)
+	}
+}
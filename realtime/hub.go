@@ -0,0 +1,196 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// subscriberBuffer is how many events a slow connection may fall behind before events are dropped
+const subscriberBuffer = 32
+
+// Event is a message pushed to a connected user
+type Event struct {
+	ID        string          `bson:"_id" json:"id"`
+	UserID    int64           `bson:"userId" json:"userId"`
+	Type      string          `bson:"type" json:"type"`
+	Data      json.RawMessage `bson:"data" json:"data"`
+	CreatedAt time.Time       `bson:"createdAt" json:"createdAt"`
+}
+
+// NewEvent builds an event for a user with data encoded as JSON
+func NewEvent(userID int64, eventType string, data interface{}) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:        bson.NewObjectId().Hex(),
+		UserID:    userID,
+		Type:      eventType,
+		Data:      raw,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Backend carries published events to the hub in every running instance
+type Backend interface {
+	// Publish sends an event to all instances
+	Publish(e Event) error
+	// Run delivers events published by any instance until ctx is done
+	Run(ctx context.Context, deliver func(Event)) error
+}
+
+// Subscription receives the events for a single user
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	userID int64
+}
+
+// Hub fans events out to the subscriptions for each user
+type Hub struct {
+	backend Backend
+	mu      sync.RWMutex
+	subs    map[int64]map[*Subscription]struct{}
+	done    <-chan struct{}
+}
+
+// NewHub instantiates a Hub on a backend, call Start before publishing
+func NewHub(backend Backend) *Hub {
+	return &Hub{
+		backend: backend,
+		subs:    make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+// Start delivers events from the backend until ctx is done, restarting the backend if it fails
+func (h *Hub) Start(ctx context.Context) {
+	h.done = ctx.Done()
+	go func() {
+		for {
+			err := h.backend.Run(ctx, h.deliver)
+			if ctx.Err() != nil {
+				return
+			}
+			log.WithError(err).Error("realtime backend stopped, restarting")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+// Done is closed when the hub stops so open streams can end
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Publish sends an event to the user on whichever instance they are connected to
+func (h *Hub) Publish(e Event) error {
+	return h.backend.Publish(e)
+}
+
+// Subscribe starts receiving events for a user
+func (h *Hub) Subscribe(userID int64) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: c, c: c, userID: userID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][s] = struct{}{}
+	return s
+}
+
+// Unsubscribe stops a subscription and closes its channel
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s.userID][s]; !ok {
+		return
+	}
+	delete(h.subs[s.userID], s)
+	if len(h.subs[s.userID]) == 0 {
+		delete(h.subs, s.userID)
+	}
+	close(s.c)
+}
+
+// Connections returns the number of open subscriptions
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var n int
+	for _, subs := range h.subs {
+		n += len(subs)
+	}
+	return n
+}
+
+func (h *Hub) deliver(e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs[e.UserID] {
+		select {
+		case s.c <- e:
+		default:
+			// never let one slow connection block delivery to everyone else
+			log.WithField("USER ID", e.UserID).Warnf("dropping %s event for slow subscriber", e.Type)
+		}
+	}
+}
+
+// LocalBackend delivers events within a single process
+type LocalBackend struct {
+	events chan Event
+}
+
+// NewLocalBackend instantiates a LocalBackend
+func NewLocalBackend() *LocalBackend {
+	return &LocalBackend{events: make(chan Event, 256)}
+}
+
+// ErrBacklogFull is returned when events are published faster than they are delivered
+var ErrBacklogFull = errors.New("realtime event backlog full")
+
+// Publish queues the event for delivery
+func (b *LocalBackend) Publish(e Event) error {
+	select {
+	case b.events <- e:
+		return nil
+	default:
+		return ErrBacklogFull
+	}
+}
+
+// Run delivers queued events until ctx is done
+func (b *LocalBackend) Run(ctx context.Context, deliver func(Event)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e := <-b.events:
+			deliver(e)
+		}
+	}
+}
+
+// BackendFromEnv returns the MongoDB backend when REALTIME_BACKEND is mongo,
+// otherwise events are only delivered within this process
+func BackendFromEnv() Backend {
+	if os.Getenv("REALTIME_BACKEND") == "mongo" {
+		return &MongoBackend{}
+	}
+	return NewLocalBackend()
+}
@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHubDeliversToSubscribedUser(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := NewHub(NewLocalBackend())
+	h.Start(ctx)
+
+	sub := h.Subscribe(1)
+	other := h.Subscribe(2)
+	defer h.Unsubscribe(sub)
+	defer h.Unsubscribe(other)
+
+	e, err := NewEvent(1, "challenge.accepted", map[string]string{"challengeId": "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Publish(e); err != nil {
+		t.Fatalf("unexpected error publishing: %v", err)
+	}
+
+	select {
+	case got := <-sub.C:
+		if got.ID != e.ID || string(got.Data) != `{"challengeId":"abc"}` {
+			t.Errorf("unexpected event %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected event to be delivered")
+	}
+	select {
+	case got := <-other.C:
+		t.Errorf("expected no event for other user, got %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	h := NewHub(NewLocalBackend())
+	sub := h.Subscribe(1)
+	if h.Connections() != 1 {
+		t.Errorf("expected 1 connection, got %d", h.Connections())
+	}
+	h.Unsubscribe(sub)
+	h.Unsubscribe(sub)
+	if _, ok := <-sub.C; ok {
+		t.Error("expected subscription channel to be closed")
+	}
+	if h.Connections() != 0 {
+		t.Errorf("expected no connections, got %d", h.Connections())
+	}
+}
+
+func TestHubDropsEventsForSlowSubscriber(t *testing.T) {
+	h := NewHub(NewLocalBackend())
+	sub := h.Subscribe(1)
+	defer h.Unsubscribe(sub)
+
+	for i := 0; i < subscriberBuffer+5; i++ {
+		h.deliver(Event{UserID: 1, Type: "test"})
+	}
+	if len(sub.C) != subscriberBuffer {
+		t.Errorf("expected %d buffered events, got %d", subscriberBuffer, len(sub.C))
+	}
+}
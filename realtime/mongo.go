@@ -0,0 +1,44 @@
+package realtime
+
+import (
+	"context"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+)
+
+// MongoBackend shares events between instances through a tailable capped collection,
+// the mgo driver predates change streams so tailing is how inserts are observed
+type MongoBackend struct {
+	// last is the ID of the last event delivered, a restarted Run resumes after it
+	last string
+}
+
+// Publish stores the event for every instance to deliver
+func (*MongoBackend) Publish(e Event) error {
+	return models.InsertRealtimeEvent(models.RealtimeEvent{
+		ID:        e.ID,
+		UserID:    e.UserID,
+		Type:      e.Type,
+		Data:      e.Data,
+		CreatedAt: e.CreatedAt,
+	})
+}
+
+// Run tails the events collection and delivers new events until ctx is done,
+// after a failure it resumes with the events published while it was down
+func (b *MongoBackend) Run(ctx context.Context, deliver func(Event)) error {
+	if err := models.EnsureEventsCollection(); err != nil {
+		return err
+	}
+	last, err := models.TailEvents(ctx, b.last, func(e models.RealtimeEvent) {
+		deliver(Event{
+			ID:        e.ID,
+			UserID:    e.UserID,
+			Type:      e.Type,
+			Data:      e.Data,
+			CreatedAt: e.CreatedAt,
+		})
+	})
+	b.last = last
+	return err
+}
@@ -0,0 +1,581 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package bestridapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// UsersClient is the client API for Users service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UsersClient interface {
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	ListFriends(ctx context.Context, in *ListFriendsRequest, opts ...grpc.CallOption) (*ListFriendsResponse, error)
+	ListUserSegments(ctx context.Context, in *ListUserSegmentsRequest, opts ...grpc.CallOption) (*ListUserSegmentsResponse, error)
+}
+
+type usersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUsersClient(cc grpc.ClientConnInterface) UsersClient {
+	return &usersClient{cc}
+}
+
+func (c *usersClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Users/GetUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) ListFriends(ctx context.Context, in *ListFriendsRequest, opts ...grpc.CallOption) (*ListFriendsResponse, error) {
+	out := new(ListFriendsResponse)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Users/ListFriends", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) ListUserSegments(ctx context.Context, in *ListUserSegmentsRequest, opts ...grpc.CallOption) (*ListUserSegmentsResponse, error) {
+	out := new(ListUserSegmentsResponse)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Users/ListUserSegments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UsersServer is the server API for Users service.
+// All implementations must embed UnimplementedUsersServer
+// for forward compatibility
+type UsersServer interface {
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	ListFriends(context.Context, *ListFriendsRequest) (*ListFriendsResponse, error)
+	ListUserSegments(context.Context, *ListUserSegmentsRequest) (*ListUserSegmentsResponse, error)
+	mustEmbedUnimplementedUsersServer()
+}
+
+// UnimplementedUsersServer must be embedded to have forward compatible implementations.
+type UnimplementedUsersServer struct {
+}
+
+func (UnimplementedUsersServer) GetUser(context.Context, *GetUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedUsersServer) ListFriends(context.Context, *ListFriendsRequest) (*ListFriendsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFriends not implemented")
+}
+func (UnimplementedUsersServer) ListUserSegments(context.Context, *ListUserSegmentsRequest) (*ListUserSegmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserSegments not implemented")
+}
+func (UnimplementedUsersServer) mustEmbedUnimplementedUsersServer() {}
+
+// UnsafeUsersServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UsersServer will
+// result in compilation errors.
+type UnsafeUsersServer interface {
+	mustEmbedUnimplementedUsersServer()
+}
+
+func RegisterUsersServer(s grpc.ServiceRegistrar, srv UsersServer) {
+	s.RegisterService(&_Users_serviceDesc, srv)
+}
+
+func _Users_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Users/GetUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_ListFriends_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFriendsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).ListFriends(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Users/ListFriends",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).ListFriends(ctx, req.(*ListFriendsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_ListUserSegments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUserSegmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).ListUserSegments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Users/ListUserSegments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).ListUserSegments(ctx, req.(*ListUserSegmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Users_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "bestrida.v1.Users",
+	HandlerType: (*UsersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetUser",
+			Handler:    _Users_GetUser_Handler,
+		},
+		{
+			MethodName: "ListFriends",
+			Handler:    _Users_ListFriends_Handler,
+		},
+		{
+			MethodName: "ListUserSegments",
+			Handler:    _Users_ListUserSegments_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "bestrida.proto",
+}
+
+// SegmentsClient is the client API for Segments service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SegmentsClient interface {
+	GetSegment(ctx context.Context, in *GetSegmentRequest, opts ...grpc.CallOption) (*Segment, error)
+}
+
+type segmentsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSegmentsClient(cc grpc.ClientConnInterface) SegmentsClient {
+	return &segmentsClient{cc}
+}
+
+func (c *segmentsClient) GetSegment(ctx context.Context, in *GetSegmentRequest, opts ...grpc.CallOption) (*Segment, error) {
+	out := new(Segment)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Segments/GetSegment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SegmentsServer is the server API for Segments service.
+// All implementations must embed UnimplementedSegmentsServer
+// for forward compatibility
+type SegmentsServer interface {
+	GetSegment(context.Context, *GetSegmentRequest) (*Segment, error)
+	mustEmbedUnimplementedSegmentsServer()
+}
+
+// UnimplementedSegmentsServer must be embedded to have forward compatible implementations.
+type UnimplementedSegmentsServer struct {
+}
+
+func (UnimplementedSegmentsServer) GetSegment(context.Context, *GetSegmentRequest) (*Segment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSegment not implemented")
+}
+func (UnimplementedSegmentsServer) mustEmbedUnimplementedSegmentsServer() {}
+
+// UnsafeSegmentsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SegmentsServer will
+// result in compilation errors.
+type UnsafeSegmentsServer interface {
+	mustEmbedUnimplementedSegmentsServer()
+}
+
+func RegisterSegmentsServer(s grpc.ServiceRegistrar, srv SegmentsServer) {
+	s.RegisterService(&_Segments_serviceDesc, srv)
+}
+
+func _Segments_GetSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSegmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentsServer).GetSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Segments/GetSegment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentsServer).GetSegment(ctx, req.(*GetSegmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Segments_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "bestrida.v1.Segments",
+	HandlerType: (*SegmentsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSegment",
+			Handler:    _Segments_GetSegment_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "bestrida.proto",
+}
+
+// ChallengesClient is the client API for Challenges service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChallengesClient interface {
+	GetChallenge(ctx context.Context, in *GetChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	ListChallenges(ctx context.Context, in *ListChallengesRequest, opts ...grpc.CallOption) (*ListChallengesResponse, error)
+	CreateChallenge(ctx context.Context, in *CreateChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	AcceptChallenge(ctx context.Context, in *AcceptChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	DeclineChallenge(ctx context.Context, in *DeclineChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	CompleteChallenge(ctx context.Context, in *CompleteChallengeRequest, opts ...grpc.CallOption) (*Challenge, error)
+	// WatchChallengeEvents streams the challenge events of a user until the client cancels
+	WatchChallengeEvents(ctx context.Context, in *WatchChallengeEventsRequest, opts ...grpc.CallOption) (Challenges_WatchChallengeEventsClient, error)
+}
+
+type challengesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChallengesClient(cc grpc.ClientConnInterface) ChallengesClient {
+	return &challengesClient{cc}
+}
+
+func (c *challengesClient) GetChallenge(ctx context.Context, in *GetChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Challenges/GetChallenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *challengesClient) ListChallenges(ctx context.Context, in *ListChallengesRequest, opts ...grpc.CallOption) (*ListChallengesResponse, error) {
+	out := new(ListChallengesResponse)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Challenges/ListChallenges", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *challengesClient) CreateChallenge(ctx context.Context, in *CreateChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Challenges/CreateChallenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *challengesClient) AcceptChallenge(ctx context.Context, in *AcceptChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Challenges/AcceptChallenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *challengesClient) DeclineChallenge(ctx context.Context, in *DeclineChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Challenges/DeclineChallenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *challengesClient) CompleteChallenge(ctx context.Context, in *CompleteChallengeRequest, opts ...grpc.CallOption) (*Challenge, error) {
+	out := new(Challenge)
+	err := c.cc.Invoke(ctx, "/bestrida.v1.Challenges/CompleteChallenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *challengesClient) WatchChallengeEvents(ctx context.Context, in *WatchChallengeEventsRequest, opts ...grpc.CallOption) (Challenges_WatchChallengeEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Challenges_serviceDesc.Streams[0], "/bestrida.v1.Challenges/WatchChallengeEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &challengesWatchChallengeEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Challenges_WatchChallengeEventsClient interface {
+	Recv() (*ChallengeEvent, error)
+	grpc.ClientStream
+}
+
+type challengesWatchChallengeEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *challengesWatchChallengeEventsClient) Recv() (*ChallengeEvent, error) {
+	m := new(ChallengeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ChallengesServer is the server API for Challenges service.
+// All implementations must embed UnimplementedChallengesServer
+// for forward compatibility
+type ChallengesServer interface {
+	GetChallenge(context.Context, *GetChallengeRequest) (*Challenge, error)
+	ListChallenges(context.Context, *ListChallengesRequest) (*ListChallengesResponse, error)
+	CreateChallenge(context.Context, *CreateChallengeRequest) (*Challenge, error)
+	AcceptChallenge(context.Context, *AcceptChallengeRequest) (*Challenge, error)
+	DeclineChallenge(context.Context, *DeclineChallengeRequest) (*Challenge, error)
+	CompleteChallenge(context.Context, *CompleteChallengeRequest) (*Challenge, error)
+	// WatchChallengeEvents streams the challenge events of a user until the client cancels
+	WatchChallengeEvents(*WatchChallengeEventsRequest, Challenges_WatchChallengeEventsServer) error
+	mustEmbedUnimplementedChallengesServer()
+}
+
+// UnimplementedChallengesServer must be embedded to have forward compatible implementations.
+type UnimplementedChallengesServer struct {
+}
+
+func (UnimplementedChallengesServer) GetChallenge(context.Context, *GetChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChallenge not implemented")
+}
+func (UnimplementedChallengesServer) ListChallenges(context.Context, *ListChallengesRequest) (*ListChallengesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChallenges not implemented")
+}
+func (UnimplementedChallengesServer) CreateChallenge(context.Context, *CreateChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateChallenge not implemented")
+}
+func (UnimplementedChallengesServer) AcceptChallenge(context.Context, *AcceptChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptChallenge not implemented")
+}
+func (UnimplementedChallengesServer) DeclineChallenge(context.Context, *DeclineChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeclineChallenge not implemented")
+}
+func (UnimplementedChallengesServer) CompleteChallenge(context.Context, *CompleteChallengeRequest) (*Challenge, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteChallenge not implemented")
+}
+func (UnimplementedChallengesServer) WatchChallengeEvents(*WatchChallengeEventsRequest, Challenges_WatchChallengeEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchChallengeEvents not implemented")
+}
+func (UnimplementedChallengesServer) mustEmbedUnimplementedChallengesServer() {}
+
+// UnsafeChallengesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChallengesServer will
+// result in compilation errors.
+type UnsafeChallengesServer interface {
+	mustEmbedUnimplementedChallengesServer()
+}
+
+func RegisterChallengesServer(s grpc.ServiceRegistrar, srv ChallengesServer) {
+	s.RegisterService(&_Challenges_serviceDesc, srv)
+}
+
+func _Challenges_GetChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChallengesServer).GetChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Challenges/GetChallenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChallengesServer).GetChallenge(ctx, req.(*GetChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Challenges_ListChallenges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChallengesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChallengesServer).ListChallenges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Challenges/ListChallenges",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChallengesServer).ListChallenges(ctx, req.(*ListChallengesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Challenges_CreateChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChallengesServer).CreateChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Challenges/CreateChallenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChallengesServer).CreateChallenge(ctx, req.(*CreateChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Challenges_AcceptChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChallengesServer).AcceptChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Challenges/AcceptChallenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChallengesServer).AcceptChallenge(ctx, req.(*AcceptChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Challenges_DeclineChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeclineChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChallengesServer).DeclineChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Challenges/DeclineChallenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChallengesServer).DeclineChallenge(ctx, req.(*DeclineChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Challenges_CompleteChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChallengesServer).CompleteChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bestrida.v1.Challenges/CompleteChallenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChallengesServer).CompleteChallenge(ctx, req.(*CompleteChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Challenges_WatchChallengeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchChallengeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChallengesServer).WatchChallengeEvents(m, &challengesWatchChallengeEventsServer{stream})
+}
+
+type Challenges_WatchChallengeEventsServer interface {
+	Send(*ChallengeEvent) error
+	grpc.ServerStream
+}
+
+type challengesWatchChallengeEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *challengesWatchChallengeEventsServer) Send(m *ChallengeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Challenges_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "bestrida.v1.Challenges",
+	HandlerType: (*ChallengesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetChallenge",
+			Handler:    _Challenges_GetChallenge_Handler,
+		},
+		{
+			MethodName: "ListChallenges",
+			Handler:    _Challenges_ListChallenges_Handler,
+		},
+		{
+			MethodName: "CreateChallenge",
+			Handler:    _Challenges_CreateChallenge_Handler,
+		},
+		{
+			MethodName: "AcceptChallenge",
+			Handler:    _Challenges_AcceptChallenge_Handler,
+		},
+		{
+			MethodName: "DeclineChallenge",
+			Handler:    _Challenges_DeclineChallenge_Handler,
+		},
+		{
+			MethodName: "CompleteChallenge",
+			Handler:    _Challenges_CompleteChallenge_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchChallengeEvents",
+			Handler:       _Challenges_WatchChallengeEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bestrida.proto",
+}
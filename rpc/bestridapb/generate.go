@@ -0,0 +1,4 @@
+// Package bestridapb holds the protobuf messages and gRPC services internal consumers use
+package bestridapb
+
+//go:generate protoc -I . --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. bestrida.proto
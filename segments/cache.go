@@ -0,0 +1,175 @@
+package segments
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	log "github.com/sirupsen/logrus"
+	strava "github.com/strava/go.strava"
+	"gopkg.in/mgo.v2"
+)
+
+// MaxAge is how long cached segment data may be kept before it is refreshed,
+// this is required by Strava API license agreement
+const MaxAge = 7 * 24 * time.Hour
+
+// Fetcher requests segment detail from Strava
+type Fetcher func(id int64) (*strava.SegmentDetailed, error)
+
+// ClientFetcher fetches segments with the given Strava client
+func ClientFetcher(client *strava.Client) Fetcher {
+	return func(id int64) (*strava.SegmentDetailed, error) {
+		return strava.NewSegmentsService(client).Get(id).Do()
+	}
+}
+
+// Metrics counts how segment lookups were served
+type Metrics struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Refreshes int64 `json:"refreshes"`
+	Stale     int64 `json:"stale"`
+	Errors    int64 `json:"errors"`
+	Coalesced int64 `json:"coalesced"`
+}
+
+// Cache returns segments from MongoDB and fetches them from Strava when missing or stale
+type Cache struct {
+	// metrics is first so its counters stay 64-bit aligned for atomic access
+	metrics  Metrics
+	maxAge   time.Duration
+	mu       sync.Mutex
+	inflight map[int64]*call
+	now      func() time.Time
+	lookup   func(id int64) (*models.Segment, error)
+}
+
+type call struct {
+	wg      sync.WaitGroup
+	segment *models.Segment
+	err     error
+}
+
+// New instantiates a Cache that refreshes segments older than maxAge
+func New(maxAge time.Duration) *Cache {
+	return &Cache{
+		maxAge:   maxAge,
+		inflight: make(map[int64]*call),
+		now:      time.Now,
+		lookup:   models.GetSegmentByID,
+	}
+}
+
+// Get returns a fresh segment, fetching it with fetch when missing or older than the max age.
+// Concurrent lookups for the same segment share a single fetch.
+func (c *Cache) Get(id int64, fetch Fetcher) (*models.Segment, error) {
+	c.mu.Lock()
+	if cl, ok := c.inflight[id]; ok {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.metrics.Coalesced, 1)
+		cl.wg.Wait()
+		return cl.segment, cl.err
+	}
+	cl := &call{}
+	cl.wg.Add(1)
+	c.inflight[id] = cl
+	c.mu.Unlock()
+
+	cl.segment, cl.err = c.load(id, fetch, false)
+	cl.wg.Done()
+
+	c.mu.Lock()
+	delete(c.inflight, id)
+	c.mu.Unlock()
+	return cl.segment, cl.err
+}
+
+// Refresh fetches the segment from Strava even when the cached copy is fresh.
+// Unlike Get it returns the fetch error instead of falling back to the cached copy.
+func (c *Cache) Refresh(id int64, fetch Fetcher) (*models.Segment, error) {
+	return c.load(id, fetch, true)
+}
+
+// Metrics returns a snapshot of the cache counters
+func (c *Cache) Metrics() Metrics {
+	return Metrics{
+		Hits:      atomic.LoadInt64(&c.metrics.Hits),
+		Misses:    atomic.LoadInt64(&c.metrics.Misses),
+		Refreshes: atomic.LoadInt64(&c.metrics.Refreshes),
+		Stale:     atomic.LoadInt64(&c.metrics.Stale),
+		Errors:    atomic.LoadInt64(&c.metrics.Errors),
+		Coalesced: atomic.LoadInt64(&c.metrics.Coalesced),
+	}
+}
+
+// IsStale reports whether a cached segment must be refreshed
+func (c *Cache) IsStale(s *models.Segment) bool {
+	return c.now().After(s.UpdatedAt.Add(c.maxAge))
+}
+
+func (c *Cache) load(id int64, fetch Fetcher, force bool) (*models.Segment, error) {
+	cached, err := c.lookup(id)
+	if err != nil && !models.IsNotFound(err) {
+		atomic.AddInt64(&c.metrics.Errors, 1)
+		return nil, err
+	}
+
+	if cached == nil {
+		atomic.AddInt64(&c.metrics.Misses, 1)
+		log.WithField("SEGMENT ID", id).Infof("segment %v not found in database... saving", id)
+		detail, err := fetch(id)
+		if err != nil {
+			atomic.AddInt64(&c.metrics.Errors, 1)
+			log.WithField("SEGMENT ID", id).Errorf("unable to retrieve segment detail for %d:\n %v", id, err)
+			return nil, err
+		}
+		saved, err := models.SaveSegment(detail)
+		if mgo.IsDup(err) {
+			// another instance cached it first, refresh that copy instead
+			existing, getErr := models.GetSegmentByID(detail.Id)
+			if getErr == nil {
+				saved, err = existing.UpdateSegment(detail)
+			}
+		}
+		if err != nil {
+			atomic.AddInt64(&c.metrics.Errors, 1)
+			log.WithError(err).Errorf("unable to save segment detail %d to database", detail.Id)
+			return nil, err
+		}
+		log.WithField("SEGMENT ID", saved.ID).Infof("segment %d stored in DB", saved.ID)
+		return saved, nil
+	}
+
+	if !force && !c.IsStale(cached) {
+		atomic.AddInt64(&c.metrics.Hits, 1)
+		return cached, nil
+	}
+
+	log.WithField("SEGMENT ID", id).Infof("segment %v is stale or forced... updating", id)
+	detail, err := fetch(id)
+	if err != nil && force {
+		atomic.AddInt64(&c.metrics.Errors, 1)
+		log.WithField("SEGMENT ID", id).Errorf("unable to refresh segment %d:\n %v", id, err)
+		return nil, err
+	}
+	if err != nil {
+		// serve the stale copy rather than failing while Strava is unavailable
+		atomic.AddInt64(&c.metrics.Stale, 1)
+		log.WithField("SEGMENT ID", id).Warnf("unable to refresh segment %d, serving cached copy from %v:\n %v", id, cached.UpdatedAt, err)
+		return cached, nil
+	}
+	updated, err := cached.UpdateSegment(detail)
+	if err != nil {
+		atomic.AddInt64(&c.metrics.Errors, 1)
+		log.WithError(err).Errorf("unable to update segment detail %d in database", detail.Id)
+		if force {
+			return nil, err
+		}
+		return cached, nil
+	}
+	atomic.AddInt64(&c.metrics.Refreshes, 1)
+	log.WithField("SEGMENT ID", updated.ID).Infof("segment %d updated in DB", updated.ID)
+	return updated, nil
+}
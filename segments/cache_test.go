@@ -0,0 +1,121 @@
+package segments
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jrzimmerman/bestrida-server-go/models"
+	strava "github.com/strava/go.strava"
+)
+
+func TestIsStale(t *testing.T) {
+	now := time.Date(2017, 9, 10, 0, 0, 0, 0, time.UTC)
+	c := New(MaxAge)
+	c.now = func() time.Time { return now }
+
+	if c.IsStale(&models.Segment{UpdatedAt: now.AddDate(0, 0, -6)}) {
+		t.Error("segment updated 6 days ago should be fresh")
+	}
+	if !c.IsStale(&models.Segment{UpdatedAt: now.AddDate(0, 0, -8)}) {
+		t.Error("segment updated 8 days ago should be stale")
+	}
+}
+
+func TestGetMissingSegmentFetchFailure(t *testing.T) {
+	c := New(MaxAge)
+	fetched := 0
+	fetch := func(id int64) (*strava.SegmentDetailed, error) {
+		fetched++
+		return nil, errors.New("strava unavailable")
+	}
+
+	if _, err := c.Get(0, fetch); err == nil {
+		t.Error("expected error when a missing segment cannot be fetched")
+	}
+	m := c.Metrics()
+	if fetched != 1 || m.Misses != 1 || m.Errors != 1 {
+		t.Errorf("unexpected fetch count %d and metrics %+v", fetched, m)
+	}
+}
+
+// getConcurrently calls Get for the same segment from n goroutines while fetch is blocked,
+// and returns the results once every caller has joined the first fetch
+func getConcurrently(t *testing.T, c *Cache, n int, fetch Fetcher, release chan struct{}) ([]*models.Segment, []error) {
+	segments, errs := make([]*models.Segment, n), make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			segments[i], errs[i] = c.Get(7, fetch)
+		}(i)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.Metrics().Coalesced < int64(n-1) {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("only %d lookups joined the fetch", c.Metrics().Coalesced)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	return segments, errs
+}
+
+func TestGetCoalescesConcurrentMisses(t *testing.T) {
+	const n = 10
+	c := New(MaxAge)
+	c.lookup = func(id int64) (*models.Segment, error) { return nil, models.NotFoundError("segment", id) }
+	release := make(chan struct{})
+	var fetched int32
+	fetchErr := errors.New("strava unavailable")
+	fetch := func(id int64) (*strava.SegmentDetailed, error) {
+		atomic.AddInt32(&fetched, 1)
+		<-release
+		return nil, fetchErr
+	}
+
+	segments, errs := getConcurrently(t, c, n, fetch, release)
+	if fetched != 1 {
+		t.Errorf("expected a single fetch, got %d", fetched)
+	}
+	for i := range errs {
+		if segments[i] != nil || errs[i] != fetchErr {
+			t.Errorf("caller %d: expected the shared fetch error, got %v, %v", i, segments[i], errs[i])
+		}
+	}
+	if m := c.Metrics(); m.Misses != 1 || m.Coalesced != n-1 {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+}
+
+func TestGetCoalescesConcurrentRefreshes(t *testing.T) {
+	const n = 10
+	c := New(MaxAge)
+	stale := &models.Segment{ID: 7, UpdatedAt: time.Now().Add(-2 * MaxAge)}
+	c.lookup = func(id int64) (*models.Segment, error) { return stale, nil }
+	release := make(chan struct{})
+	var fetched int32
+	fetch := func(id int64) (*strava.SegmentDetailed, error) {
+		atomic.AddInt32(&fetched, 1)
+		<-release
+		return nil, errors.New("strava unavailable")
+	}
+
+	segments, errs := getConcurrently(t, c, n, fetch, release)
+	if fetched != 1 {
+		t.Errorf("expected a single fetch, got %d", fetched)
+	}
+	for i := range segments {
+		if segments[i] != stale || errs[i] != nil {
+			t.Errorf("caller %d: expected the shared cached copy, got %v, %v", i, segments[i], errs[i])
+		}
+	}
+	if m := c.Metrics(); m.Stale != 1 || m.Coalesced != n-1 {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"os"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -14,3 +15,27 @@ func GetEnvString(env string) string {
 	}
 	return str
 }
+
+// GetEnvInt will check for the environment variable, and if found return the integer
+// the fallback is returned when the variable is missing or invalid
+func GetEnvInt(env string, fallback int) int {
+	str, ok := os.LookupEnv(env)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(str)
+	if err != nil {
+		log.WithField("ENV", env).Warnf("Invalid integer environment variable, using %d", fallback)
+		return fallback
+	}
+	return n
+}
+
+// GetEnvStringDefault will check for the environment variable, and if found return the string
+// the fallback is returned when the variable is missing
+func GetEnvStringDefault(env, fallback string) string {
+	if str, ok := os.LookupEnv(env); ok {
+		return str
+	}
+	return fallback
+}
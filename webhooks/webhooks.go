@@ -0,0 +1,218 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// events a webhook can subscribe to
+const (
+	ChallengeCreated   = "challenge.created"
+	ChallengeAccepted  = "challenge.accepted"
+	ChallengeCompleted = "challenge.completed"
+	ChallengeSettled   = "challenge.settled"
+)
+
+// Events lists every event a webhook can subscribe to
+var Events = []string{ChallengeCreated, ChallengeAccepted, ChallengeCompleted, ChallengeSettled}
+
+// headers sent with every delivery
+const (
+	SignatureHeader = "X-Bestrida-Signature"
+	TimestampHeader = "X-Bestrida-Timestamp"
+	EventHeader     = "X-Bestrida-Event"
+	DeliveryHeader  = "X-Bestrida-Delivery"
+)
+
+// Client sends deliveries, receivers that take longer than the timeout are retried.
+// It only connects to public addresses and never follows redirects, webhook URLs are
+// chosen by users and must not reach the internal network or cloud metadata.
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         publicDialContext(&net.Dialer{Timeout: 5 * time.Second}),
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: noRedirect,
+}
+
+// ErrNonPublicAddress is returned for webhook hosts that resolve to a loopback, private,
+// link-local or otherwise internal address
+var ErrNonPublicAddress = errors.New("webhook host must resolve to a public address")
+
+// internalNetworks are the ranges not covered by the net.IP classification methods
+var internalNetworks = parseCIDRs(
+	"0.0.0.0/8",      // this network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"240.0.0.0/4",    // reserved
+	"64:ff9b::/96",   // NAT64, may map to an internal IPv4 address
+	"fc00::/7",       // unique local
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// publicIP reports whether ip is a globally routable unicast address
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range internalNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicDialContext resolves the host and dials one of its addresses only when all of them
+// are public, the checked address is dialed so DNS cannot change between check and connect
+func publicDialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses found for %s", host)
+		}
+		for _, ip := range ips {
+			if !publicIP(ip.IP) {
+				return nil, ErrNonPublicAddress
+			}
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+	}
+}
+
+// noRedirect returns redirects to the caller, a public receiver could otherwise redirect
+// deliveries to an internal address
+func noRedirect(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// ValidEvent reports whether a webhook can subscribe to an event
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateURL checks a webhook URL is an absolute http or https URL that does not name
+// a local or internal address
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("webhook URL must use http or https")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("webhook URL must include a host")
+	}
+	// hosts are resolved again on every delivery, this only rejects the obvious cases early
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrNonPublicAddress
+	}
+	if h := strings.ToLower(strings.TrimSuffix(host, ".")); h == "localhost" || strings.HasSuffix(h, ".localhost") {
+		return ErrNonPublicAddress
+	}
+	return nil
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign computes the signature header value for a body sent at timestamp.
+// The timestamp is signed too so receivers can reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery was signed with the secret, receivers can use it to authenticate requests
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Result describes a single delivery attempt, the response body is discarded
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// Deliver posts a signed event to a webhook URL, non 2xx responses are returned as errors
+func Deliver(client *http.Client, target, secret, event, deliveryID string, body []byte) (Result, error) {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Bestrida-Webhooks/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	res := Result{Duration: time.Since(started)}
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, fmt.Errorf("webhook receiver returned %d", resp.StatusCode)
+	}
+	return res, nil
+}
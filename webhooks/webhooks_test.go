@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"challenge.settled"}`)
+	sig := Sign("secret", 1528156800, body)
+	if !Verify("secret", 1528156800, body, sig) {
+		t.Error("expected signature to verify")
+	}
+	if Verify("other", 1528156800, body, sig) {
+		t.Error("expected signature with another secret to fail")
+	}
+	if Verify("secret", 1528156801, body, sig) {
+		t.Error("expected signature with another timestamp to fail")
+	}
+}
+
+func TestDeliverToReceiver(t *testing.T) {
+	body := []byte(`{"event":"challenge.created"}`)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := ioutil.ReadAll(r.Body)
+		ts, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if err != nil || !Verify("secret", ts, got, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(EventHeader) != ChallengeCreated || r.Header.Get(DeliveryHeader) != "delivery" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer receiver.Close()
+
+	res, err := Deliver(receiver.Client(), receiver.URL, "secret", ChallengeCreated, "delivery", body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	res, err = Deliver(receiver.Client(), receiver.URL, "wrong", ChallengeCreated, "delivery", body)
+	if err == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized delivery to fail, got %+v %v", res, err)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for raw, valid := range map[string]bool{
+		"https://discord.com/api/webhooks/1/abc":  true,
+		"https://203.0.113.7/hook":                true,
+		"http://localhost:8080/hook":              false,
+		"http://api.localhost/hook":               false,
+		"http://127.0.0.1/hook":                   false,
+		"http://169.254.169.254/latest/meta-data": false,
+		"http://10.0.0.5/hook":                    false,
+		"http://[::1]/hook":                       false,
+		"ftp://example.com":                       false,
+		"/relative":                               false,
+	} {
+		if err := ValidateURL(raw); (err == nil) != valid {
+			t.Errorf("expected %s valid to be %v, got %v", raw, valid, err)
+		}
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for raw, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := publicIP(net.ParseIP(raw)); got != public {
+			t.Errorf("expected %s public to be %v, got %v", raw, public, got)
+		}
+	}
+}
+
+func TestDeliverRefusesInternalAddresses(t *testing.T) {
+	var hits int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer receiver.Close()
+
+	_, err := Deliver(Client, receiver.URL, "secret", ChallengeCreated, "delivery", []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), ErrNonPublicAddress.Error()) {
+		t.Errorf("expected delivery to a loopback receiver to be refused, got %v", err)
+	}
+	if hits != 0 {
+		t.Errorf("expected the receiver not to be called, got %d requests", hits)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	var redirected bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			redirected = true
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	client := receiver.Client()
+	client.CheckRedirect = Client.CheckRedirect
+	res, err := Deliver(client, receiver.URL, "secret", ChallengeCreated, "delivery", []byte(`{}`))
+	if err == nil || res.StatusCode != http.StatusFound {
+		t.Errorf("expected a redirect to fail the delivery, got %+v %v", res, err)
+	}
+	if redirected {
+		t.Error("expected the redirect not to be followed")
+	}
+}